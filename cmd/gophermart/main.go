@@ -2,29 +2,39 @@ package main
 
 import (
 	"context"
+	"errors"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/clients/loyal"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/clients/webhook"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/handlers/event"
 	httpHandlers "github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/handlers/http"
 	repo "github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/repository/postgres"
-	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/config"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/pubsub"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/service"
 	"go.uber.org/zap"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 const (
-	frequency        = time.Second
-	workersCount int = 20
+	frequency            = time.Second
+	cleanupFrequency     = time.Hour
+	workersCount     int = 20
+	batchSize        int = 100
+	webhookWorkers   int = 10
 )
 
 func main() {
 	conf := config.MakeConfig()
-	mainContext := context.Background()
+	mainContext, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	l, err := logger.Initialize(conf.LogLevel)
 	if err != nil {
 		log.Fatal(err)
@@ -33,38 +43,156 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	orderInfosChannel := make(chan clients.OrderLoyaltyInfo, 1000)
+	migrator := postgres.NewMigrator(dbClient)
+	if len(conf.Args) > 0 && conf.Args[0] == "migrate" {
+		if err := runMigrate(mainContext, migrator, conf.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if conf.AutoMigrate {
+		if _, err := migrator.Up(mainContext); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := migrator.CheckSchema(mainContext); err != nil {
+		log.Fatal(err)
+	}
+	if len(conf.Args) > 0 && conf.Args[0] == "role" {
+		if err := runRole(
+			mainContext, dbClient, repo.NewUserRepository(dbClient), repo.NewSessionRepository(dbClient),
+			conf.Args[1:],
+		); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	tokens, err := newTokenManager(conf, l)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, err := newUserPolicy(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passwords, err := newPasswords(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cookies, err := auth.NewCookieConfig(conf.CookieSecure, conf.CookieSameSite, conf.CookieDomain, conf.CSRFProtection)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	orderRepo := repo.NewOrderRepository(dbClient)
 	userRepo := repo.NewUserRepository(dbClient)
 	transactionRepo := repo.NewTransactionRepository(dbClient)
+	accrualResultRepo := repo.NewAccrualResultRepository(dbClient)
+	sessionRepo := repo.NewSessionRepository(dbClient)
+	loginAuditRepo := repo.NewLoginAuditRepository(dbClient)
+	auditLogRepo := repo.NewAuditLogRepository(dbClient)
+	webhookRepo := repo.NewWebhookRepository(dbClient)
+	loginAttempts := repo.NewLoginAttemptRepository(dbClient)
 	txHelper := postgres.NewTransactionHelper(dbClient)
 
-	if err := dbClient.CreateTables(mainContext); err != nil {
-		log.Fatal(err)
-	}
+	loyaltyClient := loyal.NewLoyaltyClient(
+		conf.AccrualSystemAddress, loyal.Config{
+			Timeout:          conf.AccrualTimeout,
+			RetryCount:       conf.AccrualRetryCount,
+			RetryWaitTime:    conf.AccrualRetryWait,
+			RetryMaxWaitTime: conf.AccrualRetryMaxWait,
+			MaxIdleConns:     workersCount,
+		}, l,
+	)
 
-	loyaltyClient := loyal.NewLoyaltyClient(conf.AccrualSystemAddress, l)
+	webhookClient := webhook.NewClient(conf.WebhookTimeout, l)
 
-	balanceService := service.NewBalanceService(transactionRepo, txHelper)
-	orderService := service.NewOrderService(orderRepo, txHelper)
-	userService := service.NewUserService(userRepo)
+	balanceService := service.NewBalanceService(transactionRepo, auditLogRepo, webhookRepo, txHelper)
+	orderEvents := pubsub.NewHub()
+	orderService := service.NewOrderService(orderRepo, accrualResultRepo, webhookRepo, txHelper, orderEvents)
+	sessionService := service.NewSessionService(sessionRepo, userRepo, tokens, txHelper, conf.RefreshTTL)
+	userService := service.NewUserService(userRepo, loginAttempts, loginAuditRepo, sessionService, policy, passwords)
+	webhookService := service.NewWebhookService(webhookRepo, webhookClient, conf.WebhookTimeout, webhookWorkers)
 
-	orderProcessor := service.NewOrderProcessor(orderInfosChannel, loyaltyClient, orderService)
+	orderProcessor := service.NewOrderProcessor(loyaltyClient, orderService)
 
-	fetchHandler := event.NewFetchHandler(orderProcessor, orderService, frequency, workersCount, l)
-	updateHandler := event.NewUpdateHandler(orderInfosChannel, orderService, frequency, l)
+	fetchHandler := event.NewFetchHandler(orderProcessor, orderService, frequency, workersCount, batchSize, l)
+	updateHandler := event.NewUpdateHandler(orderService, frequency, batchSize, l)
+	webhookDeliveryHandler := event.NewWebhookHandler(webhookService, frequency, batchSize, l)
+	cleanupHandler := event.NewCleanupHandler(userService, cleanupFrequency, l)
 
 	balanceHandler := httpHandlers.NewBalanceHandler(balanceService, l)
-	orderHandler := httpHandlers.NewOrderHandler(orderService, l)
-	userHandler := httpHandlers.NewUserHandler(userService, l)
+	orderHandler := httpHandlers.NewOrderHandler(orderService, orderEvents, l)
+	userHandler := httpHandlers.NewUserHandler(userService, sessionService, cookies, l)
+	webhookHandler := httpHandlers.NewWebhookHandler(webhookService, l)
+	adminHandler := httpHandlers.NewAdminHandler(userService, orderService, balanceService, l)
 
-	router := httpHandlers.GetRouter(userHandler, orderHandler, balanceHandler)
-	event.Subscribe(mainContext, fetchHandler, updateHandler)
+	authMiddleware := auth.Middleware(tokens, sessionService, cookies)
+	router := httpHandlers.GetRouter(
+		authMiddleware, userHandler, orderHandler, balanceHandler, webhookHandler, adminHandler,
+	)
+	// Сигнал остановки только запрещает обработчикам брать новую работу, а начатая доделывается
+	// с отдельным контекстом, который отменяется по истечении ShutdownTimeout
+	workContext, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	subscription := event.Subscribe(
+		workContext, mainContext.Done(), fetchHandler, updateHandler, webhookDeliveryHandler, cleanupHandler,
+	)
 
-	l.L.Info("Running server", zap.String("address", conf.RunAddress))
-	err = http.ListenAndServe(conf.RunAddress, router)
-	if err != nil {
-		log.Fatal(err)
+	server := &http.Server{Addr: conf.RunAddress, Handler: router}
+	// Shutdown не ждёт открытые потоки событий: их подписки закрываются
+	server.RegisterOnShutdown(orderEvents.Close)
+	serverErrors := make(chan error, 1)
+	go func() {
+		l.L.Info("Running server", zap.String("address", conf.RunAddress))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErrors <- err
+		}
+	}()
+
+	select {
+	case <-mainContext.Done():
+		l.L.Info("Shutdown signal received")
+	case err := <-serverErrors:
+		l.L.Error("Server failed", zap.Error(err))
+		stop()
+	}
+
+	shutdownContext, cancelShutdown := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancelShutdown()
+	go func() {
+		<-shutdownContext.Done()
+		cancelWork()
+	}()
+	if err := server.Shutdown(shutdownContext); err != nil {
+		l.L.Error("failed to stop http server", zap.Error(err))
+	}
+	// Полученные ответы системы лояльности уже сохранены в accrual_results и будут применены
+	// после перезапуска, поэтому достаточно дождаться завершения текущих транзакций
+	select {
+	case <-subscription.FetchDone():
+	case <-shutdownContext.Done():
+		l.L.Error("accrual fetch workers did not finish in time")
+	}
+	select {
+	case <-subscription.UpdateDone():
+	case <-shutdownContext.Done():
+		l.L.Error("order updates did not finish in time")
+	}
+	// Недоставленные webhook остаются в очереди и будут отправлены после перезапуска
+	select {
+	case <-subscription.WebhookDone():
+	case <-shutdownContext.Done():
+		l.L.Error("webhook deliveries did not finish in time")
+	}
+	select {
+	case <-subscription.CleanupDone():
+	case <-shutdownContext.Done():
+		l.L.Error("login history cleanup did not finish in time")
+	}
+	if err := dbClient.Close(); err != nil {
+		l.L.Error("failed to close database connection", zap.Error(err))
 	}
+	l.L.Info("Server stopped")
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: gophermart migrate up|down|status"
+
+func runMigrate(ctx context.Context, migrator *postgres.Migrator, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+	switch args[0] {
+	case "up":
+		group, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if group.IsZero() {
+			fmt.Println("there are no new migrations to run, database is up to date")
+			return nil
+		}
+		fmt.Printf("migrated to %s\n", group)
+	case "down":
+		group, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if group.IsZero() {
+			fmt.Println("there are no migrations to roll back")
+			return nil
+		}
+		fmt.Printf("rolled back %s\n", group)
+	case "status":
+		ms, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tGROUP\tMIGRATED AT")
+		for _, m := range ms {
+			migratedAt := "pending"
+			if m.IsApplied() {
+				migratedAt = m.MigratedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", m.Name, m.Comment, m.GroupID, migratedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/config"
+	"golang.org/x/crypto/bcrypt"
+	"regexp"
+)
+
+// newPasswords хеширует новые пароли выбранным алгоритмом и проверяет хеши обоих алгоритмов
+func newPasswords(conf config.Config) (*auth.Passwords, error) {
+	if conf.BcryptCost < bcrypt.MinCost || conf.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if conf.Argon2Time < 1 || conf.Argon2Threads < 1 || conf.Argon2Threads > 255 ||
+		conf.Argon2Memory < 8*conf.Argon2Threads {
+		return nil, fmt.Errorf("invalid argon2id parameters")
+	}
+	bcryptHasher := auth.BcryptHasher{Cost: conf.BcryptCost}
+	argon2idHasher := auth.Argon2idHasher{
+		Time:    uint32(conf.Argon2Time),
+		Memory:  uint32(conf.Argon2Memory),
+		Threads: uint8(conf.Argon2Threads),
+		KeyLen:  32,
+		SaltLen: 16,
+	}
+	switch conf.PasswordHash {
+	case auth.HashBcrypt:
+		return auth.NewPasswords(bcryptHasher, argon2idHasher), nil
+	case auth.HashArgon2id:
+		return auth.NewPasswords(argon2idHasher, bcryptHasher), nil
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", conf.PasswordHash)
+	}
+}
+
+func newUserPolicy(conf config.Config) (user.Policy, error) {
+	policy := user.Policy{
+		LoginMinLength:      conf.LoginMinLength,
+		LoginMaxLength:      conf.LoginMaxLength,
+		PasswordMinLength:   conf.PasswordMinLength,
+		PasswordMaxLength:   conf.PasswordMaxLength,
+		PasswordCharClasses: conf.PasswordCharClasses,
+	}
+	if conf.LoginPattern != "" {
+		pattern, err := regexp.Compile(conf.LoginPattern)
+		if err != nil {
+			return user.Policy{}, err
+		}
+		policy.LoginPattern = pattern
+	}
+	if conf.PasswordBlocklist != "" {
+		blocklist, err := auth.LoadPasswordBlocklist(conf.PasswordBlocklist)
+		if err != nil {
+			return user.Policy{}, err
+		}
+		policy.Blocklist = blocklist
+	}
+
+	return policy, nil
+}
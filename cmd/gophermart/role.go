@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+const roleUsage = "usage: gophermart role <login> user|support|admin"
+
+// runRole назначает роль пользователю из командной строки. Так выдаётся роль первому администратору,
+// дальше роли можно менять через /api/admin.
+func runRole(
+	ctx context.Context, client *postgres.Client, users repository.UserRepository,
+	sessions repository.SessionRepository, args []string,
+) error {
+	if len(args) != 2 {
+		return errors.New(roleUsage)
+	}
+	login, role := args[0], user.Role(args[1])
+	if !role.IsValid() {
+		return errors.New(roleUsage)
+	}
+	u, err := users.GetByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, repository.NoResultError{}) {
+			return fmt.Errorf("user %s not found", login)
+		}
+		return err
+	}
+	// Действующие токены несут прежнюю роль, поэтому сессии отзываются в той же транзакции
+	err = client.RunInTx(
+		ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if err := users.UpdateRole(ctx, u.ID, role, tx); err != nil {
+				return err
+			}
+			return sessions.RevokeUserSessions(ctx, u.ID, time.Now(), tx)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user %s now has role %s\n", login, role)
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/config"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+)
+
+// newTokenManager собирает ключи подписи токенов: файл ключей, затем секрет. Случайный ключ, действующий
+// до перезапуска, используется только с флагом -jwt-dev-random-key: с ним пользователи выходят из системы
+// при каждом перезапуске, а экземпляры сервиса не принимают токены друг друга.
+func newTokenManager(conf config.Config, l logger.MyLogger) (*auth.TokenManager, error) {
+	var keys auth.KeySet
+	var err error
+	switch {
+	case conf.JWTKeysFile != "":
+		keys, err = auth.LoadKeySet(conf.JWTKeysFile)
+	case conf.JWTSecret != "":
+		keys, err = auth.NewSecretKeySet("default", []byte(conf.JWTSecret))
+	case !conf.JWTDevRandomKey:
+		return nil, errors.New("token signing key is not configured: set -jwt-keys-file or -jwt-secret")
+	default:
+		l.L.Warn("token signing key is not configured, using a random key: tokens will not survive a restart")
+		keys, err = auth.NewRandomKeySet()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewTokenManager(keys, conf.JWTIssuer, conf.JWTTTL)
+}
@@ -1,32 +1,70 @@
 package loyal
 
 import (
+	"context"
+	"fmt"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
 	"github.com/go-resty/resty/v2"
 	"go.uber.org/zap"
 	"net/http"
-	"strconv"
+	"time"
 )
 
+type Config struct {
+	// Timeout ограничивает одну попытку запроса
+	Timeout          time.Duration
+	RetryCount       int
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+	MaxIdleConns     int
+}
+
 type LoyaltyClient struct {
-	baseURL string
+	client  *resty.Client
+	limiter *rateLimiter
 	log     logger.MyLogger
 }
 
-func NewLoyaltyClient(baseURL string, log logger.MyLogger) *LoyaltyClient {
-	return &LoyaltyClient{baseURL: baseURL, log: log}
+func NewLoyaltyClient(baseURL string, config Config, log logger.MyLogger) *LoyaltyClient {
+	limiter := &rateLimiter{}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConns
+
+	// Повторяются только сетевые ошибки и ответы 5xx, паузы между попытками растут экспоненциально со случайным разбросом.
+	// Лимитер проверяется перед каждой попыткой, в том числе повторной.
+	client := resty.New().
+		SetLogger(log.L.Sugar()).
+		SetTransport(transport).
+		SetBaseURL(baseURL).
+		SetTimeout(config.Timeout).
+		SetRetryCount(config.RetryCount).
+		SetRetryWaitTime(config.RetryWaitTime).
+		SetRetryMaxWaitTime(config.RetryMaxWaitTime).
+		AddRetryCondition(
+			func(resp *resty.Response, err error) bool {
+				return err != nil || resp.StatusCode() >= http.StatusInternalServerError
+			},
+		).
+		OnBeforeRequest(
+			func(_ *resty.Client, r *resty.Request) error {
+				return limiter.Wait(r.Context())
+			},
+		)
+
+	return &LoyaltyClient{client: client, limiter: limiter, log: log}
 }
 
 const (
 	getOrderInfoPath = "/api/orders"
 )
 
-func (lc LoyaltyClient) GetOrderProcessingInfo(order string) (clients.OrderLoyaltyInfo, error) {
-	client := resty.New()
+func (lc LoyaltyClient) GetOrderProcessingInfo(ctx context.Context, order string) (clients.OrderLoyaltyInfo, error) {
 	var orderInfo clients.OrderLoyaltyInfo
-	resp, err := client.
+	resp, err := lc.client.
 		R().
+		SetContext(ctx).
 		SetResult(&orderInfo).
 		SetPathParams(
 			map[string]string{
@@ -34,31 +72,41 @@ func (lc LoyaltyClient) GetOrderProcessingInfo(order string) (clients.OrderLoyal
 			},
 		).
 		SetHeader("Accept", "application/json").
-		Get(lc.baseURL + getOrderInfoPath + "/{order}")
-	lc.log.L.Info("request", zap.String("URL", resp.Request.URL))
+		Get(getOrderInfoPath + "/{order}")
 	if err != nil {
-		if responseError, ok := err.(*resty.ResponseError); ok {
-			return orderInfo, clients.LoyaltyServiceError{
-				OriginError: responseError,
-			}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return orderInfo, ctxErr
+		}
+		return orderInfo, clients.LoyaltyServiceError{
+			OriginError: err,
 		}
-		return orderInfo, err
 	}
+	lc.log.L.Info("request", zap.String("URL", resp.Request.URL), zap.Int("status", resp.StatusCode()))
 
-	if resp.StatusCode() == http.StatusNoContent {
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return orderInfo, nil
+	case http.StatusNoContent:
 		return orderInfo, clients.NoOrderError{Order: order}
-	}
-
-	if resp.StatusCode() == http.StatusTooManyRequests {
-		retryAfter, err := strconv.Atoi(resp.Header().Get("Retry-After"))
-		if err != nil {
-			return orderInfo, err
+	case http.StatusTooManyRequests:
+		retryAfter, ok := parseRetryAfter(resp.Header().Get("Retry-After"), time.Now())
+		if !ok {
+			retryAfter = defaultRetryAfter
 		}
+		if rpm, ok := parseRateLimitRPM(resp.Body()); ok {
+			lc.limiter.SetRPM(rpm)
+		}
+		lc.limiter.Pause(retryAfter)
+		lc.log.L.Warn(
+			"accrual system rate limit exceeded", zap.Duration("retry_after", retryAfter),
+		)
 		return orderInfo, clients.TooManyRequests{
 			Order:      order,
-			RetryAfter: retryAfter,
+			RetryAfter: int(retryAfter.Seconds()),
+		}
+	default:
+		return orderInfo, clients.LoyaltyServiceError{
+			OriginError: fmt.Errorf("unexpected status %d", resp.StatusCode()),
 		}
 	}
-
-	return orderInfo, nil
 }
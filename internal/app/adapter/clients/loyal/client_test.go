@@ -0,0 +1,185 @@
+package loyal
+
+import (
+	"context"
+	"errors"
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// accrualStub имитирует систему расчёта баллов: первые limited запросов получают 429
+func accrualStub(t *testing.T, limited int32, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= limited {
+					w.Header().Set("Retry-After", retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					_, _ = w.Write([]byte("No more than 600 requests per minute allowed"))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"order": "` + r.URL.Path[len(getOrderInfoPath)+1:] + `", "status": "PROCESSED", "accrual": 729.98}`))
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestClient(baseURL string) *LoyaltyClient {
+	return NewLoyaltyClient(
+		baseURL, Config{
+			Timeout:          time.Second,
+			RetryCount:       2,
+			RetryWaitTime:    time.Millisecond,
+			RetryMaxWaitTime: 10 * time.Millisecond,
+			MaxIdleConns:     1,
+		}, logger.MyLogger{L: zap.NewNop()},
+	)
+}
+
+func TestLoyaltyClient_GetOrderProcessingInfo(t *testing.T) {
+	server, _ := accrualStub(t, 0, "")
+	lc := newTestClient(server.URL)
+	orderNumber := goluhn.Generate(10)
+
+	info, err := lc.GetOrderProcessingInfo(context.Background(), orderNumber)
+	require.NoError(t, err)
+	require.Equal(
+		t, clients.OrderLoyaltyInfo{
+			Order:   orderNumber,
+			Status:  clients.StatusProcessed,
+			Accrual: 72998,
+		}, info,
+	)
+}
+
+func TestLoyaltyClient_NoOrder(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer server.Close()
+	lc := newTestClient(server.URL)
+	orderNumber := goluhn.Generate(10)
+
+	_, err := lc.GetOrderProcessingInfo(context.Background(), orderNumber)
+	require.True(t, errors.Is(err, clients.NoOrderError{Order: orderNumber}))
+}
+
+func TestLoyaltyClient_TooManyRequests(t *testing.T) {
+	server, requests := accrualStub(t, 1, "1")
+	lc := newTestClient(server.URL)
+
+	_, err := lc.GetOrderProcessingInfo(context.Background(), goluhn.Generate(10))
+	var tooManyRequests clients.TooManyRequests
+	require.True(t, errors.As(err, &tooManyRequests))
+	require.Equal(t, 1, tooManyRequests.RetryAfter)
+	require.Equal(t, "600", metricRateLimitRPM.String())
+
+	// Все последующие запросы ждут окончания паузы, а не уходят в систему лояльности
+	start := time.Now()
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := lc.GetOrderProcessingInfo(context.Background(), goluhn.Generate(10))
+			done <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-done)
+	}
+	require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	require.Equal(t, int32(4), requests.Load())
+
+	// После паузы запросы распределяются с учётом лимита 600 запросов в минуту
+	require.Equal(t, 100*time.Millisecond, lc.limiter.interval)
+}
+
+func TestLoyaltyClient_Retry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) < 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"order": "1", "status": "PROCESSING"}`))
+			},
+		),
+	)
+	defer server.Close()
+	lc := newTestClient(server.URL)
+
+	info, err := lc.GetOrderProcessingInfo(context.Background(), "1")
+	require.NoError(t, err)
+	require.Equal(t, clients.StatusProcessing, info.Status)
+	require.Equal(t, int32(3), requests.Load())
+
+	// Попытки исчерпаны: ошибка сервиса лояльности
+	requests.Store(-10)
+	_, err = lc.GetOrderProcessingInfo(context.Background(), "1")
+	var serviceError clients.LoyaltyServiceError
+	require.True(t, errors.As(err, &serviceError))
+}
+
+func TestLoyaltyClient_ContextCanceled(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		),
+	)
+	defer server.Close()
+	lc := newTestClient(server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := lc.GetOrderProcessingInfo(ctx, "1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "Test_1. Секунды", value: "60", want: time.Minute, wantOk: true},
+		{name: "Test_2. HTTP-дата", value: "Sun, 01 Oct 2023 12:00:30 GMT", want: 30 * time.Second, wantOk: true},
+		{name: "Test_3. HTTP-дата в прошлом", value: "Sun, 01 Oct 2023 11:00:00 GMT", want: 0, wantOk: true},
+		{name: "Test_4. Пустой заголовок", value: "", wantOk: false},
+		{name: "Test_5. Отрицательное значение", value: "-1", wantOk: false},
+		{name: "Test_6. Мусор", value: "soon", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, ok := parseRetryAfter(tt.value, now)
+				require.Equal(t, tt.wantOk, ok)
+				require.Equal(t, tt.want, got)
+			},
+		)
+	}
+}
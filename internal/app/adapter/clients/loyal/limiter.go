@@ -0,0 +1,119 @@
+package loyal
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultRetryAfter = time.Minute
+
+var (
+	metrics            = expvar.NewMap("accrual_client")
+	metricPausedUntil  = new(expvar.String)
+	metricRateLimitRPM = new(expvar.Int)
+	metricThrottled    = new(expvar.Int)
+
+	rateLimitRPMRegexp = regexp.MustCompile(`(?i)no more than (\d+) requests per minute`)
+)
+
+func init() {
+	metrics.Set("paused_until", metricPausedUntil)
+	metrics.Set("rate_limit_rpm", metricRateLimitRPM)
+	metrics.Set("throttled_total", metricThrottled)
+}
+
+// rateLimiter общий для всех запросов клиента: после ответа 429 приостанавливает всех вызывающих
+// до истечения Retry-After, а узнав допустимое число запросов в минуту, равномерно распределяет запросы
+type rateLimiter struct {
+	mu          sync.Mutex
+	pausedUntil time.Time
+	interval    time.Duration
+	next        time.Time
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		var delay time.Duration
+		switch {
+		case l.pausedUntil.After(now):
+			delay = l.pausedUntil.Sub(now)
+		case l.interval > 0 && l.next.After(now):
+			delay = l.next.Sub(now)
+		default:
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *rateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		metricPausedUntil.Set(until.Format(time.RFC3339))
+	}
+	metricThrottled.Add(1)
+}
+
+func (l *rateLimiter) SetRPM(rpm int) {
+	if rpm <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval = time.Minute / time.Duration(rpm)
+	metricRateLimitRPM.Set(int64(rpm))
+}
+
+// parseRetryAfter поддерживает обе формы заголовка Retry-After: число секунд и HTTP-дату
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := date.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// parseRateLimitRPM извлекает лимит из тела ответа 429: "No more than N requests per minute allowed"
+func parseRateLimitRPM(body []byte) (int, bool) {
+	matches := rateLimitRPMRegexp.FindSubmatch(body)
+	if matches == nil {
+		return 0, false
+	}
+	rpm, err := strconv.Atoi(string(matches[1]))
+	if err != nil || rpm <= 0 {
+		return 0, false
+	}
+	return rpm, true
+}
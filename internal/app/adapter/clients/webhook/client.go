@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/go-resty/resty/v2"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// forbiddenPrefixes сети, куда webhook не отправляются, чтобы адрес подписчика нельзя было использовать
+// для запросов во внутреннюю сеть сервиса: кроме частных и локальных адресов, это CGNAT, служебные
+// диапазоны и адреса метаданных облачных провайдеров
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+type Client struct {
+	client *resty.Client
+	log    logger.MyLogger
+}
+
+// NewClient клиент без повторов: повторные попытки планирует очередь доставок.
+// Редиректы не выполняются, чтобы подписанное тело не ушло на другой адрес, а тело ответа не читается.
+// Соединения с внутренними адресами запрещены.
+func NewClient(timeout time.Duration, log logger.MyLogger) *Client {
+	return newClient(timeout, publicAddr, log)
+}
+
+// newClient проверяет адрес каждого соединения уже после разрешения имени, поэтому подписчик не может
+// обойти проверку DNS-записью, которая указывает на внутренний адрес или меняется между запросами.
+// Прокси из окружения не используется: иначе проверялся бы адрес прокси, а не подписчика.
+func newClient(timeout time.Duration, allowed func(netip.Addr) bool, log logger.MyLogger) *Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if addr := addrPort.Addr().Unmap(); !allowed(addr) {
+				return fmt.Errorf("webhook address %s is not allowed", addr)
+			}
+			return nil
+		},
+	}
+	client := resty.New().
+		SetLogger(log.L.Sugar()).
+		SetTransport(
+			&http.Transport{
+				DialContext:           dialer.DialContext,
+				ForceAttemptHTTP2:     true,
+				MaxIdleConns:          100,
+				IdleConnTimeout:       90 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ExpectContinueTimeout: time.Second,
+			},
+		).
+		SetTimeout(timeout).
+		SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	return &Client{client: client, log: log}
+}
+
+// publicAddr разрешает только глобальные адреса
+func publicAddr(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Client) Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	resp, err := c.client.
+		R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(headers).
+		SetBody(body).
+		SetDoNotParseResponse(true).
+		Post(url)
+	if resp != nil && resp.RawBody() != nil {
+		defer resp.RawBody().Close()
+	}
+	if err != nil {
+		if resp != nil && resp.StatusCode() != 0 {
+			return resp.StatusCode(), nil
+		}
+		return 0, err
+	}
+	c.log.L.Debug("webhook sent", zap.String("URL", url), zap.Int("status", resp.StatusCode()))
+
+	return resp.StatusCode(), nil
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient разрешает локальные адреса, на которых работают тестовые серверы
+func newTestClient() *Client {
+	return newClient(time.Second, func(netip.Addr) bool { return true }, logger.MyLogger{L: zap.NewNop()})
+}
+
+func TestClient_Send(t *testing.T) {
+	body := []byte(`{"type":"order.processed"}`)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				got, err := io.ReadAll(r.Body)
+				if err != nil || string(got) != string(body) || r.Header.Get("X-Gophermart-Event") != "order.processed" ||
+					r.Header.Get("Content-Type") != "application/json" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			},
+		),
+	)
+	defer server.Close()
+
+	status, err := newTestClient().Send(
+		context.Background(), server.URL, map[string]string{"X-Gophermart-Event": "order.processed"}, body,
+	)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, status)
+}
+
+func TestClient_SendServerError(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		),
+	)
+	defer server.Close()
+
+	status, err := newTestClient().Send(context.Background(), server.URL, nil, []byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, status)
+}
+
+func TestClient_SendNoRedirect(t *testing.T) {
+	target := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				t.Error("redirect must not be followed")
+			},
+		),
+	)
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer server.Close()
+
+	status, err := newTestClient().Send(context.Background(), server.URL, nil, []byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTemporaryRedirect, status)
+}
+
+func TestClient_SendUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	status, err := newTestClient().Send(context.Background(), url, nil, []byte(`{}`))
+	require.Error(t, err)
+	require.Zero(t, status)
+}
+
+func TestClient_SendForbiddenAddress(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+			},
+		),
+	)
+	defer server.Close()
+	client := NewClient(time.Second, logger.MyLogger{L: zap.NewNop()})
+
+	// Адрес проверяется после разрешения имени, поэтому имя, указывающее на внутренний адрес, тоже отклоняется
+	for _, url := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		status, err := client.Send(context.Background(), url, nil, []byte(`{}`))
+		require.Error(t, err, url)
+		require.Contains(t, err.Error(), "is not allowed")
+		require.Zero(t, status)
+	}
+	require.Zero(t, requests.Load())
+}
+
+func Test_publicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "8.8.8.8", want: true},
+		{addr: "2606:4700:4700::1111", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "fd00:ec2::254"},
+		{addr: "100.100.100.200"},
+		{addr: "0.0.0.0"},
+		{addr: "224.0.0.1"},
+		{addr: "255.255.255.255"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.addr, func(t *testing.T) {
+				require.Equal(t, tt.want, publicAddr(netip.MustParseAddr(tt.addr)))
+			},
+		)
+	}
+}
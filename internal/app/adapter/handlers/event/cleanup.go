@@ -0,0 +1,38 @@
+package event
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type CleanupHandler struct {
+	us        service.UserService
+	frequency time.Duration
+	log       logger.MyLogger
+}
+
+func NewCleanupHandler(us service.UserService, frequency time.Duration, log logger.MyLogger) *CleanupHandler {
+	return &CleanupHandler{us: us, frequency: frequency, log: log}
+}
+
+// Cleanup раз в frequency удаляет устаревшие счётчики и журнал попыток входа. Работает до закрытия stop
+// или отмены ctx.
+func (c CleanupHandler) Cleanup(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(c.frequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := c.us.CleanupLoginHistory(ctx); err != nil {
+			c.log.L.Error("failed to clean up login history", zap.Error(err))
+		}
+	}
+}
@@ -2,11 +2,10 @@ package event
 
 import (
 	"context"
-	"errors"
-	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
 	"go.uber.org/zap"
+	"sync"
 	"time"
 )
 
@@ -15,53 +14,66 @@ type FetchHandler struct {
 	orderService service.OrderService
 	frequency    time.Duration
 	workersCount int
+	batchSize    int
 	log          logger.MyLogger
 }
 
 func NewFetchHandler(
-	processor service.NewOrderProcessor, orderService service.OrderService, frequency time.Duration, workersCount int, log logger.MyLogger,
+	processor service.NewOrderProcessor, orderService service.OrderService, frequency time.Duration, workersCount int, batchSize int,
+	log logger.MyLogger,
 ) *FetchHandler {
-	return &FetchHandler{processor: processor, orderService: orderService, frequency: frequency, workersCount: workersCount, log: log}
+	return &FetchHandler{
+		processor: processor, orderService: orderService, frequency: frequency, workersCount: workersCount, batchSize: batchSize,
+		log: log,
+	}
 }
 
-func (f *FetchHandler) FetchOrderStatus(ctx context.Context) {
+// FetchOrderStatus опрашивает систему лояльности до закрытия stop или отмены ctx
+// и возвращается только после завершения всех запущенных запросов.
+// Ограничение частоты запросов при ответах 429 соблюдает клиент системы лояльности.
+func (f *FetchHandler) FetchOrderStatus(ctx context.Context, stop <-chan struct{}) {
 	ticker := time.NewTicker(f.frequency)
+	defer ticker.Stop()
 	//Количество потоков запросов к сервису лояльности
 	workers := make(chan struct{}, f.workersCount)
-	sleepSignal := make(chan int)
-	requestCtx, cancelRequests := context.WithCancel(ctx)
-	defer cancelRequests()
-	for range ticker.C {
-		orders, err := f.orderService.GetUnprocessedOrders(ctx)
-		if err != nil {
-			f.log.L.Error("failed to get unprocessed orders", zap.Error(err))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
-		if len(orders) == 0 {
-			continue
+		orders, err := f.orderService.ClaimUnprocessedOrders(ctx, f.batchSize)
+		if err != nil {
+			f.log.L.Error("failed to claim unprocessed orders", zap.Error(err))
 		}
 		for _, o := range orders {
+			claimed := o
+			//Запускаем получение данных из сервиса лояльности многопоточно
+			//"Занимаем" или ожидаем один из потоков
+			//Заказы, не отправленные до остановки, снова станут доступны после истечения блокировки
 			select {
-			case sleepTime := <-sleepSignal:
-				time.Sleep(time.Duration(sleepTime) * time.Second)
-			default:
-				orderNumber := o.Number
-				//Запускаем получение данных из сервиса лояльности многопоточно
-				//"Занимаем" или ожидаем один из потоков
-				workers <- struct{}{}
-				go func() {
-					err := f.processor.ProcessNewOrder(requestCtx, orderNumber)
-					if err != nil {
-						var tooManyRequests *clients.TooManyRequests
-						if errors.As(err, &tooManyRequests) {
-							cancelRequests()
-							sleepSignal <- tooManyRequests.RetryAfter
-						}
-						f.log.L.Error("failed to get order info", zap.Error(err))
-					}
-					//"Освобождаем" поток
-					<-workers
-				}()
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case workers <- struct{}{}:
 			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := f.processor.ProcessNewOrder(ctx, claimed.Number); err != nil {
+					f.log.L.Error("failed to get order info", zap.Error(err))
+				}
+				if err := f.orderService.ScheduleNextCheck(ctx, claimed); err != nil {
+					f.log.L.Error("failed to schedule next order check", zap.Error(err))
+				}
+				//"Освобождаем" поток
+				<-workers
+			}()
 		}
 	}
 }
@@ -5,7 +5,69 @@ import (
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/handlers"
 )
 
-func Subscribe(ctx context.Context, fetchHandler handlers.OrderFetchInfoHandler, updateHandler handlers.OrderUpdateHandler) {
-	go fetchHandler.FetchOrderStatus(ctx)
-	go updateHandler.UpdateStatusAndBalance(ctx)
+type Subscription struct {
+	fetchDone   chan struct{}
+	updateDone  chan struct{}
+	webhookDone chan struct{}
+	cleanupDone chan struct{}
+}
+
+// Subscribe запускает обработчики событий. После закрытия stop обработчики перестают брать новую работу
+// и доделывают начатую, а отмена ctx прерывает и её. Subscription позволяет дождаться завершения
+// обработчиков при остановке сервиса.
+func Subscribe(
+	ctx context.Context, stop <-chan struct{}, fetchHandler handlers.OrderFetchInfoHandler,
+	updateHandler handlers.OrderUpdateHandler, webhookHandler handlers.WebhookDeliveryHandler,
+	cleanupHandler handlers.CleanupHandler,
+) *Subscription {
+	s := &Subscription{
+		fetchDone:   make(chan struct{}),
+		updateDone:  make(chan struct{}),
+		webhookDone: make(chan struct{}),
+		cleanupDone: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.fetchDone)
+		fetchHandler.FetchOrderStatus(ctx, stop)
+	}()
+	go func() {
+		defer close(s.updateDone)
+		updateHandler.UpdateStatusAndBalance(ctx, stop)
+	}()
+	go func() {
+		defer close(s.webhookDone)
+		webhookHandler.DeliverWebhooks(ctx, stop)
+	}()
+	go func() {
+		defer close(s.cleanupDone)
+		cleanupHandler.Cleanup(ctx, stop)
+	}()
+
+	return s
+}
+
+func (s *Subscription) FetchDone() <-chan struct{} {
+	return s.fetchDone
+}
+
+func (s *Subscription) UpdateDone() <-chan struct{} {
+	return s.updateDone
+}
+
+func (s *Subscription) WebhookDone() <-chan struct{} {
+	return s.webhookDone
+}
+
+func (s *Subscription) CleanupDone() <-chan struct{} {
+	return s.cleanupDone
+}
+
+// stopped сообщает, что пора перестать брать новую работу
+func stopped(ctx context.Context, stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return ctx.Err() != nil
+	}
 }
@@ -2,7 +2,6 @@ package event
 
 import (
 	"context"
-	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
 	"go.uber.org/zap"
@@ -10,34 +9,37 @@ import (
 )
 
 type UpdateHandler struct {
-	orderInfosChannel <-chan clients.OrderLoyaltyInfo
-	os                service.OrderService
-	frequency         time.Duration
-	log               logger.MyLogger
+	os        service.OrderService
+	frequency time.Duration
+	batchSize int
+	log       logger.MyLogger
 }
 
-func NewUpdateHandler(
-	orderInfosChannel <-chan clients.OrderLoyaltyInfo, os service.OrderService, frequency time.Duration, log logger.MyLogger,
-) *UpdateHandler {
-	return &UpdateHandler{orderInfosChannel: orderInfosChannel, os: os, frequency: frequency, log: log}
+func NewUpdateHandler(os service.OrderService, frequency time.Duration, batchSize int, log logger.MyLogger) *UpdateHandler {
+	return &UpdateHandler{os: os, frequency: frequency, batchSize: batchSize, log: log}
 }
 
-func (u UpdateHandler) UpdateStatusAndBalance(ctx context.Context) {
+// UpdateStatusAndBalance раз в frequency применяет сохранённые ответы системы лояльности
+// пачками по batchSize, пока они не закончатся. Работает до закрытия stop или отмены ctx.
+func (u UpdateHandler) UpdateStatusAndBalance(ctx context.Context, stop <-chan struct{}) {
 	ticker := time.NewTicker(u.frequency)
-	infos := make(map[string]clients.OrderLoyaltyInfo)
+	defer ticker.Stop()
 	for {
 		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			errors := u.os.UpdateOrdersAndBalance(ctx, infos)
+		}
+		for !stopped(ctx, stop) {
+			processed, errors := u.os.UpdateOrdersAndBalance(ctx, u.batchSize)
 			if len(errors) > 0 {
 				u.log.L.Error("failed to update orders", zap.Errors("err", errors))
 			}
-			infos = make(map[string]clients.OrderLoyaltyInfo)
-		case info, ok := <-u.orderInfosChannel:
-			if !ok {
+			if processed < u.batchSize {
 				break
 			}
-			infos[info.Order] = info
 		}
 	}
 }
@@ -0,0 +1,45 @@
+package event
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+type WebhookHandler struct {
+	ws        service.WebhookService
+	frequency time.Duration
+	batchSize int
+	log       logger.MyLogger
+}
+
+func NewWebhookHandler(ws service.WebhookService, frequency time.Duration, batchSize int, log logger.MyLogger) *WebhookHandler {
+	return &WebhookHandler{ws: ws, frequency: frequency, batchSize: batchSize, log: log}
+}
+
+// DeliverWebhooks раз в frequency отправляет накопившиеся webhook пачками по batchSize,
+// пока они не закончатся. Работает до закрытия stop или отмены ctx.
+func (wh WebhookHandler) DeliverWebhooks(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(wh.frequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for !stopped(ctx, stop) {
+			sent, errors := wh.ws.DeliverDue(ctx, wh.batchSize)
+			if len(errors) > 0 {
+				wh.log.L.Error("failed to deliver webhooks", zap.Errors("err", errors))
+			}
+			if sent < wh.batchSize {
+				break
+			}
+		}
+	}
+}
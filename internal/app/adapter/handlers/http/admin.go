@@ -0,0 +1,261 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	domenuser "github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// AdminHandler ручки поддержки и администраторов. Права проверяются в роутере через auth.RequirePermission.
+type AdminHandler struct {
+	us  service.UserService
+	os  service.OrderService
+	bs  service.BalanceService
+	log logger.MyLogger
+}
+
+func NewAdminHandler(
+	us service.UserService, os service.OrderService, bs service.BalanceService, log logger.MyLogger,
+) *AdminHandler {
+	return &AdminHandler{us: us, os: os, bs: bs, log: log}
+}
+
+// adminUserResponse пользователь без хеша пароля
+type adminUserResponse struct {
+	ID    uuid.UUID      `json:"id"`
+	Login string         `json:"login"`
+	Role  domenuser.Role `json:"role"`
+}
+
+type adminTransactionResponse struct {
+	ID          uuid.UUID   `json:"id"`
+	OrderNumber string      `json:"order"`
+	Sum         money.Money `json:"sum"`
+	Type        string      `json:"type"`
+	Reason      string      `json:"reason,omitempty"`
+	ProcessedAt time.Time   `json:"processed_at"`
+}
+
+type adjustBalanceRequest struct {
+	Sum    money.Money `json:"sum"`
+	Reason string      `json:"reason"`
+}
+
+type setRoleRequest struct {
+	Role domenuser.Role `json:"role"`
+}
+
+// FindUser ищет пользователя по логину из параметра login
+func (a AdminHandler) FindUser(w http.ResponseWriter, r *http.Request) {
+	login := r.URL.Query().Get("login")
+	if login == "" {
+		http.Error(w, "login is required", http.StatusBadRequest)
+		return
+	}
+	u, err := a.us.FindByLogin(r.Context(), login)
+	if err != nil {
+		a.writeError(w, "failed to find user", err)
+		return
+	}
+	a.writeJSON(w, adminUserResponse{ID: u.ID, Login: u.Login, Role: u.Role})
+}
+
+func (a AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.userID(w, r)
+	if !ok {
+		return
+	}
+	u, err := a.us.GetUser(r.Context(), userID)
+	if err != nil {
+		a.writeError(w, "failed to get user", err)
+		return
+	}
+	a.writeJSON(w, adminUserResponse{ID: u.ID, Login: u.Login, Role: u.Role})
+}
+
+// SetRole меняет роль пользователя. Сервис отзывает его сессии, чтобы старая роль не действовала до истечения токенов
+func (a AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.userID(w, r)
+	if !ok {
+		return
+	}
+	var req setRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.log.L.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := a.us.SetRole(r.Context(), userID, req.Role); err != nil {
+		a.writeError(w, "failed to set role", err)
+		return
+	}
+	a.log.L.Info("user role changed", zap.String("user_id", userID.String()), zap.String("role", string(req.Role)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a AdminHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.userID(w, r)
+	if !ok {
+		return
+	}
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := a.os.GetUserOrders(r.Context(), userID, filter)
+	if err != nil {
+		a.writeError(w, "failed to get user orders", err)
+		return
+	}
+	setNextPageHeaders(w, r, page.NextCursor)
+	a.writeJSON(w, page.Orders)
+}
+
+func (a AdminHandler) GetUserTransactions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.userID(w, r)
+	if !ok {
+		return
+	}
+	transactions, err := a.bs.GetUserTransactions(r.Context(), userID)
+	if err != nil {
+		a.writeError(w, "failed to get user transactions", err)
+		return
+	}
+	resp := make([]adminTransactionResponse, len(transactions))
+	for i, t := range transactions {
+		resp[i] = adminTransactionResponse{
+			ID:          t.ID,
+			OrderNumber: t.OrderNumber,
+			Sum:         t.Sum,
+			Type:        t.Type,
+			Reason:      t.Reason,
+			ProcessedAt: t.ProcessedAt,
+		}
+	}
+	a.writeJSON(w, resp)
+}
+
+// AdjustBalance корректирует баланс пользователя от имени текущего сотрудника
+func (a AdminHandler) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.userID(w, r)
+	if !ok {
+		return
+	}
+	actorID, ok := auth.GetUserID(r)
+	if !ok {
+		a.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	var req adjustBalanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.log.L.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	t, err := a.bs.AdjustBalance(r.Context(), userID, req.Sum, req.Reason, actorID)
+	if err != nil {
+		a.writeError(w, "failed to adjust balance", err)
+		return
+	}
+	a.log.L.Info(
+		"balance adjusted", zap.String("user_id", userID.String()), zap.String("actor_id", actorID.String()),
+		zap.String("sum", t.Sum.String()),
+	)
+	a.writeJSONStatus(
+		w, http.StatusCreated, adminTransactionResponse{
+			ID:          t.ID,
+			Sum:         t.Sum,
+			Type:        t.Type,
+			Reason:      t.Reason,
+			ProcessedAt: t.ProcessedAt,
+		},
+	)
+}
+
+// RequeueOrder ставит заказ в очередь опроса системы лояльности вне расписания
+func (a AdminHandler) RequeueOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+	if err := a.os.RequeueOrder(r.Context(), number); err != nil {
+		a.writeError(w, "failed to requeue order", err)
+		return
+	}
+	a.log.L.Info("order requeued", zap.String("order", number))
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a AdminHandler) userID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func (a AdminHandler) writeError(w http.ResponseWriter, msg string, err error) {
+	a.log.L.Error(msg, zap.Error(err))
+	var errNoSuchUser *domenuser.NoSuchUser
+	var errNoSuchOrder *order.NoSuchOrder
+	var errInvalidRole *domenuser.InvalidRole
+	var errAlreadyFinal *order.AlreadyFinal
+	var errInvalidAdjustment *transaction.InvalidAdjustment
+	var errSelfAdjustment *transaction.SelfAdjustment
+	var errNotEnoughMoney *transaction.NotEnoughMoney
+	var errInvalidListFilter *order.InvalidListFilter
+	switch {
+	case errors.As(err, &errNoSuchUser), errors.As(err, &errNoSuchOrder):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.As(err, &errInvalidRole):
+		http.Error(w, "invalid role", http.StatusBadRequest)
+	case errors.As(err, &errAlreadyFinal):
+		http.Error(w, "order already has final status", http.StatusConflict)
+	case errors.As(err, &errInvalidAdjustment):
+		http.Error(w, errInvalidAdjustment.Message, http.StatusBadRequest)
+	case errors.As(err, &errSelfAdjustment):
+		http.Error(w, "adjusting own balance is forbidden", http.StatusForbidden)
+	case errors.As(err, &errInvalidListFilter):
+		http.Error(w, errInvalidListFilter.Message, http.StatusBadRequest)
+	case errors.As(err, &errNotEnoughMoney):
+		http.Error(w, "Not enough money", http.StatusPaymentRequired)
+	default:
+		if _, ok := err.(*service.NoData); ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+	}
+}
+
+func (a AdminHandler) writeJSON(w http.ResponseWriter, v any) {
+	a.writeJSONStatus(w, http.StatusOK, v)
+}
+
+func (a AdminHandler) writeJSONStatus(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		a.log.L.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(resp); err != nil {
+		a.log.L.Error("failed to make response", zap.Error(err))
+	}
+}
@@ -2,13 +2,20 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/gofrs/uuid"
 	"go.uber.org/zap"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type BalanceHandler struct {
@@ -20,9 +27,11 @@ func NewBalanceHandler(bs service.BalanceService, log logger.MyLogger) *BalanceH
 	return &BalanceHandler{bs: bs, log: log}
 }
 
+const maxIdempotencyKeyLength = 255
+
 type withdrawRequest struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order string      `json:"order"`
+	Sum   money.Money `json:"sum"`
 }
 
 func (b BalanceHandler) GetUserBalance(w http.ResponseWriter, r *http.Request) {
@@ -77,7 +86,12 @@ func (b BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
-	if err := b.bs.Withdraw(r.Context(), withdraw.Sum, withdraw.Order, userID); err != nil {
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+		return
+	}
+	if err := b.bs.Withdraw(r.Context(), withdraw.Sum, withdraw.Order, userID, idempotencyKey); err != nil {
 		b.log.L.Error("failed to process withdrawal", zap.Error(err))
 		if _, ok := err.(*order.InvalidFormat); ok {
 			http.Error(w, "Invalid order format", http.StatusUnprocessableEntity)
@@ -87,6 +101,14 @@ func (b BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Not enough money", http.StatusPaymentRequired)
 			return
 		}
+		if _, ok := err.(*transaction.AlreadyWithdrawn); ok {
+			http.Error(w, "Order already paid with another sum", http.StatusConflict)
+			return
+		}
+		if _, ok := err.(*transaction.IdempotencyKeyReused); ok {
+			http.Error(w, "Idempotency-Key already used for another request", http.StatusConflict)
+			return
+		}
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
@@ -127,3 +149,81 @@ func (b BalanceHandler) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// GetTransactions отдаёт историю начислений, списаний и корректировок с балансом после каждой операции.
+// Параметры: type (можно несколько, через запятую), from и to в RFC 3339, limit и cursor из next_cursor.
+func (b BalanceHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		b.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	filter, err := parseHistoryFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	history, err := b.bs.GetUserHistory(r.Context(), userID, filter)
+	if err != nil {
+		b.log.L.Error("failed to get transactions", zap.Error(err))
+		if _, ok := err.(*service.NoData); ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if _, ok := err.(*transaction.InvalidHistoryFilter); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(history)
+	if err != nil {
+		b.log.L.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(resp); err != nil {
+		b.log.L.Error("failed to make response", zap.Error(err))
+		return
+	}
+}
+
+func parseHistoryFilter(query url.Values) (transaction.HistoryFilter, error) {
+	filter := transaction.HistoryFilter{Limit: transaction.DefaultHistoryLimit}
+	for _, v := range query["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, strings.ToUpper(t))
+			}
+		}
+	}
+	var err error
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, errors.New("from must be in RFC 3339 format")
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, errors.New("to must be in RFC 3339 format")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("limit must be a number")
+		}
+	}
+	if v := query.Get("cursor"); v != "" {
+		if filter.Before, err = uuid.FromString(v); err != nil {
+			return filter, errors.New("invalid cursor")
+		}
+	}
+
+	return filter, nil
+}
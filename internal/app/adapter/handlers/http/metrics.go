@@ -0,0 +1,34 @@
+package http
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// hiddenMetrics стандартные переменные expvar: cmdline содержит аргументы запуска вместе с DSN и секретом
+// подписи токенов, memstats к работе сервиса не относится
+var hiddenMetrics = map[string]bool{
+	"cmdline":  true,
+	"memstats": true,
+}
+
+// metricsHandler отдаёт счётчики сервиса в формате expvar
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, "{")
+	first := true
+	expvar.Do(
+		func(kv expvar.KeyValue) {
+			if hiddenMetrics[kv.Key] {
+				return
+			}
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+			first = false
+			fmt.Fprintf(w, "\n%q: %s", kv.Key, kv.Value)
+		},
+	)
+	fmt.Fprint(w, "\n}\n")
+}
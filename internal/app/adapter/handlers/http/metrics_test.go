@@ -0,0 +1,23 @@
+package http
+
+import (
+	"encoding/json"
+	"expvar"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	expvar.NewInt("metrics_handler_test").Set(7)
+	w := httptest.NewRecorder()
+	metricsHandler(w, httptest.NewRequest(http.MethodGet, "/api/admin/metrics", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var metrics map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	require.Equal(t, "7", string(metrics["metrics_handler_test"]))
+	require.NotContains(t, metrics, "cmdline")
+	require.NotContains(t, metrics, "memstats")
+}
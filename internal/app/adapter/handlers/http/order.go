@@ -3,22 +3,32 @@ package http
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// eventsHeartbeat интервал комментариев, которые не дают прокси закрыть простаивающий поток событий
+const eventsHeartbeat = 15 * time.Second
+
 type OrderHandler struct {
-	os  service.OrderService
-	log logger.MyLogger
+	os     service.OrderService
+	events service.OrderEventSubscriber
+	log    logger.MyLogger
 }
 
-func NewOrderHandler(os service.OrderService, log logger.MyLogger) *OrderHandler {
-	return &OrderHandler{os: os, log: log}
+func NewOrderHandler(os service.OrderService, events service.OrderEventSubscriber, log logger.MyLogger) *OrderHandler {
+	return &OrderHandler{os: os, events: events, log: log}
 }
 
 func (oh OrderHandler) LoadOrder(w http.ResponseWriter, r *http.Request) {
@@ -64,6 +74,145 @@ func (oh OrderHandler) LoadOrder(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// maxBatchBodySize с запасом вмещает order.MaxBatchSize номеров в любом из форматов
+const maxBatchBodySize = 1 << 20
+
+// LoadOrders загружает пакет номеров: JSON массив строк (Content-Type: application/json)
+// или список по одному номеру в строке. Отвечает результатом по каждому номеру.
+func (oh OrderHandler) LoadOrders(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		oh.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	numbers, err := parseOrderNumbers(w, r)
+	if err != nil {
+		oh.log.L.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	results, err := oh.os.LoadOrders(r.Context(), numbers, userID)
+	if err != nil {
+		oh.log.L.Error("failed to load orders", zap.Error(err))
+		var errInvalidBatch *order.InvalidBatch
+		if errors.As(err, &errInvalidBatch) {
+			http.Error(w, errInvalidBatch.Message, http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(results)
+	if err != nil {
+		oh.log.L.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(resp); err != nil {
+		oh.log.L.Error("failed to make response", zap.Error(err))
+	}
+}
+
+// StreamEvents отдаёт поток Server-Sent Events с изменениями статусов и начислений заказов пользователя.
+// Клиент, передавший Last-Event-ID, сначала получает пропущенные события, затем новые.
+func (oh OrderHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		oh.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		oh.log.L.Error("streaming is not supported")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	var replayedUpTo int64
+	resume := r.Header.Get("Last-Event-ID")
+	if resume != "" {
+		var err error
+		if replayedUpTo, err = strconv.ParseInt(resume, 10, 64); err != nil || replayedUpTo < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Подписка оформляется до чтения пропущенных событий, чтобы не потерять изменения между ними.
+	// Номера событий идут в порядке фиксации, поэтому всё, что не новее последнего отправленного
+	// из истории, уже отправлено. Живые события публикуются параллельно и могут прийти не по порядку,
+	// поэтому с ними сравнивается только граница пропущенных, а не номер последнего отправленного.
+	events, unsubscribe := oh.events.Subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if resume != "" {
+		for {
+			missed, err := oh.os.GetUserOrderEvents(r.Context(), userID, replayedUpTo)
+			if err != nil {
+				oh.log.L.Error("failed to get missed order events", zap.Error(err))
+				return
+			}
+			for _, e := range missed {
+				if err := oh.writeEvent(w, e); err != nil {
+					return
+				}
+				replayedUpTo = e.Seq
+			}
+			flusher.Flush()
+			if len(missed) < order.MaxReplayEvents {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if e.Seq <= replayedUpTo {
+				continue
+			}
+			if err := oh.writeEvent(w, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func (oh OrderHandler) writeEvent(w io.Writer, e order.StatusEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		oh.log.L.Error("failed to marshal order event", zap.Error(err))
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", e.Seq, data)
+	return err
+}
+
+// GetUserOrders отдаёт страницу заказов пользователя. Параметры: status (можно несколько, через запятую),
+// from и to в RFC 3339, sort (uploaded_at или -uploaded_at, по умолчанию от новых к старым), limit и cursor.
+// Курсор следующей страницы передаётся в заголовках Link и X-Next-Cursor.
 func (oh OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 	if !ok {
@@ -71,7 +220,12 @@ func (oh OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
-	orders, err := oh.os.GetUserOrders(r.Context(), userID)
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := oh.os.GetUserOrders(r.Context(), userID, filter)
 	if err != nil {
 		oh.log.L.Error("failed to get user orders", zap.Error(err))
 		if _, ok := err.(*service.NoData); ok {
@@ -79,16 +233,21 @@ func (oh OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
+		if _, ok := err.(*order.InvalidListFilter); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
 
-	resp, err := json.Marshal(orders)
+	resp, err := json.Marshal(page.Orders)
 	if err != nil {
 		oh.log.L.Error("failed to marshal response", zap.Error(err))
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
+	setNextPageHeaders(w, r, page.NextCursor)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write(resp); err != nil {
@@ -97,3 +256,115 @@ func (oh OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// GetUserOrder отдаёт заказ пользователя с начислением и историей статусов.
+// Чужой заказ, как и несуществующий, не находится.
+func (oh OrderHandler) GetUserOrder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		oh.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	details, err := oh.os.GetUserOrder(r.Context(), userID, chi.URLParam(r, "number"))
+	if err != nil {
+		var errNoSuchOrder *order.NoSuchOrder
+		if errors.As(err, &errNoSuchOrder) {
+			http.Error(w, "order not found", http.StatusNotFound)
+			return
+		}
+		oh.log.L.Error("failed to get user order", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(details)
+	if err != nil {
+		oh.log.L.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(resp); err != nil {
+		oh.log.L.Error("failed to make response", zap.Error(err))
+	}
+}
+
+func parseOrderFilter(query url.Values) (order.ListFilter, error) {
+	filter := order.ListFilter{Limit: order.DefaultListLimit}
+	for _, v := range query["status"] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				filter.Statuses = append(filter.Statuses, strings.ToUpper(s))
+			}
+		}
+	}
+	var err error
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, errors.New("from must be in RFC 3339 format")
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, errors.New("to must be in RFC 3339 format")
+		}
+	}
+	switch query.Get("sort") {
+	case "", "-uploaded_at":
+	case "uploaded_at":
+		filter.Ascending = true
+	default:
+		return filter, errors.New("sort must be uploaded_at or -uploaded_at")
+	}
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("limit must be a number")
+		}
+	}
+	if v := query.Get("cursor"); v != "" {
+		c, err := order.DecodeCursor(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = &c
+	}
+
+	return filter, nil
+}
+
+// setNextPageHeaders передаёт курсор следующей страницы в заголовке X-Next-Cursor и ссылку на неё в Link
+func setNextPageHeaders(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	query := r.URL.Query()
+	query.Set("cursor", nextCursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	w.Header().Set("Link", "<"+next.String()+`>; rel="next"`)
+	w.Header().Set("X-Next-Cursor", nextCursor)
+}
+
+func parseOrderNumbers(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBodySize))
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	if strings.TrimSpace(mediaType) == "application/json" {
+		var numbers []string
+		if err := json.Unmarshal(body, &numbers); err != nil {
+			return nil, err
+		}
+		return numbers, nil
+	}
+	var numbers []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if number := strings.TrimSpace(line); number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+
+	return numbers, nil
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/handlers"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/compress"
@@ -10,39 +11,86 @@ import (
 	"time"
 )
 
-func GetRouter(userHandler handlers.UserHandler, orderHandler handlers.OrderHandler, balanceHandler handlers.BalanceHandler) http.Handler {
+func GetRouter(
+	authMiddleware func(http.Handler) http.Handler, userHandler handlers.UserHandler, orderHandler handlers.OrderHandler,
+	balanceHandler handlers.BalanceHandler, webhookHandler handlers.WebhookHandler, adminHandler handlers.AdminHandler,
+) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(100 * time.Second))
 	r.Use(compress.GzipMiddleware)
+	// Поток событий открыт, пока подключён клиент, поэтому ограничение времени ответа действует на остальные ручки
+	timeout := middleware.Timeout(100 * time.Second)
 
-	r.Route(
+	r.With(timeout).Route(
 		"/api/user", func(r chi.Router) {
 			r.Post("/register", userHandler.Register)
 			r.Post("/login", userHandler.Login)
+			r.Post("/refresh", userHandler.Refresh)
+			r.Group(
+				func(r chi.Router) {
+					r.Use(authMiddleware)
+					r.Post("/logout", userHandler.Logout)
+					r.Post("/logout/all", userHandler.LogoutAll)
+					r.Post("/password", userHandler.ChangePassword)
+				},
+			)
 		},
 	)
-	r.Route(
+	r.With(timeout).Route(
 		"/api/user/orders", func(r chi.Router) {
-			r.Use(auth.Middleware)
+			r.Use(authMiddleware)
 			r.Post("/", orderHandler.LoadOrder)
+			r.Post("/batch", orderHandler.LoadOrders)
 			r.Get("/", orderHandler.GetUserOrders)
+			r.Get("/{number}", orderHandler.GetUserOrder)
 		},
 	)
-	r.Route(
+	r.With(timeout).Route(
 		"/api/user/balance", func(r chi.Router) {
-			r.Use(auth.Middleware)
+			r.Use(authMiddleware)
 			r.Get("/", balanceHandler.GetUserBalance)
 			r.Post("/withdraw", balanceHandler.Withdraw)
 		},
 	)
-	r.Route(
+	r.With(timeout).Route(
 		"/api/user/withdrawals", func(r chi.Router) {
-			r.Use(auth.Middleware)
+			r.Use(authMiddleware)
 			r.Get("/", balanceHandler.GetWithdrawals)
 		},
 	)
+	r.With(timeout).Route(
+		"/api/user/transactions", func(r chi.Router) {
+			r.Use(authMiddleware)
+			r.Get("/", balanceHandler.GetTransactions)
+		},
+	)
+	r.With(timeout).Route(
+		"/api/user/webhooks", func(r chi.Router) {
+			r.Use(authMiddleware)
+			r.Post("/", webhookHandler.CreateEndpoint)
+			r.Get("/", webhookHandler.GetEndpoints)
+			r.Delete("/{id}", webhookHandler.DeleteEndpoint)
+			r.Get("/{id}/deliveries", webhookHandler.GetDeliveries)
+		},
+	)
+	r.With(timeout).Route(
+		"/api/admin", func(r chi.Router) {
+			r.Use(authMiddleware)
+			r.With(auth.RequirePermission(user.PermissionViewUsers)).Get("/users", adminHandler.FindUser)
+			r.With(auth.RequirePermission(user.PermissionViewUsers)).Get("/users/{id}", adminHandler.GetUser)
+			r.With(auth.RequirePermission(user.PermissionManageRoles)).Put("/users/{id}/role", adminHandler.SetRole)
+			r.With(auth.RequirePermission(user.PermissionViewOrders)).Get("/users/{id}/orders", adminHandler.GetUserOrders)
+			r.With(auth.RequirePermission(user.PermissionViewTransactions)).
+				Get("/users/{id}/transactions", adminHandler.GetUserTransactions)
+			r.With(auth.RequirePermission(user.PermissionAdjustBalance)).
+				Post("/users/{id}/adjustments", adminHandler.AdjustBalance)
+			r.With(auth.RequirePermission(user.PermissionRequeueOrders)).
+				Post("/orders/{number}/requeue", adminHandler.RequeueOrder)
+			r.With(auth.RequirePermission(user.PermissionViewMetrics)).Get("/metrics", metricsHandler)
+		},
+	)
+	r.With(authMiddleware).Get("/api/user/orders/events", orderHandler.StreamEvents)
 
 	return r
 }
@@ -2,21 +2,37 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/session"
 	domenuser "github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
 	"go.uber.org/zap"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	refreshTokenCookie = "refresh_token"
+	// refreshTokenPath refresh токен нужен только ручкам /api/user/refresh и /api/user/logout
+	refreshTokenPath = "/api/user"
 )
 
 type UserHandler struct {
-	us  service.UserService
-	log logger.MyLogger
+	us      service.UserService
+	ss      service.SessionService
+	cookies auth.CookieConfig
+	log     logger.MyLogger
 }
 
-func NewUserHandler(us service.UserService, log logger.MyLogger) *UserHandler {
-	return &UserHandler{us: us, log: log}
+func NewUserHandler(
+	us service.UserService, ss service.SessionService, cookies auth.CookieConfig, log logger.MyLogger,
+) *UserHandler {
+	return &UserHandler{us: us, ss: ss, cookies: cookies, log: log}
 }
 
 type userCreds struct {
@@ -24,6 +40,26 @@ type userCreds struct {
 	Password string `json:"password"`
 }
 
+type changePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type validationErrorResponse struct {
+	Errors []domenuser.Violation `json:"errors"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokensResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
 func (u UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	creds := userCreds{}
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
@@ -39,26 +75,22 @@ func (u UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "internal server error occurred", http.StatusConflict)
 			return
 		}
+		if invalid, ok := err.(*domenuser.InvalidCredentials); ok {
+			u.writeViolations(w, invalid.Violations)
+			return
+		}
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
 
-	tokenString, err := auth.GenerateJWT(user.ID)
+	tokens, err := u.ss.Create(r.Context(), user.ID)
 	if err != nil {
-		u.log.L.Error("failed to generate auth token", zap.Error(err))
+		u.log.L.Error("failed to create session", zap.Error(err))
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:  "token",
-		Value: tokenString,
-		Path:  "/",
-	}
-	http.SetCookie(
-		w, cookie,
-	)
-	w.WriteHeader(http.StatusOK)
+	u.writeTokens(w, tokens)
 }
 
 func (u UserHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -69,31 +101,212 @@ func (u UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := u.us.Login(r.Context(), creds.Login, creds.Password)
+	user, err := u.us.Login(r.Context(), creds.Login, creds.Password, clientIP(r))
 	if err != nil {
 		u.log.L.Error("failed to login user", zap.Error(err))
 		if _, ok := err.(*domenuser.IncorrectLoginOrPassword); ok {
 			http.Error(w, "internal server error occurred", http.StatusUnauthorized)
 			return
 		}
+		if locked, ok := err.(*domenuser.LoginLocked); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			http.Error(w, "too many login attempts", http.StatusTooManyRequests)
+			return
+		}
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
 
-	tokenString, err := auth.GenerateJWT(user.ID)
+	tokens, err := u.ss.Create(r.Context(), user.ID)
 	if err != nil {
-		u.log.L.Error("failed to generate auth token", zap.Error(err))
+		u.log.L.Error("failed to create session", zap.Error(err))
 		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:  "token",
-		Value: tokenString,
-		Path:  "/",
+	u.writeTokens(w, tokens)
+}
+
+// ChangePassword меняет пароль, отзывает все сессии пользователя и открывает новую для текущего клиента
+func (u UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	req := changePasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		u.log.L.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "Can not parse request", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := u.us.ChangePassword(r.Context(), userID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		if _, ok := err.(*domenuser.WrongPassword); ok {
+			http.Error(w, "wrong password", http.StatusForbidden)
+			return
+		}
+		if invalid, ok := err.(*domenuser.InvalidCredentials); ok {
+			u.writeViolations(w, invalid.Violations)
+			return
+		}
+		u.log.L.Error("failed to change password", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+
+	u.writeTokens(w, tokens)
+}
+
+func (u UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	// Браузер присылает refresh токен в cookie, остальные клиенты в теле запроса
+	var refreshToken string
+	if c, err := r.Cookie(refreshTokenCookie); err == nil && c.Value != "" {
+		if !u.cookies.CheckCSRF(r) {
+			http.Error(w, "CSRF token mismatch", http.StatusForbidden)
+			return
+		}
+		refreshToken = c.Value
+	} else {
+		req := refreshRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+			http.Error(w, "refresh token is required", http.StatusUnauthorized)
+			return
+		}
+		refreshToken = req.RefreshToken
+	}
+
+	tokens, err := u.ss.Refresh(r.Context(), refreshToken)
+	if err != nil {
+		var invalid session.InvalidRefreshToken
+		var reused session.RefreshTokenReused
+		switch {
+		case errors.As(err, &invalid):
+			u.clearTokenCookies(w)
+			http.Error(w, "refresh token is invalid", http.StatusUnauthorized)
+		case errors.As(err, &reused):
+			u.log.L.Warn("refresh token reused", zap.Error(err))
+			u.clearTokenCookies(w)
+			http.Error(w, "refresh token is invalid", http.StatusUnauthorized)
+		default:
+			u.log.L.Error("failed to refresh session", zap.Error(err))
+			http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		}
+		return
 	}
+
+	u.writeTokens(w, tokens)
+}
+
+func (u UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := auth.GetSessionID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := u.ss.Logout(r.Context(), sessionID); err != nil {
+		u.log.L.Error("failed to revoke session", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+
+	u.clearTokenCookies(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (u UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := u.ss.LogoutAll(r.Context(), userID); err != nil {
+		u.log.L.Error("failed to revoke user sessions", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+
+	u.clearTokenCookies(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeTokens отдаёт токены в cookie для браузера, а также в заголовке Authorization и теле ответа
+// для клиентов, которые передают токен в заголовке Authorization: Bearer
+func (u UserHandler) writeTokens(w http.ResponseWriter, tokens service.Tokens) {
 	http.SetCookie(
-		w, cookie,
+		w, u.cookie(auth.AccessTokenCookie, tokens.AccessToken, "/", tokens.AccessExpiresAt, true),
 	)
+	http.SetCookie(
+		w, u.cookie(refreshTokenCookie, tokens.RefreshToken, refreshTokenPath, tokens.RefreshExpiresAt, true),
+	)
+	// CSRF cookie выдаётся вместе с cookie токенов всегда, чтобы клиенту не приходилось знать,
+	// включена ли проверка. Значение читается скриптом страницы и повторяется в заголовке X-CSRF-Token
+	csrfToken, err := auth.NewCSRFToken()
+	if err != nil {
+		u.log.L.Error("failed to generate CSRF token", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, u.cookie(auth.CSRFCookie, csrfToken, "/", tokens.RefreshExpiresAt, false))
+	w.Header().Set(auth.CSRFHeader, csrfToken)
+	w.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(
+		tokensResponse{
+			AccessToken:  tokens.AccessToken,
+			TokenType:    "Bearer",
+			ExpiresIn:    int64(time.Until(tokens.AccessExpiresAt).Seconds()),
+			RefreshToken: tokens.RefreshToken,
+		},
+	)
+	if err != nil {
+		u.log.L.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+func (u UserHandler) writeViolations(w http.ResponseWriter, violations []domenuser.Violation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(w).Encode(validationErrorResponse{Errors: violations}); err != nil {
+		u.log.L.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+func (u UserHandler) clearTokenCookies(w http.ResponseWriter) {
+	for _, c := range []*http.Cookie{
+		u.cookie(auth.AccessTokenCookie, "", "/", time.Unix(0, 0), true),
+		u.cookie(refreshTokenCookie, "", refreshTokenPath, time.Unix(0, 0), true),
+		u.cookie(auth.CSRFCookie, "", "/", time.Unix(0, 0), false),
+	} {
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+	}
+}
+
+// clientIP адрес соединения. Заголовки X-Forwarded-For и X-Real-IP не учитываются:
+// их подделка позволила бы обойти ограничение попыток входа по IP
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (u UserHandler) cookie(name, value, path string, expires time.Time, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   u.cookies.Domain,
+		Expires:  expires,
+		Secure:   u.cookies.Secure,
+		HttpOnly: httpOnly,
+		SameSite: u.cookies.SameSite,
+	}
 }
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type WebhookHandler struct {
+	ws  service.WebhookService
+	log logger.MyLogger
+}
+
+func NewWebhookHandler(ws service.WebhookService, log logger.MyLogger) *WebhookHandler {
+	return &WebhookHandler{ws: ws, log: log}
+}
+
+type createEndpointRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// createEndpointResponse секрет отдаётся только при создании адреса
+type createEndpointResponse struct {
+	webhook.Endpoint
+	Secret string `json:"secret"`
+}
+
+func (wh WebhookHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		wh.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	var req createEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		wh.log.L.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	endpoint, err := wh.ws.CreateEndpoint(r.Context(), userID, req.URL, req.Secret, req.Events)
+	if err != nil {
+		wh.writeError(w, "failed to create webhook endpoint", err)
+		return
+	}
+	wh.writeJSON(w, http.StatusCreated, createEndpointResponse{Endpoint: endpoint, Secret: endpoint.Secret})
+}
+
+func (wh WebhookHandler) GetEndpoints(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		wh.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	endpoints, err := wh.ws.GetEndpoints(r.Context(), userID)
+	if err != nil {
+		wh.writeError(w, "failed to get webhook endpoints", err)
+		return
+	}
+	wh.writeJSON(w, http.StatusOK, endpoints)
+}
+
+func (wh WebhookHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, endpointID, ok := wh.endpoint(w, r)
+	if !ok {
+		return
+	}
+	if err := wh.ws.DeleteEndpoint(r.Context(), userID, endpointID); err != nil {
+		wh.writeError(w, "failed to delete webhook endpoint", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries журнал последних доставок на адрес: статус, число попыток, код ответа и ошибка последней попытки
+func (wh WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, endpointID, ok := wh.endpoint(w, r)
+	if !ok {
+		return
+	}
+	deliveries, err := wh.ws.GetDeliveries(r.Context(), userID, endpointID)
+	if err != nil {
+		wh.writeError(w, "failed to get webhook deliveries", err)
+		return
+	}
+	wh.writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (wh WebhookHandler) endpoint(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		wh.log.L.Error("failed to get user")
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return uuid.Nil, uuid.Nil, false
+	}
+	endpointID, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid webhook id", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, endpointID, true
+}
+
+func (wh WebhookHandler) writeError(w http.ResponseWriter, msg string, err error) {
+	wh.log.L.Error(msg, zap.Error(err))
+	var errInvalidEndpoint *webhook.InvalidEndpoint
+	var errNoSuchEndpoint *webhook.NoSuchEndpoint
+	switch {
+	case errors.As(err, &errInvalidEndpoint):
+		http.Error(w, errInvalidEndpoint.Message, http.StatusBadRequest)
+	case errors.As(err, &errNoSuchEndpoint):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+	}
+}
+
+func (wh WebhookHandler) writeJSON(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		wh.log.L.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "internal server error occurred", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(resp); err != nil {
+		wh.log.L.Error("failed to make response", zap.Error(err))
+	}
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"sync"
+	"time"
+)
+
+type loginAttemptsEntry struct {
+	attempts  user.LoginAttempts
+	expiresAt time.Time
+}
+
+// LoginAttemptStore хранит счётчики попыток входа в памяти процесса,
+// поэтому при нескольких экземплярах сервиса лимиты считаются для каждого отдельно
+type LoginAttemptStore struct {
+	mu        sync.Mutex
+	entries   map[string]loginAttemptsEntry
+	lastSweep time.Time
+}
+
+func NewLoginAttemptStore() *LoginAttemptStore {
+	return &LoginAttemptStore{entries: make(map[string]loginAttemptsEntry)}
+}
+
+func (s *LoginAttemptStore) GetAttempts(_ context.Context, key string) (user.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return user.LoginAttempts{}, nil
+	}
+
+	return entry.attempts, nil
+}
+
+func (s *LoginAttemptStore) TryAcquire(
+	_ context.Context, key string, lockout user.Lockout, at time.Time, ttl time.Duration,
+) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now, ttl)
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		entry = loginAttemptsEntry{}
+	}
+	if retryAfter := lockout.Remaining(entry.attempts, at); retryAfter > 0 {
+		return retryAfter, nil
+	}
+	entry.attempts.Failures++
+	entry.attempts.LastFailureAt = at
+	entry.expiresAt = at.Add(ttl)
+	s.entries[key] = entry
+
+	return 0, nil
+}
+
+func (s *LoginAttemptStore) ReleaseAttempt(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	entry.attempts.Failures--
+	if entry.attempts.Failures <= 0 {
+		delete(s.entries, key)
+		return nil
+	}
+	s.entries[key] = entry
+
+	return nil
+}
+
+func (s *LoginAttemptStore) ResetAttempts(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+
+	return nil
+}
+
+func (s *LoginAttemptStore) DeleteExpired(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteExpired(before)
+
+	return nil
+}
+
+// sweep удаляет истёкшие счётчики не чаще раза в ttl, чтобы перебор логинов не занимал память бесконечно
+func (s *LoginAttemptStore) sweep(now time.Time, ttl time.Duration) {
+	if now.Sub(s.lastSweep) < ttl {
+		return
+	}
+	s.deleteExpired(now)
+	s.lastSweep = now
+}
+
+func (s *LoginAttemptStore) deleteExpired(before time.Time) {
+	for key, entry := range s.entries {
+		if !before.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
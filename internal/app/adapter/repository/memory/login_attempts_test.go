@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testLockout = user.Lockout{FreeAttempts: 3, Base: time.Second, Max: time.Minute}
+
+func TestLoginAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewLoginAttemptStore()
+	now := time.Now()
+
+	for i := 1; i <= 3; i++ {
+		retryAfter, err := s.TryAcquire(ctx, "login:a", testLockout, now, time.Hour)
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+	}
+	attempts, err := s.GetAttempts(ctx, "login:a")
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts.Failures)
+	require.Equal(t, now, attempts.LastFailureAt)
+
+	// Заблокированная попытка не засчитывается
+	retryAfter, err := s.TryAcquire(ctx, "login:a", testLockout, now, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, time.Second, retryAfter)
+	attempts, err = s.GetAttempts(ctx, "login:a")
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts.Failures)
+
+	// После истечения блокировки попытка снова разрешена
+	retryAfter, err = s.TryAcquire(ctx, "login:a", testLockout, now.Add(time.Second), time.Hour)
+	require.NoError(t, err)
+	require.Zero(t, retryAfter)
+
+	// Счётчики разных ключей независимы
+	attempts, err = s.GetAttempts(ctx, "login:b")
+	require.NoError(t, err)
+	require.Zero(t, attempts.Failures)
+
+	require.NoError(t, s.ReleaseAttempt(ctx, "login:a"))
+	attempts, err = s.GetAttempts(ctx, "login:a")
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts.Failures)
+
+	require.NoError(t, s.ResetAttempts(ctx, "login:a"))
+	attempts, err = s.GetAttempts(ctx, "login:a")
+	require.NoError(t, err)
+	require.Zero(t, attempts.Failures)
+
+	// Истёкший счётчик начинается заново
+	_, err = s.TryAcquire(ctx, "ip:1", testLockout, now.Add(-2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	attempts, err = s.GetAttempts(ctx, "ip:1")
+	require.NoError(t, err)
+	require.Zero(t, attempts.Failures)
+	_, err = s.TryAcquire(ctx, "ip:1", testLockout, now, time.Hour)
+	require.NoError(t, err)
+	attempts, err = s.GetAttempts(ctx, "ip:1")
+	require.NoError(t, err)
+	require.Equal(t, 1, attempts.Failures)
+}
+
+func TestLoginAttemptStore_ConcurrentTryAcquire(t *testing.T) {
+	ctx := context.Background()
+	s := NewLoginAttemptStore()
+	now := time.Now()
+
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retryAfter, err := s.TryAcquire(ctx, "login:a", testLockout, now, time.Hour)
+			if err == nil && retryAfter == 0 {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.EqualValues(t, testLockout.FreeAttempts, acquired.Load())
+}
+
+func TestLoginAttemptStore_DeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	s := NewLoginAttemptStore()
+	now := time.Now()
+
+	_, err := s.TryAcquire(ctx, "login:a", testLockout, now, time.Hour)
+	require.NoError(t, err)
+	_, err = s.TryAcquire(ctx, "login:b", testLockout, now, 2*time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteExpired(ctx, now.Add(time.Hour)))
+	require.NotContains(t, s.entries, "login:a")
+	require.Contains(t, s.entries, "login:b")
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+type AccrualResultRepository struct {
+	client *postgres.Client
+}
+
+func NewAccrualResultRepository(client *postgres.Client) *AccrualResultRepository {
+	return &AccrualResultRepository{client: client}
+}
+
+// SaveResult сохраняет последний ответ по заказу. Повтор уже сохранённого ответа ничего не меняет,
+// новый ответ снова ставится в очередь на применение.
+func (ar AccrualResultRepository) SaveResult(ctx context.Context, result order.AccrualResult) error {
+	_, err := ar.client.NewInsert().Model(&result).
+		On(`CONFLICT ("order") DO UPDATE`).
+		Set("status = EXCLUDED.status").
+		Set("accrual = EXCLUDED.accrual").
+		Set("received_at = EXCLUDED.received_at").
+		Set("processed_at = NULL").
+		Where("ar.status <> EXCLUDED.status OR ar.accrual <> EXCLUDED.accrual").
+		Exec(ctx)
+	return translateError(err)
+}
+
+func (ar AccrualResultRepository) ClaimPending(ctx context.Context, limit int, tx bun.IDB) ([]order.AccrualResult, error) {
+	if tx == nil {
+		tx = ar.client
+	}
+	results := make([]order.AccrualResult, 0)
+	err := tx.NewSelect().Model(&results).
+		Where("processed_at IS NULL").
+		OrderExpr("received_at").
+		Limit(limit).
+		For("UPDATE SKIP LOCKED").
+		Scan(ctx)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return results, nil
+		}
+		return results, translateError(err)
+	}
+
+	return results, nil
+}
+
+func (ar AccrualResultRepository) MarkProcessed(ctx context.Context, orderNumbers []string, tx bun.IDB) error {
+	if tx == nil {
+		tx = ar.client
+	}
+	_, err := tx.NewUpdate().Model((*order.AccrualResult)(nil)).
+		Set("processed_at = ?", time.Now()).
+		Where(`"order" IN (?)`, bun.In(orderNumbers)).
+		Exec(ctx)
+	return translateError(err)
+}
@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/audit"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/uptrace/bun"
+)
+
+type AuditLogRepository struct {
+	client *postgres.Client
+}
+
+func NewAuditLogRepository(client *postgres.Client) *AuditLogRepository {
+	return &AuditLogRepository{client: client}
+}
+
+func (ar AuditLogRepository) Record(ctx context.Context, entry audit.Entry, tx bun.IDB) error {
+	if tx == nil {
+		tx = ar.client
+	}
+	_, err := tx.NewInsert().Model(&entry).Exec(ctx)
+	return translateError(err)
+}
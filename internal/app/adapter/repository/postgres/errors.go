@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+)
+
+// Коды ошибок PostgreSQL, https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	codeUniqueViolation = "23505"
+)
+
+// pgError реализуется pgdriver.Error
+type pgError interface {
+	error
+	Field(k byte) string
+}
+
+// translateError заменяет ошибки драйвера на ошибки из core/ports/adapters/repository,
+// чтобы сервисы не зависели от кодов PostgreSQL
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.NoResultError{}
+	}
+	var pgErr pgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	if pgErr.Field('C') == codeUniqueViolation {
+		return repository.UniqueViolation{Constraint: pgErr.Field('n')}
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// fakePgError повторяет поля pgdriver.Error, который нельзя создать вне драйвера
+type fakePgError map[byte]string
+
+func (e fakePgError) Field(k byte) string {
+	return e[k]
+}
+
+func (e fakePgError) Error() string {
+	return e['M']
+}
+
+func Test_translateError(t *testing.T) {
+	otherErr := errors.New("connection refused")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "Test_1. Нет ошибки",
+			err:  nil,
+			want: nil,
+		},
+		{
+			name: "Test_2. Нет строк",
+			err:  fmt.Errorf("scan: %w", sql.ErrNoRows),
+			want: repository.NoResultError{},
+		},
+		{
+			name: "Test_3. Нарушение уникальности",
+			err:  fakePgError{'C': "23505", 'n': "users_login_key", 'M': "duplicate key"},
+			want: repository.UniqueViolation{Constraint: "users_login_key"},
+		},
+		{
+			name: "Test_4. Прочие ошибки не меняются",
+			err:  otherErr,
+			want: otherErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				require.Equal(t, tt.want, translateError(tt.err))
+			},
+		)
+	}
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+type loginAttemptsRow struct {
+	bun.BaseModel `bun:"table:login_attempts,alias:la"`
+
+	Key           string    `bun:"key,pk"`
+	Failures      int       `bun:"failures,notnull"`
+	LastFailureAt time.Time `bun:"last_failure_at,notnull"`
+	ExpiresAt     time.Time `bun:"expires_at,notnull"`
+}
+
+// LoginAttemptRepository хранит счётчики попыток входа в базе, поэтому блокировка действует
+// на всех экземплярах сервиса и не сбрасывается при перезапуске
+type LoginAttemptRepository struct {
+	client *postgres.Client
+}
+
+func NewLoginAttemptRepository(client *postgres.Client) *LoginAttemptRepository {
+	return &LoginAttemptRepository{client: client}
+}
+
+func (lr LoginAttemptRepository) GetAttempts(ctx context.Context, key string) (user.LoginAttempts, error) {
+	row := loginAttemptsRow{}
+	err := lr.client.NewSelect().Model(&row).
+		Where("key = ?", key).
+		Where("expires_at > ?", time.Now()).
+		Scan(ctx)
+	if err != nil {
+		if err = translateError(err); errors.Is(err, repository.NoResultError{}) {
+			return user.LoginAttempts{}, nil
+		}
+		return user.LoginAttempts{}, err
+	}
+
+	return user.LoginAttempts{Failures: row.Failures, LastFailureAt: row.LastFailureAt}, nil
+}
+
+// TryAcquire блокирует строку счётчика до конца транзакции, поэтому параллельные попытки с разных
+// экземпляров сервиса проверяют блокировку по очереди
+func (lr LoginAttemptRepository) TryAcquire(
+	ctx context.Context, key string, lockout user.Lockout, at time.Time, ttl time.Duration,
+) (time.Duration, error) {
+	var retryAfter time.Duration
+	err := lr.client.RunInTx(
+		ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			row := loginAttemptsRow{Key: key, LastFailureAt: at, ExpiresAt: at}
+			if _, err := tx.NewInsert().Model(&row).On("CONFLICT (key) DO NOTHING").Exec(ctx); err != nil {
+				return err
+			}
+			if err := tx.NewSelect().Model(&row).WherePK().For("UPDATE").Scan(ctx); err != nil {
+				return err
+			}
+			var attempts user.LoginAttempts
+			if time.Now().Before(row.ExpiresAt) {
+				attempts = user.LoginAttempts{Failures: row.Failures, LastFailureAt: row.LastFailureAt}
+			}
+			if retryAfter = lockout.Remaining(attempts, at); retryAfter > 0 {
+				return nil
+			}
+			_, err := tx.NewUpdate().Model((*loginAttemptsRow)(nil)).
+				Set("failures = ?", attempts.Failures+1).
+				Set("last_failure_at = ?", at).
+				Set("expires_at = ?", at.Add(ttl)).
+				Where("key = ?", key).
+				Exec(ctx)
+			return err
+		},
+	)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	return retryAfter, nil
+}
+
+func (lr LoginAttemptRepository) ReleaseAttempt(ctx context.Context, key string) error {
+	_, err := lr.client.NewUpdate().Model((*loginAttemptsRow)(nil)).
+		Set("failures = failures - 1").
+		Where("key = ?", key).
+		Where("failures > 0").
+		Exec(ctx)
+	return translateError(err)
+}
+
+func (lr LoginAttemptRepository) ResetAttempts(ctx context.Context, key string) error {
+	_, err := lr.client.NewDelete().Model((*loginAttemptsRow)(nil)).Where("key = ?", key).Exec(ctx)
+	return translateError(err)
+}
+
+// DeleteExpired удаляет счётчики, истёкшие к моменту before
+func (lr LoginAttemptRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	_, err := lr.client.NewDelete().Model((*loginAttemptsRow)(nil)).Where("expires_at <= ?", before).Exec(ctx)
+	return translateError(err)
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptRepository_ConcurrentTryAcquire(t *testing.T) {
+	const attempts = 20
+	client := newTestClient(t)
+	ctx := context.Background()
+	// Два экземпляра сервиса с общей базой делят один счётчик
+	stores := []*LoginAttemptRepository{NewLoginAttemptRepository(client), NewLoginAttemptRepository(client)}
+	lockout := user.Lockout{FreeAttempts: 3, Base: time.Minute, Max: time.Hour}
+	id, _ := uuid.NewV7()
+	key := "login:" + id.String()
+	now := time.Now()
+
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(store *LoginAttemptRepository) {
+			defer wg.Done()
+			retryAfter, err := store.TryAcquire(ctx, key, lockout, now, time.Hour)
+			if err != nil {
+				t.Errorf("TryAcquire() error = %v", err)
+				return
+			}
+			if retryAfter == 0 {
+				acquired.Add(1)
+			}
+		}(stores[i%len(stores)])
+	}
+	wg.Wait()
+	require.EqualValues(t, lockout.FreeAttempts, acquired.Load())
+
+	got, err := stores[0].GetAttempts(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, lockout.FreeAttempts, got.Failures)
+
+	require.NoError(t, stores[0].ReleaseAttempt(ctx, key))
+	got, err = stores[0].GetAttempts(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, lockout.FreeAttempts-1, got.Failures)
+
+	require.NoError(t, stores[0].DeleteExpired(ctx, now.Add(2*time.Hour)))
+	got, err = stores[0].GetAttempts(ctx, key)
+	require.NoError(t, err)
+	require.Zero(t, got.Failures)
+}
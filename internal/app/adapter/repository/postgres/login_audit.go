@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"time"
+)
+
+type LoginAuditRepository struct {
+	client *postgres.Client
+}
+
+func NewLoginAuditRepository(client *postgres.Client) *LoginAuditRepository {
+	return &LoginAuditRepository{client: client}
+}
+
+func (lr LoginAuditRepository) RecordFailure(ctx context.Context, failure user.LoginFailure) error {
+	_, err := lr.client.NewInsert().Model(&failure).Exec(ctx)
+	return translateError(err)
+}
+
+// DeleteFailuresBefore удаляет записи журнала старше before
+func (lr LoginAuditRepository) DeleteFailuresBefore(ctx context.Context, before time.Time) error {
+	_, err := lr.client.NewDelete().Model((*user.LoginFailure)(nil)).Where("attempted_at < ?", before).Exec(ctx)
+	return translateError(err)
+}
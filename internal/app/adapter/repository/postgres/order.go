@@ -9,8 +9,12 @@ import (
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
 	"github.com/gofrs/uuid"
 	"github.com/uptrace/bun"
+	"time"
 )
 
+// statusHistoryLockKey ключ блокировки, которая сериализует записи в историю статусов
+const statusHistoryLockKey int64 = 0x6f726465725f68
+
 type OrderRepository struct {
 	client *postgres.Client
 }
@@ -24,7 +28,28 @@ func (or OrderRepository) CreateOrder(ctx context.Context, order order.Order, tx
 		tx = or.client
 	}
 	_, err := tx.NewInsert().Model(&order).Exec(ctx)
-	return err
+	return translateError(err)
+}
+
+// CreateOrdersIfNotExist создаёт заказы, номера которых ещё не загружены, и возвращает номера созданных.
+// Заказы с уже занятыми номерами пропускаются без ошибки.
+func (or OrderRepository) CreateOrdersIfNotExist(ctx context.Context, orders []order.Order, tx bun.IDB) ([]string, error) {
+	if tx == nil {
+		tx = or.client
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	created := make([]string, 0, len(orders))
+	_, err := tx.NewInsert().Model(&orders).
+		On("CONFLICT (number) DO NOTHING").
+		Returning("number").
+		Exec(ctx, &created)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
 }
 
 func (or OrderRepository) GetByNumber(ctx context.Context, number string, tx bun.IDB) (order.Order, error) {
@@ -33,81 +58,250 @@ func (or OrderRepository) GetByNumber(ctx context.Context, number string, tx bun
 	}
 	o := new(order.Order)
 	err := tx.NewSelect().Model(o).Where("number = ?", number).Scan(ctx)
-	return *o, err
+	return *o, translateError(err)
 }
 
-func (or OrderRepository) GetAllByUser(ctx context.Context, userID uuid.UUID) ([]service.OrderInfo, error) {
+// GetPageByUser возвращает страницу заказов пользователя, упорядоченную по времени загрузки и идентификатору.
+// Начисление берётся только из транзакции INCOME: списания и корректировки тоже хранят номер заказа.
+func (or OrderRepository) GetPageByUser(
+	ctx context.Context, userID uuid.UUID, filter order.ListFilter,
+) ([]service.OrderInfo, error) {
+	q := or.client.NewSelect().TableExpr("orders AS o").
+		ColumnExpr("o.id, o.number, o.status, t.sum AS accrual, o.uploaded_at").
+		Join(`LEFT JOIN transactions AS t ON t."order" = o.number AND t.type = ?`, transaction.TypeIncome).
+		Where("o.user_id = ?", userID.String())
+	if len(filter.Statuses) > 0 {
+		q = q.Where("o.status IN (?)", bun.In(filter.Statuses))
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("o.uploaded_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("o.uploaded_at < ?", filter.To)
+	}
+	direction := "DESC"
+	if filter.Ascending {
+		direction = "ASC"
+	}
+	if filter.After != nil {
+		if filter.Ascending {
+			q = q.Where("(o.uploaded_at, o.id) > (?, ?)", filter.After.UploadedAt, filter.After.ID.String())
+		} else {
+			q = q.Where("(o.uploaded_at, o.id) < (?, ?)", filter.After.UploadedAt, filter.After.ID.String())
+		}
+	}
 	orderInfos := make([]service.OrderInfo, 0)
-	err := or.client.NewRaw(
-		"SELECT o.number, o.status, t.sum accrual, o.uploaded_at FROM orders as o LEFT JOIN transactions t on t.order = o.number WHERE o."+
-			"user_id = ?",
-		userID.String(),
-	).Scan(ctx, &orderInfos)
+	err := q.OrderExpr("o.uploaded_at "+direction+", o.id "+direction).
+		Limit(filter.Limit).
+		Scan(ctx, &orderInfos)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return orderInfos, nil
 }
 
-func (or OrderRepository) UpdateOrder(ctx context.Context, order order.Order, tx bun.IDB) error {
+// GetUserOrderInfo возвращает заказ пользователя с начислением. Чужой заказ не находится, как и несуществующий.
+func (or OrderRepository) GetUserOrderInfo(
+	ctx context.Context, userID uuid.UUID, number string,
+) (service.OrderInfo, error) {
+	var orderInfo service.OrderInfo
+	err := or.client.NewSelect().TableExpr("orders AS o").
+		ColumnExpr("o.id, o.number, o.status, t.sum AS accrual, o.uploaded_at").
+		Join(`LEFT JOIN transactions AS t ON t."order" = o.number AND t.type = ?`, transaction.TypeIncome).
+		Where("o.user_id = ?", userID.String()).
+		Where("o.number = ?", number).
+		Limit(1).
+		Scan(ctx, &orderInfo)
+	if err != nil {
+		return service.OrderInfo{}, translateError(err)
+	}
+	return orderInfo, nil
+}
+
+// MarkInvalid переводит заказ в статус INVALID, только если он ещё не достиг конечного статуса,
+// чтобы не отменить уже начисленный заказ. Возвращает, был ли заказ изменён.
+func (or OrderRepository) MarkInvalid(ctx context.Context, id uuid.UUID, tx bun.IDB) (bool, error) {
 	if tx == nil {
 		tx = or.client
 	}
-	_, err := tx.NewUpdate().Model(&order).WherePK().Exec(ctx)
-	return err
+	res, err := tx.NewUpdate().Model((*order.Order)(nil)).
+		Set("status = ?", order.StatusInvalid).
+		Where("id = ?", id).
+		Where("status NOT IN (?)", bun.In([]string{order.StatusProcessed, order.StatusInvalid})).
+		Exec(ctx)
+	if err != nil {
+		return false, translateError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
 }
 
+// BatchUpdateOrdersAndBalance меняет статусы только у заказов, ещё не достигших конечного статуса,
+// и создаёт начисления только по заказам, статус которых был изменён этим вызовом.
+// Уникальный индекс transactions_income_order_key не допускает второго начисления по заказу.
+// Возвращает номера заказов, которые были обновлены.
 func (or OrderRepository) BatchUpdateOrdersAndBalance(
-	ctx context.Context, orders []order.Order, transactions []transaction.Transaction,
-) error {
-	tx, err := or.client.BeginTx(ctx, &sql.TxOptions{})
+	ctx context.Context, orders []order.Order, transactions []transaction.Transaction, tx bun.IDB,
+) ([]string, error) {
+	if tx == nil {
+		tx = or.client
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	updated := make([]string, 0, len(orders))
+	_, err := tx.NewUpdate().Model(&orders).Column("status").Bulk().
+		Where("o.status NOT IN (?)", bun.In([]string{order.StatusProcessed, order.StatusInvalid})).
+		Returning("o.number").
+		Exec(ctx, &updated)
 	if err != nil {
-		return err
+		return nil, translateError(err)
+	}
+
+	updatedNumbers := make(map[string]bool, len(updated))
+	for _, number := range updated {
+		updatedNumbers[number] = true
 	}
-	if _, err := tx.NewUpdate().Model(&orders).Column("status").Bulk().Exec(ctx); err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return err
+	income := make([]transaction.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		if updatedNumbers[t.OrderNumber] {
+			income = append(income, t)
 		}
-		return err
+	}
+	if len(income) == 0 {
+		return updated, nil
+	}
+	_, err = tx.NewInsert().Model(&income).
+		On(`CONFLICT ("order") WHERE type = 'INCOME' DO NOTHING`).
+		Exec(ctx)
+	if err != nil {
+		return nil, translateError(err)
 	}
 
-	if _, err = or.client.NewInsert().Model(&transactions).Exec(ctx); err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return err
-		}
-		return err
+	return updated, nil
+}
+
+// AddStatusChanges дописывает переходы заказов в историю статусов и заполняет их порядковые номера.
+// Записи в историю сериализуются блокировкой до конца транзакции, чтобы номера шли в порядке фиксации:
+// иначе читатель потока мог бы пропустить меньший номер, зафиксированный позже большего.
+func (or OrderRepository) AddStatusChanges(ctx context.Context, changes []order.StatusChange, tx bun.IDB) error {
+	if tx == nil {
+		tx = or.client
+	}
+	if len(changes) == 0 {
+		return nil
 	}
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?)", statusHistoryLockKey); err != nil {
+		return translateError(err)
+	}
+	_, err := tx.NewInsert().Model(&changes).Returning("seq").Exec(ctx)
 
-	return tx.Commit()
+	return translateError(err)
 }
 
-func (or OrderRepository) GetAllByStatuses(ctx context.Context, statuses []string) ([]order.Order, error) {
-	orders := make([]order.Order, 0)
-	err := or.client.NewSelect().Model(&orders).
-		Where("status IN (?)", bun.In(statuses)).
+// GetStatusHistory отдаёт переходы заказа в порядке их записи
+func (or OrderRepository) GetStatusHistory(ctx context.Context, orderID uuid.UUID) ([]order.StatusChange, error) {
+	var changes []order.StatusChange
+	err := or.client.NewSelect().Model(&changes).
+		Where("osh.order_id = ?", orderID).
+		OrderExpr("osh.changed_at ASC, osh.id ASC").
 		Scan(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return changes, nil
+}
+
+// GetStatusEventsByUser возвращает до limit переходов заказов пользователя с порядковым номером больше after.
+// Начисление показывается только у перехода в PROCESSED.
+func (or OrderRepository) GetStatusEventsByUser(
+	ctx context.Context, userID uuid.UUID, after int64, limit int,
+) ([]order.StatusEvent, error) {
+	events := make([]order.StatusEvent, 0)
+	err := or.client.NewSelect().TableExpr("order_status_history AS osh").
+		ColumnExpr("osh.id, osh.seq, o.number, osh.status, osh.changed_at").
+		ColumnExpr("CASE WHEN osh.status = ? THEN t.sum END AS accrual", order.StatusProcessed).
+		Join("JOIN orders AS o ON o.id = osh.order_id").
+		Join(`LEFT JOIN transactions AS t ON t."order" = o.number AND t.type = ?`, transaction.TypeIncome).
+		Where("o.user_id = ?", userID.String()).
+		Where("osh.seq > ?", after).
+		OrderExpr("osh.seq ASC").
+		Limit(limit).
+		Scan(ctx, &events)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return events, nil
+}
+
+// ClaimForPolling выбирает до limit заказов, которые пора опросить, и блокирует их на время lease,
+// чтобы другие экземпляры сервиса их пропустили. Заблокированные другими транзакциями строки пропускаются.
+func (or OrderRepository) ClaimForPolling(
+	ctx context.Context, statuses []string, limit int, lease time.Duration,
+) ([]order.Order, error) {
+	orders := make([]order.Order, 0)
+	err := or.client.NewRaw(
+		`UPDATE orders AS o
+		SET locked_until = now() + make_interval(secs => ?), attempts = o.attempts + 1
+		FROM (
+			SELECT id FROM orders
+			WHERE status IN (?) AND next_check_at <= now() AND (locked_until IS NULL OR locked_until <= now())
+			ORDER BY next_check_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		) AS claimed
+		WHERE o.id = claimed.id
+		RETURNING o.*`,
+		lease.Seconds(), bun.In(statuses), limit,
+	).Scan(ctx, &orders)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return orders, nil
 		}
-		return orders, err
+		return orders, translateError(err)
 	}
 
 	return orders, nil
 }
 
-func (or OrderRepository) GetBatchByNumbers(ctx context.Context, orderNumbers []string) ([]order.Order, error) {
+func (or OrderRepository) ScheduleNextCheck(ctx context.Context, orderID uuid.UUID, nextCheckAt time.Time) error {
+	_, err := or.client.NewUpdate().Model((*order.Order)(nil)).
+		Set("next_check_at = ?", nextCheckAt).
+		Set("locked_until = NULL").
+		Where("id = ?", orderID.String()).
+		Exec(ctx)
+	return translateError(err)
+}
+
+// RequeueForPolling ставит заказ в очередь опроса на nextCheckAt, снимая блокировку
+// и сбрасывая счётчик попыток, чтобы опрос шёл без накопленной задержки
+func (or OrderRepository) RequeueForPolling(ctx context.Context, orderID uuid.UUID, nextCheckAt time.Time) error {
+	_, err := or.client.NewUpdate().Model((*order.Order)(nil)).
+		Set("next_check_at = ?", nextCheckAt).
+		Set("locked_until = NULL").
+		Set("attempts = 0").
+		Where("id = ?", orderID.String()).
+		Exec(ctx)
+	return translateError(err)
+}
+
+func (or OrderRepository) GetBatchByNumbers(ctx context.Context, orderNumbers []string, tx bun.IDB) ([]order.Order, error) {
+	if tx == nil {
+		tx = or.client
+	}
 	orders := make([]order.Order, 0)
-	err := or.client.NewSelect().Model(&orders).
+	err := tx.NewSelect().Model(&orders).
 		Where("number IN (?)", bun.In(orderNumbers)).
 		Scan(ctx)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return orders, nil
 		}
-		return orders, err
+		return orders, translateError(err)
 	}
 
 	return orders, nil
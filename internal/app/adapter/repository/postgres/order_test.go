@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	ports "github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderRepository_ClaimForPolling(t *testing.T) {
+	const (
+		ordersCount = 50
+		claimers    = 5
+	)
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := NewOrderRepository(client)
+	statuses := []string{order.StatusNew, order.StatusProcessing}
+
+	created := make(map[uuid.UUID]bool, ordersCount)
+	for i := 0; i < ordersCount; i++ {
+		id, _ := uuid.NewV7()
+		require.NoError(
+			t, repo.CreateOrder(
+				ctx, order.Order{
+					ID:         id,
+					UserID:     userID,
+					Number:     goluhn.Generate(16),
+					Status:     order.StatusNew,
+					UploadedAt: time.Now(),
+				}, nil,
+			),
+		)
+		created[id] = true
+	}
+
+	var mu sync.Mutex
+	claimed := make(map[uuid.UUID]int)
+	var wg sync.WaitGroup
+	for i := 0; i < claimers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orders, err := repo.ClaimForPolling(ctx, statuses, ordersCount, time.Minute)
+			if err != nil {
+				t.Errorf("ClaimForPolling() error = %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, o := range orders {
+				claimed[o.ID]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id := range created {
+		require.Equal(t, 1, claimed[id], "order %s must be claimed exactly once", id)
+	}
+
+	// Заблокированные заказы не выдаются повторно до истечения блокировки или переноса проверки
+	orders, err := repo.ClaimForPolling(ctx, statuses, ordersCount, time.Minute)
+	require.NoError(t, err)
+	for _, o := range orders {
+		require.False(t, created[o.ID])
+	}
+}
+
+func TestOrderRepository_BatchUpdateOrdersAndBalance_NoDoubleIncome(t *testing.T) {
+	const (
+		updaters             = 10
+		accrual  money.Money = 150_50
+	)
+	client := newTestClient(t)
+	client.SetMaxOpenConns(updaters)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := NewOrderRepository(client)
+	transactionRepo := NewTransactionRepository(client)
+	txHelper := postgres.NewTransactionHelper(client)
+
+	id, _ := uuid.NewV7()
+	created := order.Order{
+		ID:         id,
+		UserID:     userID,
+		Number:     goluhn.Generate(16),
+		Status:     order.StatusNew,
+		UploadedAt: time.Now(),
+	}
+	require.NoError(t, repo.CreateOrder(ctx, created, nil))
+
+	// Каждый обработчик видит заказ в статусе NEW и пытается начислить баллы
+	var wg sync.WaitGroup
+	for i := 0; i < updaters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			processed := created
+			processed.Status = order.StatusProcessed
+			transactionID, _ := uuid.NewV7()
+			income := transaction.Transaction{
+				ID:          transactionID,
+				UserID:      userID,
+				OrderNumber: created.Number,
+				Sum:         accrual,
+				ProcessedAt: time.Now(),
+				Type:        transaction.TypeIncome,
+			}
+			tx, err := txHelper.StartTransaction(ctx)
+			if err != nil {
+				t.Errorf("StartTransaction() error = %v", err)
+				return
+			}
+			_, err = repo.BatchUpdateOrdersAndBalance(
+				ctx, []order.Order{processed}, []transaction.Transaction{income}, tx.GetTransaction(),
+			)
+			if err != nil {
+				_ = tx.Rollback()
+				t.Errorf("BatchUpdateOrdersAndBalance() error = %v", err)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				t.Errorf("Commit() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	balance, err := transactionRepo.GetBalanceByUser(ctx, userID, nil)
+	require.NoError(t, err)
+	require.Equal(t, accrual, balance)
+}
+
+func TestOrderRepository_GetPageByUser(t *testing.T) {
+	const ordersCount = 5
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := NewOrderRepository(client)
+
+	// Два заказа с одинаковым временем загрузки проверяют, что курсор учитывает идентификатор
+	uploadedAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	for i := 0; i < ordersCount; i++ {
+		id, _ := uuid.NewV7()
+		require.NoError(
+			t, repo.CreateOrder(
+				ctx, order.Order{
+					ID:         id,
+					UserID:     userID,
+					Number:     goluhn.Generate(16),
+					Status:     order.StatusNew,
+					UploadedAt: uploadedAt.Add(time.Duration(i/2) * time.Minute),
+				}, nil,
+			),
+		)
+	}
+
+	var pages [][]ports.OrderInfo
+	filter := order.ListFilter{Limit: 2}
+	for {
+		page, err := repo.GetPageByUser(ctx, userID, filter)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+		last := page[len(page)-1]
+		filter.After = &order.Cursor{UploadedAt: last.UploadedAt, ID: last.ID}
+	}
+	require.Len(t, pages, 3)
+	var got []ports.OrderInfo
+	for _, page := range pages {
+		got = append(got, page...)
+	}
+	require.Len(t, got, ordersCount)
+	for i := 1; i < len(got); i++ {
+		require.False(t, got[i].UploadedAt.After(got[i-1].UploadedAt), "orders must go from newest to oldest")
+		require.NotEqual(t, got[i].ID, got[i-1].ID)
+	}
+}
+
+func TestOrderRepository_StatusEventsCommitOrder(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := NewOrderRepository(client)
+	txHelper := postgres.NewTransactionHelper(client)
+
+	id, _ := uuid.NewV7()
+	o := order.Order{
+		ID: id, UserID: userID, Number: goluhn.Generate(16), Status: order.StatusNew, UploadedAt: time.Now(),
+	}
+	require.NoError(t, repo.CreateOrder(ctx, o, nil))
+	newChange := func(status string) order.StatusChange {
+		changeID, _ := uuid.NewV7()
+		return order.StatusChange{ID: changeID, OrderID: o.ID, Status: status, ChangedAt: time.Now()}
+	}
+
+	// Первая транзакция записывает переход и не фиксируется, вторая должна ждать её фиксации,
+	// иначе больший номер стал бы виден раньше меньшего
+	first, err := txHelper.StartTransaction(ctx)
+	require.NoError(t, err)
+	firstChanges := []order.StatusChange{newChange(order.StatusProcessing)}
+	require.NoError(t, repo.AddStatusChanges(ctx, firstChanges, first.GetTransaction()))
+
+	secondDone := make(chan error, 1)
+	go func() {
+		second, err := txHelper.StartTransaction(ctx)
+		if err != nil {
+			secondDone <- err
+			return
+		}
+		changes := []order.StatusChange{newChange(order.StatusProcessed)}
+		if err := repo.AddStatusChanges(ctx, changes, second.GetTransaction()); err != nil {
+			_ = second.Rollback()
+			secondDone <- err
+			return
+		}
+		secondDone <- second.Commit()
+	}()
+	select {
+	case err := <-secondDone:
+		t.Fatalf("second transaction finished before the first one committed: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	require.NoError(t, first.Commit())
+	require.NoError(t, <-secondDone)
+
+	events, err := repo.GetStatusEventsByUser(ctx, userID, 0, order.MaxReplayEvents)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, firstChanges[0].ID, events[0].ID)
+	require.Equal(t, firstChanges[0].Seq, events[0].Seq)
+	require.Less(t, events[0].Seq, events[1].Seq)
+	require.Equal(t, order.StatusProcessed, events[1].Status)
+
+	events, err = repo.GetStatusEventsByUser(ctx, userID, firstChanges[0].Seq, order.MaxReplayEvents)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, order.StatusProcessed, events[0].Status)
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// Тесты репозиториев выполняются на реальной базе, адрес которой задаётся в TEST_DATABASE_URI
+func newTestClient(t *testing.T) *postgres.Client {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := postgres.NewPostgresConnection(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	_, err = postgres.NewMigrator(client).Up(ctx)
+	require.NoError(t, err)
+
+	return client
+}
+
+func createTestUser(t *testing.T, client *postgres.Client) uuid.UUID {
+	t.Helper()
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+	_, err = NewUserRepository(client).CreateUser(
+		context.Background(), user.User{
+			ID:       id,
+			Login:    id.String(),
+			Password: "-",
+		},
+	)
+	require.NoError(t, err)
+
+	return id
+}
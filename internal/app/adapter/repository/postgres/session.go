@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/session"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+type SessionRepository struct {
+	client *postgres.Client
+}
+
+func NewSessionRepository(client *postgres.Client) *SessionRepository {
+	return &SessionRepository{client: client}
+}
+
+func (sr SessionRepository) CreateSession(ctx context.Context, s session.Session, tx bun.IDB) error {
+	if tx == nil {
+		tx = sr.client
+	}
+	_, err := tx.NewInsert().Model(&s).Exec(ctx)
+	return translateError(err)
+}
+
+func (sr SessionRepository) GetSession(ctx context.Context, id uuid.UUID) (session.Session, error) {
+	s := session.Session{}
+	err := sr.client.NewSelect().Model(&s).Where("id = ?", id).Scan(ctx)
+	return s, translateError(err)
+}
+
+// GetSessionByRefreshHash ищет сессию по текущему или предыдущему хешу refresh токена и блокирует её
+// до конца транзакции, чтобы два параллельных обновления не выдали два действующих токена
+func (sr SessionRepository) GetSessionByRefreshHash(ctx context.Context, hash string, tx bun.IDB) (session.Session, error) {
+	if tx == nil {
+		tx = sr.client
+	}
+	s := session.Session{}
+	err := tx.NewSelect().Model(&s).
+		Where("refresh_token_hash = ? OR previous_refresh_token_hash = ?", hash, hash).
+		For("UPDATE").
+		Limit(1).
+		Scan(ctx)
+	return s, translateError(err)
+}
+
+func (sr SessionRepository) UpdateSession(ctx context.Context, s session.Session, tx bun.IDB) error {
+	if tx == nil {
+		tx = sr.client
+	}
+	_, err := tx.NewUpdate().Model(&s).WherePK().Exec(ctx)
+	return translateError(err)
+}
+
+func (sr SessionRepository) RevokeSession(ctx context.Context, id uuid.UUID, revokedAt time.Time) error {
+	_, err := sr.client.NewUpdate().Model((*session.Session)(nil)).
+		Set("revoked_at = ?", revokedAt).
+		Where("id = ?", id).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	return translateError(err)
+}
+
+func (sr SessionRepository) RevokeUserSessions(
+	ctx context.Context, userID uuid.UUID, revokedAt time.Time, tx bun.IDB,
+) error {
+	if tx == nil {
+		tx = sr.client
+	}
+	_, err := tx.NewUpdate().Model((*session.Session)(nil)).
+		Set("revoked_at = ?", revokedAt).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	return translateError(err)
+}
@@ -3,11 +3,11 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
 	"github.com/gofrs/uuid"
 	"github.com/uptrace/bun"
-	"math"
 )
 
 type TransactionRepository struct {
@@ -23,16 +23,30 @@ func (tr TransactionRepository) CreateTransaction(ctx context.Context, transacti
 		tx = tr.client
 	}
 	_, err := tx.NewInsert().Model(&transaction).Exec(ctx)
-	return err
+	return translateError(err)
 }
 
-func (tr TransactionRepository) GetBalanceByUser(ctx context.Context, userID uuid.UUID, tx bun.IDB) (float64, error) {
+// LockBalance блокирует строку пользователя до конца транзакции tx, чтобы параллельные списания
+// одного пользователя выполнялись последовательно и проверяли уже обновлённый баланс.
+func (tr TransactionRepository) LockBalance(ctx context.Context, userID uuid.UUID, tx bun.IDB) error {
 	if tx == nil {
 		tx = tr.client
 	}
-	var balance float64
+	var id uuid.UUID
 	err := tx.NewRaw(
-		"SELECT SUM(sum) FROM transactions WHERE user_id = ? GROUP BY user_id",
+		"SELECT id FROM users WHERE id = ? FOR NO KEY UPDATE",
+		userID.String(),
+	).Scan(ctx, &id)
+	return translateError(err)
+}
+
+func (tr TransactionRepository) GetBalanceByUser(ctx context.Context, userID uuid.UUID, tx bun.IDB) (money.Money, error) {
+	if tx == nil {
+		tx = tr.client
+	}
+	var balance money.Money
+	err := tx.NewRaw(
+		"SELECT SUM(sum)::bigint FROM transactions WHERE user_id = ? GROUP BY user_id",
 		userID.String(),
 	).Scan(ctx, &balance)
 	if err != nil {
@@ -45,10 +59,10 @@ func (tr TransactionRepository) GetBalanceByUser(ctx context.Context, userID uui
 	return balance, nil
 }
 
-func (tr TransactionRepository) GetWithdrawalSumByUser(ctx context.Context, userID uuid.UUID) (float64, error) {
-	var sum float64
+func (tr TransactionRepository) GetWithdrawalSumByUser(ctx context.Context, userID uuid.UUID) (money.Money, error) {
+	var sum money.Money
 	err := tr.client.NewRaw(
-		"SELECT SUM(sum) FROM transactions WHERE user_id = ? AND type = ? GROUP BY user_id",
+		"SELECT SUM(sum)::bigint FROM transactions WHERE user_id = ? AND type = ? GROUP BY user_id",
 		userID.String(), transaction.TypeWithdraw,
 	).Scan(ctx, &sum)
 	if err != nil {
@@ -58,7 +72,7 @@ func (tr TransactionRepository) GetWithdrawalSumByUser(ctx context.Context, user
 		return 0, err
 	}
 
-	return math.Abs(sum), nil
+	return sum.Abs(), nil
 }
 
 func (tr TransactionRepository) GetWithdrawalsByUser(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error) {
@@ -76,3 +90,94 @@ func (tr TransactionRepository) GetWithdrawalsByUser(ctx context.Context, userID
 
 	return transactions, nil
 }
+
+func (tr TransactionRepository) GetAllByUser(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error) {
+	transactions := make([]transaction.Transaction, 0)
+	err := tr.client.NewSelect().Model(&transactions).
+		Where("user_id = ?", userID.String()).
+		OrderExpr("processed_at, id").
+		Scan(ctx)
+	if err != nil {
+		return transactions, translateError(err)
+	}
+
+	return transactions, nil
+}
+
+// GetHistory возвращает страницу истории транзакций пользователя от новых к старым. Баланс после каждой
+// транзакции считается по всей истории пользователя до применения фильтров.
+func (tr TransactionRepository) GetHistory(
+	ctx context.Context, userID uuid.UUID, filter transaction.HistoryFilter,
+) ([]transaction.HistoryEntry, error) {
+	history := tr.client.NewSelect().Model((*transaction.Transaction)(nil)).
+		Column("id", "type", "order", "sum", "reason", "processed_at").
+		ColumnExpr("(SUM(tr.sum) OVER (ORDER BY tr.id))::bigint AS balance").
+		Where("user_id = ?", userID.String())
+	q := tr.client.NewSelect().TableExpr("(?) AS h", history).ColumnExpr("h.*")
+	if len(filter.Types) > 0 {
+		q = q.Where("h.type IN (?)", bun.In(filter.Types))
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("h.processed_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("h.processed_at < ?", filter.To)
+	}
+	if filter.Before != uuid.Nil {
+		q = q.Where("h.id < ?", filter.Before.String())
+	}
+	entries := make([]transaction.HistoryEntry, 0)
+	err := q.OrderExpr("h.id DESC").Limit(filter.Limit).Scan(ctx, &entries)
+	if err != nil {
+		return entries, translateError(err)
+	}
+
+	return entries, nil
+}
+
+func (tr TransactionRepository) GetWithdrawalByOrder(
+	ctx context.Context, userID uuid.UUID, orderNumber string, tx bun.IDB,
+) (transaction.Transaction, error) {
+	if tx == nil {
+		tx = tr.client
+	}
+	t := new(transaction.Transaction)
+	err := tx.NewSelect().Model(t).
+		Where("user_id = ?", userID.String()).
+		Where(`"order" = ?`, orderNumber).
+		Where("type = ?", transaction.TypeWithdraw).
+		Scan(ctx)
+	if err != nil {
+		return *t, translateError(err)
+	}
+
+	return *t, nil
+}
+
+func (tr TransactionRepository) GetWithdrawalRequest(
+	ctx context.Context, userID uuid.UUID, key string, tx bun.IDB,
+) (transaction.WithdrawalRequest, error) {
+	if tx == nil {
+		tx = tr.client
+	}
+	r := new(transaction.WithdrawalRequest)
+	err := tx.NewSelect().Model(r).
+		Where("user_id = ?", userID.String()).
+		Where("idempotency_key = ?", key).
+		Scan(ctx)
+	if err != nil {
+		return *r, translateError(err)
+	}
+
+	return *r, nil
+}
+
+func (tr TransactionRepository) CreateWithdrawalRequest(
+	ctx context.Context, request transaction.WithdrawalRequest, tx bun.IDB,
+) error {
+	if tx == nil {
+		tx = tr.client
+	}
+	_, err := tx.NewInsert().Model(&request).Exec(ctx)
+	return translateError(err)
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTransactionRepository_GetHistory(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := NewTransactionRepository(client)
+
+	sums := []money.Money{100_00, -30_00, 50_00, -20_00}
+	start := time.Now().Add(-time.Hour)
+	for i, sum := range sums {
+		id, _ := uuid.NewV7()
+		tType := transaction.TypeIncome
+		if sum < 0 {
+			tType = transaction.TypeWithdraw
+		}
+		require.NoError(
+			t, repo.CreateTransaction(
+				ctx, transaction.Transaction{
+					ID:          id,
+					UserID:      userID,
+					OrderNumber: goluhn.Generate(12),
+					Sum:         sum,
+					ProcessedAt: start.Add(time.Duration(i) * time.Minute),
+					Type:        tType,
+				}, nil,
+			),
+		)
+	}
+
+	all, err := repo.GetHistory(ctx, userID, transaction.HistoryFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, all, len(sums))
+	require.Equal(t, []money.Money{100_00, 70_00, 120_00, 100_00}, []money.Money{
+		all[3].Balance, all[2].Balance, all[1].Balance, all[0].Balance,
+	})
+
+	page, err := repo.GetHistory(ctx, userID, transaction.HistoryFilter{Limit: 2, Before: all[1].ID})
+	require.NoError(t, err)
+	require.Equal(t, all[2:], page)
+
+	// Фильтр не меняет баланс, посчитанный по всей истории
+	withdrawals, err := repo.GetHistory(
+		ctx, userID, transaction.HistoryFilter{Types: []string{transaction.TypeWithdraw}, Limit: 10},
+	)
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 2)
+	require.Equal(t, money.Money(100_00), withdrawals[0].Balance)
+	require.Equal(t, money.Money(70_00), withdrawals[1].Balance)
+}
@@ -3,7 +3,10 @@ package postgres
 import (
 	"context"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
 )
 
 type UserRepository struct {
@@ -16,11 +19,46 @@ func NewUserRepository(client *postgres.Client) *UserRepository {
 
 func (ur UserRepository) CreateUser(ctx context.Context, user user.User) (user.User, error) {
 	_, err := ur.client.NewInsert().Model(&user).Exec(ctx)
-	return user, err
+	return user, translateError(err)
 }
 
 func (ur UserRepository) GetByLogin(ctx context.Context, login string) (user.User, error) {
 	u := new(user.User)
 	err := ur.client.NewSelect().Model(u).Where("login = ?", login).Scan(ctx)
-	return *u, err
+	return *u, translateError(err)
+}
+
+func (ur UserRepository) GetByID(ctx context.Context, id uuid.UUID) (user.User, error) {
+	u := new(user.User)
+	err := ur.client.NewSelect().Model(u).Where("id = ?", id).Scan(ctx)
+	return *u, translateError(err)
+}
+
+func (ur UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, tx bun.IDB) error {
+	if tx == nil {
+		tx = ur.client
+	}
+	_, err := tx.NewUpdate().Model((*user.User)(nil)).
+		Set("password = ?", passwordHash).
+		Where("id = ?", id).
+		Exec(ctx)
+	return translateError(err)
+}
+
+func (ur UserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role user.Role, tx bun.IDB) error {
+	if tx == nil {
+		tx = ur.client
+	}
+	res, err := tx.NewUpdate().Model((*user.User)(nil)).
+		Set("role = ?", role).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return repository.NoResultError{}
+	}
+
+	return nil
 }
@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUserRepository_GetByLogin_NoResult(t *testing.T) {
+	client := newTestClient(t)
+	login, _ := uuid.NewV7()
+	_, err := NewUserRepository(client).GetByLogin(context.Background(), login.String())
+	require.ErrorIs(t, err, repository.NoResultError{})
+}
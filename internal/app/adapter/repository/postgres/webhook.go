@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+type WebhookRepository struct {
+	client *postgres.Client
+}
+
+func NewWebhookRepository(client *postgres.Client) *WebhookRepository {
+	return &WebhookRepository{client: client}
+}
+
+func (wr WebhookRepository) CreateEndpoint(ctx context.Context, endpoint webhook.Endpoint) error {
+	_, err := wr.client.NewInsert().Model(&endpoint).Exec(ctx)
+	return translateError(err)
+}
+
+func (wr WebhookRepository) GetEndpointsByUser(ctx context.Context, userID uuid.UUID) ([]webhook.Endpoint, error) {
+	endpoints := make([]webhook.Endpoint, 0)
+	err := wr.client.NewSelect().Model(&endpoints).
+		Where("we.user_id = ?", userID).
+		OrderExpr("we.created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint удаляет адрес пользователя вместе с журналом доставок на него
+func (wr WebhookRepository) DeleteEndpoint(ctx context.Context, userID uuid.UUID, endpointID uuid.UUID) error {
+	res, err := wr.client.NewDelete().Model((*webhook.Endpoint)(nil)).
+		Where("id = ?", endpointID).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return repository.NoResultError{}
+	}
+	return nil
+}
+
+// Enqueue ставит событие в очередь доставки на каждый адрес пользователя, подписанный на его тип.
+// Вызывается в транзакции изменения, породившего событие, поэтому событие не теряется и не отправляется
+// об откаченном изменении. Адреса блокируются FOR KEY SHARE до конца транзакции: параллельное удаление
+// адреса либо дожидается её, либо уже зафиксировано, и тогда адрес пропускается, а не нарушает внешний ключ.
+func (wr WebhookRepository) Enqueue(ctx context.Context, event webhook.Event, tx bun.IDB) error {
+	if tx == nil {
+		tx = wr.client
+	}
+	var endpoints []webhook.Endpoint
+	err := tx.NewSelect().Model(&endpoints).
+		Column("id").
+		Where("we.user_id = ?", event.UserID).
+		Where("? = ANY(we.events)", event.Type).
+		For("KEY SHARE").
+		Scan(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	deliveries := make([]webhook.Delivery, len(endpoints))
+	for i, e := range endpoints {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		deliveries[i] = webhook.Delivery{
+			ID:            id,
+			EndpointID:    e.ID,
+			EventID:       event.ID,
+			Event:         event.Type,
+			Payload:       payload,
+			Status:        webhook.DeliveryPending,
+			NextAttemptAt: event.CreatedAt,
+			CreatedAt:     event.CreatedAt,
+		}
+	}
+	_, err = tx.NewInsert().Model(&deliveries).Exec(ctx)
+	return translateError(err)
+}
+
+// ClaimDue выбирает до limit доставок, время попытки которых наступило, и блокирует их на время lease,
+// чтобы другие экземпляры сервиса их пропустили. Вместе с доставкой возвращаются адрес и секрет.
+func (wr WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	deliveries := make([]webhook.Delivery, 0)
+	err := wr.client.NewRaw(
+		`UPDATE webhook_deliveries AS wd
+		SET locked_until = now() + make_interval(secs => ?)
+		FROM (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= now() AND (locked_until IS NULL OR locked_until <= now())
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		) AS claimed, webhook_endpoints AS we
+		WHERE wd.id = claimed.id AND we.id = wd.endpoint_id
+		RETURNING wd.*, we.url, we.secret`,
+		lease.Seconds(), webhook.DeliveryPending, limit,
+	).Scan(ctx, &deliveries)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return deliveries, nil
+		}
+		return deliveries, translateError(err)
+	}
+
+	return deliveries, nil
+}
+
+// SaveAttempt сохраняет результат попытки доставки и снимает блокировку
+func (wr WebhookRepository) SaveAttempt(ctx context.Context, delivery webhook.Delivery) error {
+	delivery.LockedUntil = time.Time{}
+	_, err := wr.client.NewUpdate().Model(&delivery).
+		Column("status", "attempts", "next_attempt_at", "locked_until", "last_status_code", "last_error", "delivered_at").
+		WherePK().
+		Exec(ctx)
+	return translateError(err)
+}
+
+// GetDeliveries возвращает до limit последних доставок на адрес пользователя, от новых к старым
+func (wr WebhookRepository) GetDeliveries(
+	ctx context.Context, userID uuid.UUID, endpointID uuid.UUID, limit int,
+) ([]webhook.Delivery, error) {
+	deliveries := make([]webhook.Delivery, 0)
+	err := wr.client.NewSelect().Model(&deliveries).
+		Join("JOIN webhook_endpoints AS we ON we.id = wd.endpoint_id").
+		Where("wd.endpoint_id = ?", endpointID).
+		Where("we.user_id = ?", userID).
+		OrderExpr("wd.id DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWebhookRepository_EnqueueSkipsDeletedEndpoint(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := NewWebhookRepository(client)
+	endpointID, _ := uuid.NewV7()
+	require.NoError(
+		t, repo.CreateEndpoint(
+			ctx, webhook.Endpoint{
+				ID:        endpointID,
+				UserID:    userID,
+				URL:       "https://example.com/hook",
+				Secret:    "0123456789abcdef",
+				Events:    []string{webhook.EventOrderProcessed},
+				CreatedAt: time.Now(),
+			},
+		),
+	)
+
+	// Адрес удаляется в транзакции, которая фиксируется, пока событие ставится в очередь
+	deleteTx, err := client.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer func() { _ = deleteTx.Rollback() }()
+	_, err = deleteTx.NewDelete().Model((*webhook.Endpoint)(nil)).Where("id = ?", endpointID).Exec(ctx)
+	require.NoError(t, err)
+
+	eventID, _ := uuid.NewV7()
+	enqueued := make(chan error, 1)
+	go func() {
+		enqueued <- repo.Enqueue(
+			ctx, webhook.Event{
+				ID:        eventID,
+				UserID:    userID,
+				Type:      webhook.EventOrderProcessed,
+				CreatedAt: time.Now(),
+			}, nil,
+		)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, deleteTx.Commit())
+	require.NoError(t, <-enqueued)
+
+	count, err := client.NewSelect().Model((*webhook.Delivery)(nil)).Where("event_id = ?", eventID).Count(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
@@ -0,0 +1,24 @@
+package audit
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+const (
+	ActionBalanceAdjusted = "BALANCE_ADJUSTED"
+)
+
+// Entry запись журнала действий сотрудников. Журнал только пополняется, это обеспечивают триггеры в базе.
+type Entry struct {
+	bun.BaseModel `bun:"table:audit_log,alias:al"`
+
+	ID           uuid.UUID      `bun:"id,type:uuid,pk"                json:"id"`
+	ActorID      uuid.UUID      `bun:"actor_id,type:uuid,notnull"     json:"actor_id"`
+	Action       string         `bun:"action,notnull"                 json:"action"`
+	TargetUserID uuid.UUID      `bun:"target_user_id,type:uuid,nullzero" json:"target_user_id,omitempty"`
+	EntityID     uuid.UUID      `bun:"entity_id,type:uuid,nullzero"   json:"entity_id,omitempty"`
+	Details      map[string]any `bun:"details,type:jsonb,notnull"     json:"details"`
+	CreatedAt    time.Time      `bun:"created_at,notnull"             json:"created_at"`
+}
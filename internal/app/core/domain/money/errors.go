@@ -0,0 +1,11 @@
+package money
+
+import "fmt"
+
+type InvalidAmount struct {
+	Value string
+}
+
+func (e InvalidAmount) Error() string {
+	return fmt.Sprintf("Invalid amount: %s", e.Value)
+}
@@ -0,0 +1,96 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money хранит количество баллов в сотых долях (1 балл = 1 рубль = 100 копеек),
+// чтобы суммы складывались и сравнивались точно. В JSON кодируется десятичным числом: 729.98
+type Money int64
+
+const (
+	scale     = 100
+	precision = 2
+)
+
+// Parse разбирает десятичную запись суммы без потери точности.
+// Значения с более чем двумя знаками после запятой округляются до копеек, половина округляется от нуля.
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		return 0, &InvalidAmount{Value: s}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, &InvalidAmount{Value: s}
+	}
+	r.Mul(r, big.NewRat(scale, 1))
+
+	num := new(big.Int).Abs(r.Num())
+	quo, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	if !quo.IsInt64() {
+		return 0, &InvalidAmount{Value: s}
+	}
+
+	return Money(quo.Int64()), nil
+}
+
+// FromFloat переводит сумму в копейки с округлением половины от нуля.
+func FromFloat(f float64) Money {
+	return Money(math.Round(f * scale))
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / scale
+}
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+func (m Money) String() string {
+	sign := ""
+	units := int64(m)
+	if units < 0 {
+		sign = "-"
+	}
+	abs := uint64(units)
+	if units < 0 {
+		abs = uint64(-(units + 1)) + 1
+	}
+	whole, fraction := abs/scale, abs%scale
+	if fraction == 0 {
+		return sign + strconv.FormatUint(whole, 10)
+	}
+	return strings.TrimRight(fmt.Sprintf("%s%d.%0*d", sign, whole, precision, fraction), "0")
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,99 @@
+package money
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Money
+		wantErr bool
+	}{
+		{name: "Test_1. Целое число", value: "500", want: 50000},
+		{name: "Test_2. Два знака после запятой", value: "729.98", want: 72998},
+		{name: "Test_3. Один знак после запятой", value: "0.1", want: 10},
+		{name: "Test_4. Половина копейки округляется вверх", value: "0.005", want: 1},
+		{name: "Test_5. Меньше половины копейки отбрасывается", value: "0.0049999", want: 0},
+		{name: "Test_6. Отрицательная половина округляется от нуля", value: "-0.005", want: -1},
+		{name: "Test_7. Экспоненциальная запись", value: "1.5e2", want: 15000},
+		{name: "Test_8. Число, не представимое в float64 точно", value: "0.30000000000000004", want: 30},
+		{name: "Test_9. Большая сумма", value: "92233720368547758.07", want: 9223372036854775807},
+		{name: "Test_10. Переполнение", value: "92233720368547758.08", wantErr: true},
+		{name: "Test_11. Не число", value: "abc", wantErr: true},
+		{name: "Test_12. Дробь", value: "1/3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := Parse(tt.value)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				require.Equal(t, tt.want, got)
+			},
+		)
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		value Money
+		want  string
+	}{
+		{name: "Test_1. Ноль", value: 0, want: "0"},
+		{name: "Test_2. Целое число", value: 50000, want: "500"},
+		{name: "Test_3. Десятые", value: 50, want: "0.5"},
+		{name: "Test_4. Сотые", value: 72998, want: "729.98"},
+		{name: "Test_5. Отрицательная сумма", value: -5, want: "-0.05"},
+		{name: "Test_6. Минимальное значение", value: -9223372036854775808, want: "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				require.Equal(t, tt.want, tt.value.String())
+			},
+		)
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	type balance struct {
+		Current Money `json:"current"`
+		Accrual Money `json:"accrual,omitempty"`
+	}
+	var b balance
+	require.NoError(t, json.Unmarshal([]byte(`{"current": 729.98, "accrual": 0.1}`), &b))
+	require.Equal(t, balance{Current: 72998, Accrual: 10}, b)
+
+	resp, err := json.Marshal(balance{Current: 50000})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"current": 500}`, string(resp))
+
+	require.Error(t, json.Unmarshal([]byte(`{"current": "много"}`), &b))
+}
+
+func TestMoney_NoDrift(t *testing.T) {
+	var sum Money
+	var floatSum float64
+	for i := 0; i < 1000; i++ {
+		accrual, err := Parse("0.1")
+		require.NoError(t, err)
+		sum += accrual
+		floatSum += 0.1
+	}
+	require.Equal(t, Money(10000), sum)
+	require.NotEqual(t, 100.0, floatSum)
+	require.Equal(t, "100", sum.String())
+}
+
+func TestFromFloat(t *testing.T) {
+	require.Equal(t, Money(10), FromFloat(0.1))
+	require.Equal(t, Money(30), FromFloat(0.1+0.2))
+	require.Equal(t, Money(-72998), FromFloat(-729.98))
+}
@@ -0,0 +1,19 @@
+package order
+
+import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+// AccrualResult - ответ системы лояльности, сохранённый до применения к заказу и балансу.
+// Для каждого заказа хранится только последний ответ, ProcessedAt заполняется после применения.
+type AccrualResult struct {
+	bun.BaseModel `bun:"table:accrual_results,alias:ar"`
+
+	OrderNumber string      `bun:"order,pk"                   json:"order"`
+	Status      string      `bun:"status,notnull"             json:"status"`
+	Accrual     money.Money `bun:"accrual,notnull"            json:"accrual"`
+	ReceivedAt  time.Time   `bun:"received_at,notnull"        json:"-"`
+	ProcessedAt time.Time   `bun:"processed_at,nullzero"      json:"-"`
+}
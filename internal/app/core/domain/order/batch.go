@@ -0,0 +1,18 @@
+package order
+
+// MaxBatchSize больше номеров за один запрос пакетной загрузки не принимается
+const MaxBatchSize = 1000
+
+// Результаты загрузки номера в пакете
+const (
+	LoadAccepted         string = "accepted"
+	LoadAlreadyUploaded  string = "already_uploaded"
+	LoadUploadedByOthers string = "uploaded_by_another_user"
+	LoadInvalidFormat    string = "invalid_format"
+)
+
+// LoadResult результат загрузки одного номера из пакета
+type LoadResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+}
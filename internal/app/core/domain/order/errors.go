@@ -38,3 +38,28 @@ type InvalidFormat struct {
 func (e InvalidFormat) Error() string {
 	return fmt.Sprintf("Order number %s has invalid format", e.OrderNumber)
 }
+
+type AlreadyFinal struct {
+	OrderNumber string
+	Status      string
+}
+
+func (e AlreadyFinal) Error() string {
+	return fmt.Sprintf("Order number %s already has final status %s", e.OrderNumber, e.Status)
+}
+
+type InvalidListFilter struct {
+	Message string
+}
+
+func (e InvalidListFilter) Error() string {
+	return fmt.Sprintf("Invalid orders filter: %s", e.Message)
+}
+
+type InvalidBatch struct {
+	Message string
+}
+
+func (e InvalidBatch) Error() string {
+	return fmt.Sprintf("Invalid orders batch: %s", e.Message)
+}
@@ -0,0 +1,22 @@
+package order
+
+import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+// MaxReplayEvents больше событий за один запрос пропущенных изменений не отдаётся
+const MaxReplayEvents = 1000
+
+// StatusEvent изменение статуса заказа для владельца заказа. ID и Seq совпадают с идентификатором
+// и порядковым номером записи истории статусов. Номера выдаются в порядке фиксации транзакций,
+// поэтому по Seq можно продолжить поток с места обрыва.
+type StatusEvent struct {
+	ID        uuid.UUID   `json:"-"`
+	Seq       int64       `json:"-"`
+	Number    string      `json:"number"`
+	Status    string      `json:"status"`
+	Accrual   money.Money `json:"accrual,omitempty"`
+	ChangedAt time.Time   `json:"changed_at"`
+}
@@ -0,0 +1,69 @@
+package order
+
+import (
+	"encoding/base64"
+	"github.com/gofrs/uuid"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// Cursor позиция в списке заказов: время загрузки и идентификатор последнего заказа страницы
+type Cursor struct {
+	UploadedAt time.Time
+	ID         uuid.UUID
+}
+
+func (c Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.UploadedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, &InvalidListFilter{Message: "invalid cursor"}
+	}
+	uploadedAt, id, ok := strings.Cut(string(b), "|")
+	if !ok {
+		return Cursor{}, &InvalidListFilter{Message: "invalid cursor"}
+	}
+	c := Cursor{}
+	if c.UploadedAt, err = time.Parse(time.RFC3339Nano, uploadedAt); err != nil {
+		return Cursor{}, &InvalidListFilter{Message: "invalid cursor"}
+	}
+	if c.ID, err = uuid.FromString(id); err != nil {
+		return Cursor{}, &InvalidListFilter{Message: "invalid cursor"}
+	}
+
+	return c, nil
+}
+
+// ListFilter отбор страницы заказов пользователя. По умолчанию заказы идут от новых к старым.
+type ListFilter struct {
+	Statuses  []string
+	From      time.Time
+	To        time.Time
+	Ascending bool
+	After     *Cursor
+	Limit     int
+}
+
+func (f ListFilter) Validate() error {
+	for _, s := range f.Statuses {
+		if s != StatusNew && s != StatusProcessing && s != StatusInvalid && s != StatusProcessed {
+			return &InvalidListFilter{Message: "unknown order status " + s}
+		}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return &InvalidListFilter{Message: "from must be before to"}
+	}
+	if f.Limit < 1 || f.Limit > MaxListLimit {
+		return &InvalidListFilter{Message: "limit is out of range"}
+	}
+
+	return nil
+}
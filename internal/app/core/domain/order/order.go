@@ -22,6 +22,11 @@ type Order struct {
 	Number     string    `bun:"number,notnull,unique"       json:"number"`
 	Status     string    `bun:"status,notnull"             json:"status"`
 	UploadedAt time.Time `bun:"uploaded_at,notnull"         json:"uploaded_at"`
+
+	// Расписание опроса системы лояльности
+	NextCheckAt time.Time `bun:"next_check_at,nullzero,notnull,default:current_timestamp" json:"-"`
+	Attempts    int       `bun:"attempts,notnull,default:0"                               json:"-"`
+	LockedUntil time.Time `bun:"locked_until,nullzero"                                    json:"-"`
 }
 
 func ValidateOrderFormat(orderNumber string) bool {
@@ -31,3 +36,14 @@ func ValidateOrderFormat(orderNumber string) bool {
 
 	return true
 }
+
+// StatusChange переход заказа в новый статус
+type StatusChange struct {
+	bun.BaseModel `bun:"table:order_status_history,alias:osh"`
+
+	ID        uuid.UUID `bun:"id,type:uuid,pk"               json:"-"`
+	Seq       int64     `bun:"seq,autoincrement"             json:"-"`
+	OrderID   uuid.UUID `bun:"order_id,type:uuid,notnull"    json:"-"`
+	Status    string    `bun:"status,notnull"                json:"status"`
+	ChangedAt time.Time `bun:"changed_at,notnull"            json:"changed_at"`
+}
@@ -0,0 +1,15 @@
+package session
+
+type InvalidRefreshToken struct{}
+
+func (InvalidRefreshToken) Error() string {
+	return "refresh token is invalid, expired or revoked"
+}
+
+type RefreshTokenReused struct {
+	SessionID string
+}
+
+func (e RefreshTokenReused) Error() string {
+	return "refresh token reused, session " + e.SessionID + " revoked"
+}
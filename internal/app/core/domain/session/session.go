@@ -0,0 +1,26 @@
+package session
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+// Session сессия пользователя. Refresh токен хранится только в виде хеша,
+// предыдущий хеш нужен, чтобы обнаружить повторное использование уже заменённого токена.
+type Session struct {
+	bun.BaseModel `bun:"table:sessions,alias:s"`
+
+	ID                       uuid.UUID `bun:"id,type:uuid,pk"`
+	UserID                   uuid.UUID `bun:"user_id,type:uuid,notnull"`
+	RefreshTokenHash         string    `bun:"refresh_token_hash,notnull"`
+	PreviousRefreshTokenHash string    `bun:"previous_refresh_token_hash,nullzero"`
+	CreatedAt                time.Time `bun:"created_at,notnull"`
+	LastUsedAt               time.Time `bun:"last_used_at,notnull"`
+	ExpiresAt                time.Time `bun:"expires_at,notnull"`
+	RevokedAt                time.Time `bun:"revoked_at,nullzero"`
+}
+
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
@@ -1,7 +1,48 @@
 package transaction
 
+import "fmt"
+
 type NotEnoughMoney struct{}
 
 func (NotEnoughMoney) Error() string {
 	return "Not enough money"
 }
+
+type AlreadyWithdrawn struct {
+	OrderNumber string
+}
+
+func (e AlreadyWithdrawn) Error() string {
+	return fmt.Sprintf("Order number %s already paid with another sum", e.OrderNumber)
+}
+
+type IdempotencyKeyReused struct {
+	Key string
+}
+
+func (e IdempotencyKeyReused) Error() string {
+	return fmt.Sprintf("Idempotency key %s already used for another request", e.Key)
+}
+
+type InvalidAdjustment struct {
+	Message string
+}
+
+func (e InvalidAdjustment) Error() string {
+	return fmt.Sprintf("Invalid balance adjustment: %s", e.Message)
+}
+
+// SelfAdjustment сотрудник пытается изменить собственный баланс
+type SelfAdjustment struct{}
+
+func (e SelfAdjustment) Error() string {
+	return "Balance adjustment of own account is forbidden"
+}
+
+type InvalidHistoryFilter struct {
+	Message string
+}
+
+func (e InvalidHistoryFilter) Error() string {
+	return fmt.Sprintf("Invalid history filter: %s", e.Message)
+}
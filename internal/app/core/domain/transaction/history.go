@@ -0,0 +1,49 @@
+package transaction
+
+import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 100
+)
+
+// HistoryEntry транзакция пользователя с балансом после неё
+type HistoryEntry struct {
+	ID          uuid.UUID   `bun:"id,type:uuid"     json:"id"`
+	Type        string      `bun:"type"             json:"type"`
+	OrderNumber string      `bun:"order"            json:"order,omitempty"`
+	Sum         money.Money `bun:"sum"              json:"sum"`
+	Balance     money.Money `bun:"balance"          json:"balance"`
+	Reason      string      `bun:"reason"           json:"reason,omitempty"`
+	ProcessedAt time.Time   `bun:"processed_at"     json:"processed_at"`
+}
+
+// HistoryFilter отбор страницы истории. Транзакции идут от новых к старым, Before - идентификатор
+// последней транзакции предыдущей страницы: UUIDv7 упорядочены по времени создания.
+type HistoryFilter struct {
+	Types  []string
+	From   time.Time
+	To     time.Time
+	Before uuid.UUID
+	Limit  int
+}
+
+func (f HistoryFilter) Validate() error {
+	for _, t := range f.Types {
+		if t != TypeIncome && t != TypeWithdraw && t != TypeAdjustment {
+			return &InvalidHistoryFilter{Message: "unknown transaction type " + t}
+		}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return &InvalidHistoryFilter{Message: "from must be before to"}
+	}
+	if f.Limit < 1 || f.Limit > MaxHistoryLimit {
+		return &InvalidHistoryFilter{Message: "limit is out of range"}
+	}
+
+	return nil
+}
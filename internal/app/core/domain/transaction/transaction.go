@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/gofrs/uuid"
 	"github.com/uptrace/bun"
 	"time"
@@ -9,15 +10,18 @@ import (
 const (
 	TypeIncome   = "INCOME"
 	TypeWithdraw = "WITHDRAW"
+	// TypeAdjustment ручная корректировка баланса сотрудником, не связана с заказом
+	TypeAdjustment = "ADJUSTMENT"
 )
 
 type Transaction struct {
 	bun.BaseModel `bun:"table:transactions,alias:tr"`
 
-	ID          uuid.UUID `bun:"id,type:uuid,pk"             json:"-"`
-	UserID      uuid.UUID `bun:"user_id,type:uuid"           json:"-"`
-	OrderNumber string    `bun:"order,notnull"               json:"order"`
-	Sum         float64   `bun:"sum,notnull"                 json:"sum"`
-	ProcessedAt time.Time `bun:"processed_at,notnull"        json:"processed_at"`
-	Type        string    `bun:"type"                        json:"-"`
+	ID          uuid.UUID   `bun:"id,type:uuid,pk"             json:"-"`
+	UserID      uuid.UUID   `bun:"user_id,type:uuid"           json:"-"`
+	OrderNumber string      `bun:"order,nullzero"              json:"order"`
+	Sum         money.Money `bun:"sum,notnull"                 json:"sum"`
+	ProcessedAt time.Time   `bun:"processed_at,notnull"        json:"processed_at"`
+	Type        string      `bun:"type"                        json:"-"`
+	Reason      string      `bun:"reason,nullzero"             json:"reason,omitempty"`
 }
@@ -0,0 +1,38 @@
+package transaction
+
+import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+const (
+	WithdrawalResultSuccess        = "SUCCESS"
+	WithdrawalResultNotEnoughMoney = "NOT_ENOUGH_MONEY"
+)
+
+// WithdrawalRequest - результат запроса на списание, сохранённый по ключу Idempotency-Key,
+// чтобы повторный запрос получил тот же ответ, что и исходный
+type WithdrawalRequest struct {
+	bun.BaseModel `bun:"table:withdrawal_requests,alias:wr"`
+
+	UserID         uuid.UUID   `bun:"user_id,type:uuid,pk"`
+	IdempotencyKey string      `bun:"idempotency_key,pk"`
+	OrderNumber    string      `bun:"order,notnull"`
+	Sum            money.Money `bun:"sum,notnull"`
+	Result         string      `bun:"result,notnull"`
+	CreatedAt      time.Time   `bun:"created_at,notnull"`
+}
+
+// Replay возвращает исход исходного запроса, если повторный запрос совпадает с ним по заказу и сумме
+func (r WithdrawalRequest) Replay(orderNumber string, sum money.Money) error {
+	if r.OrderNumber != orderNumber || r.Sum != sum {
+		return &IdempotencyKeyReused{Key: r.IdempotencyKey}
+	}
+	if r.Result == WithdrawalResultNotEnoughMoney {
+		return &NotEnoughMoney{}
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package user
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type IncorrectLoginOrPassword struct {
 	Login    string
@@ -18,3 +22,50 @@ type LoginAlreadyExists struct {
 func (e LoginAlreadyExists) Error() string {
 	return fmt.Sprintf("Login %s already exists", e.Login)
 }
+
+type LoginLocked struct {
+	Login      string
+	RetryAfter time.Duration
+}
+
+func (e LoginLocked) Error() string {
+	return fmt.Sprintf("Too many failed login attempts for %s, retry after %s", e.Login, e.RetryAfter)
+}
+
+type InvalidCredentials struct {
+	Violations []Violation
+}
+
+func (e InvalidCredentials) Error() string {
+	codes := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		codes[i] = v.Field + ": " + v.Code
+	}
+	return fmt.Sprintf("Invalid credentials: %s", strings.Join(codes, ", "))
+}
+
+type WrongPassword struct{}
+
+func (WrongPassword) Error() string {
+	return "Wrong password"
+}
+
+type NoSuchUser struct {
+	ID    string
+	Login string
+}
+
+func (e NoSuchUser) Error() string {
+	if e.Login != "" {
+		return fmt.Sprintf("User with login %s not found", e.Login)
+	}
+	return fmt.Sprintf("User %s not found", e.ID)
+}
+
+type InvalidRole struct {
+	Role Role
+}
+
+func (e InvalidRole) Error() string {
+	return fmt.Sprintf("Invalid role %s", e.Role)
+}
@@ -0,0 +1,55 @@
+package user
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+const (
+	LoginFailureWrongPassword = "WRONG_PASSWORD"
+	LoginFailureUnknownLogin  = "UNKNOWN_LOGIN"
+)
+
+// LoginAttempts неудачные попытки входа по логину или по IP адресу
+type LoginAttempts struct {
+	Failures      int
+	LastFailureAt time.Time
+}
+
+// Lockout блокировка входа за перебор паролей: первые FreeAttempts неудачных попыток не ограничиваются,
+// после них каждая следующая удваивает блокировку, начиная с Base, но не больше Max
+type Lockout struct {
+	FreeAttempts int
+	Base         time.Duration
+	Max          time.Duration
+}
+
+// Remaining сколько ещё продлится блокировка после последней неудачной попытки
+func (l Lockout) Remaining(attempts LoginAttempts, now time.Time) time.Duration {
+	if attempts.Failures < l.FreeAttempts {
+		return 0
+	}
+	lockout := l.Max
+	if shift := attempts.Failures - l.FreeAttempts; shift < 30 {
+		if d := l.Base << shift; d < l.Max {
+			lockout = d
+		}
+	}
+	if remaining := attempts.LastFailureAt.Add(lockout).Sub(now); remaining > 0 {
+		return remaining
+	}
+
+	return 0
+}
+
+// LoginFailure запись журнала неудачных попыток входа
+type LoginFailure struct {
+	bun.BaseModel `bun:"table:login_failures,alias:lf"`
+
+	ID          uuid.UUID `bun:"id,type:uuid,pk"`
+	Login       string    `bun:"login,notnull"`
+	IP          string    `bun:"ip,notnull"`
+	Reason      string    `bun:"reason,notnull"`
+	AttemptedAt time.Time `bun:"attempted_at,notnull"`
+}
@@ -0,0 +1,39 @@
+package user
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLockout_Remaining(t *testing.T) {
+	lockout := Lockout{FreeAttempts: 5, Base: time.Second, Max: 15 * time.Minute}
+	now := time.Now()
+	tests := []struct {
+		name     string
+		attempts LoginAttempts
+		want     time.Duration
+	}{
+		{name: "Test_1. Свободные попытки", attempts: LoginAttempts{Failures: 4, LastFailureAt: now}},
+		{name: "Test_2. Первая блокировка", attempts: LoginAttempts{Failures: 5, LastFailureAt: now}, want: time.Second},
+		{
+			name: "Test_3. Блокировка удваивается", attempts: LoginAttempts{Failures: 8, LastFailureAt: now},
+			want: 8 * time.Second,
+		},
+		{
+			name: "Test_4. Блокировка не больше максимальной", attempts: LoginAttempts{Failures: 100, LastFailureAt: now},
+			want: 15 * time.Minute,
+		},
+		{
+			name:     "Test_5. Блокировка истекла",
+			attempts: LoginAttempts{Failures: 5, LastFailureAt: now.Add(-time.Minute)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				require.Equal(t, tt.want, lockout.Remaining(tt.attempts, now))
+			},
+		)
+	}
+}
@@ -0,0 +1,142 @@
+package user
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ViolationRequired       = "required"
+	ViolationTooShort       = "too_short"
+	ViolationTooLong        = "too_long"
+	ViolationInvalidFormat  = "invalid_format"
+	ViolationTooSimple      = "too_simple"
+	ViolationBlocklisted    = "blocklisted"
+	ViolationSameAsLogin    = "same_as_login"
+	ViolationSameAsPrevious = "same_as_previous"
+)
+
+// Violation нарушение правил для одного поля
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Policy правила для логина и пароля. Blocklist содержит утёкшие пароли в нижнем регистре.
+type Policy struct {
+	LoginMinLength      int
+	LoginMaxLength      int
+	LoginPattern        *regexp.Regexp
+	PasswordMinLength   int
+	PasswordMaxLength   int
+	PasswordCharClasses int
+	Blocklist           map[string]struct{}
+}
+
+func (p Policy) ValidateLogin(login string) []Violation {
+	length := utf8.RuneCountInString(login)
+	switch {
+	case length == 0:
+		return []Violation{{Field: "login", Code: ViolationRequired, Message: "login is required"}}
+	case length < p.LoginMinLength:
+		return []Violation{
+			{
+				Field: "login", Code: ViolationTooShort,
+				Message: fmt.Sprintf("login must be at least %d characters long", p.LoginMinLength),
+			},
+		}
+	case p.LoginMaxLength > 0 && length > p.LoginMaxLength:
+		return []Violation{
+			{
+				Field: "login", Code: ViolationTooLong,
+				Message: fmt.Sprintf("login must be at most %d characters long", p.LoginMaxLength),
+			},
+		}
+	case p.LoginPattern != nil && !p.LoginPattern.MatchString(login):
+		return []Violation{
+			{
+				Field: "login", Code: ViolationInvalidFormat,
+				Message: fmt.Sprintf("login must match %s", p.LoginPattern.String()),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (p Policy) ValidatePassword(login, password string) []Violation {
+	length := utf8.RuneCountInString(password)
+	var violations []Violation
+	switch {
+	case length == 0:
+		return []Violation{{Field: "password", Code: ViolationRequired, Message: "password is required"}}
+	case length < p.PasswordMinLength:
+		violations = append(
+			violations, Violation{
+				Field: "password", Code: ViolationTooShort,
+				Message: fmt.Sprintf("password must be at least %d characters long", p.PasswordMinLength),
+			},
+		)
+	// Ограничение в байтах: bcrypt учитывает только первые 72 байта пароля
+	case p.PasswordMaxLength > 0 && len(password) > p.PasswordMaxLength:
+		violations = append(
+			violations, Violation{
+				Field: "password", Code: ViolationTooLong,
+				Message: fmt.Sprintf("password must be at most %d bytes long", p.PasswordMaxLength),
+			},
+		)
+	}
+	if classes := charClasses(password); classes < p.PasswordCharClasses {
+		violations = append(
+			violations, Violation{
+				Field: "password", Code: ViolationTooSimple,
+				Message: fmt.Sprintf(
+					"password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols",
+					p.PasswordCharClasses,
+				),
+			},
+		)
+	}
+	if login != "" && strings.EqualFold(login, password) {
+		violations = append(
+			violations, Violation{Field: "password", Code: ViolationSameAsLogin, Message: "password must differ from login"},
+		)
+	}
+	if _, ok := p.Blocklist[strings.ToLower(password)]; ok {
+		violations = append(
+			violations, Violation{
+				Field: "password", Code: ViolationBlocklisted, Message: "password is known from data breaches",
+			},
+		)
+	}
+
+	return violations
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			classes++
+		}
+	}
+
+	return classes
+}
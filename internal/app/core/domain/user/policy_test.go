@@ -0,0 +1,82 @@
+package user
+
+import (
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPolicy_ValidatePassword(t *testing.T) {
+	policy := Policy{
+		PasswordMinLength:   8,
+		PasswordMaxLength:   72,
+		PasswordCharClasses: 3,
+		Blocklist:           map[string]struct{}{"qwerty123!": {}},
+	}
+	tests := []struct {
+		name      string
+		login     string
+		password  string
+		wantCodes []string
+	}{
+		{name: "Test_1. Надёжный пароль", login: "gopher", password: "Correct-Horse-7"},
+		{name: "Test_2. Пустой пароль", login: "gopher", password: "", wantCodes: []string{ViolationRequired}},
+		{
+			name: "Test_3. Короткий и простой пароль", login: "gopher", password: "abc",
+			wantCodes: []string{ViolationTooShort, ViolationTooSimple},
+		},
+		{
+			name: "Test_4. Длиннее 72 байт", login: "gopher", password: "Aa1!" + strings.Repeat("я", 40),
+			wantCodes: []string{ViolationTooLong},
+		},
+		{
+			name: "Test_5. Совпадает с логином", login: "Gopher-2023", password: "gopher-2023",
+			wantCodes: []string{ViolationSameAsLogin},
+		},
+		{
+			name: "Test_6. Пароль из списка утёкших", login: "gopher", password: "Qwerty123!",
+			wantCodes: []string{ViolationBlocklisted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var codes []string
+				for _, v := range policy.ValidatePassword(tt.login, tt.password) {
+					require.Equal(t, "password", v.Field)
+					codes = append(codes, v.Code)
+				}
+				require.Equal(t, tt.wantCodes, codes)
+			},
+		)
+	}
+}
+
+func TestPolicy_ValidateLogin(t *testing.T) {
+	policy := Policy{LoginMinLength: 3, LoginMaxLength: 8, LoginPattern: regexp.MustCompile(`^[a-z0-9]+$`)}
+	tests := []struct {
+		name     string
+		login    string
+		wantCode string
+	}{
+		{name: "Test_1. Корректный логин", login: "gopher"},
+		{name: "Test_2. Пустой логин", login: "", wantCode: ViolationRequired},
+		{name: "Test_3. Короткий логин", login: "go", wantCode: ViolationTooShort},
+		{name: "Test_4. Длинный логин", login: "gophermart", wantCode: ViolationTooLong},
+		{name: "Test_5. Недопустимые символы", login: "go pher", wantCode: ViolationInvalidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				violations := policy.ValidateLogin(tt.login)
+				if tt.wantCode == "" {
+					require.Empty(t, violations)
+					return
+				}
+				require.Len(t, violations, 1)
+				require.Equal(t, tt.wantCode, violations[0].Code)
+			},
+		)
+	}
+}
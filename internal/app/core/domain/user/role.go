@@ -0,0 +1,51 @@
+package user
+
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleSupport Role = "support"
+	RoleAdmin   Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionViewUsers        Permission = "users:view"
+	PermissionManageRoles      Permission = "users:roles"
+	PermissionViewOrders       Permission = "orders:view"
+	PermissionRequeueOrders    Permission = "orders:requeue"
+	PermissionViewTransactions Permission = "transactions:view"
+	PermissionAdjustBalance    Permission = "balance:adjust"
+	PermissionViewMetrics      Permission = "metrics:view"
+)
+
+// rolePermissions перечисляет права ролей. Обычному пользователю доступны только его собственные данные,
+// поэтому у него нет прав на административные запросы.
+var rolePermissions = map[Role]map[Permission]bool{
+	RoleUser: {},
+	RoleSupport: {
+		PermissionViewUsers:        true,
+		PermissionViewOrders:       true,
+		PermissionViewTransactions: true,
+		PermissionAdjustBalance:    true,
+	},
+	RoleAdmin: {
+		PermissionViewUsers:        true,
+		PermissionManageRoles:      true,
+		PermissionViewOrders:       true,
+		PermissionRequeueOrders:    true,
+		PermissionViewTransactions: true,
+		PermissionAdjustBalance:    true,
+		PermissionViewMetrics:      true,
+	},
+}
+
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	return rolePermissions[r][p]
+}
@@ -11,4 +11,5 @@ type User struct {
 	ID       uuid.UUID `bun:"id,type:uuid,pk"             json:"id"`
 	Login    string    `bun:"login,notnull,unique"        json:"login"`
 	Password string    `bun:"password,notnull"            json:"password"`
+	Role     Role      `bun:"role,notnull,default:'user'" json:"role"`
 }
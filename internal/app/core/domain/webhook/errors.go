@@ -0,0 +1,19 @@
+package webhook
+
+import "fmt"
+
+type InvalidEndpoint struct {
+	Message string
+}
+
+func (e InvalidEndpoint) Error() string {
+	return fmt.Sprintf("Invalid webhook endpoint: %s", e.Message)
+}
+
+type NoSuchEndpoint struct {
+	ID string
+}
+
+func (e NoSuchEndpoint) Error() string {
+	return fmt.Sprintf("Webhook endpoint %s not found", e.ID)
+}
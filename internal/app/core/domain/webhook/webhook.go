@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/gofrs/uuid"
+	"github.com/uptrace/bun"
+	"net/url"
+	"time"
+)
+
+// События, на которые можно подписаться
+const (
+	EventOrderProcessed   = "order.processed"
+	EventOrderInvalid     = "order.invalid"
+	EventBalanceWithdrawn = "balance.withdrawn"
+)
+
+// Статусы доставки
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	DeliveryFailed    = "FAILED"
+)
+
+const (
+	minSecretLength = 16
+	maxSecretLength = 256
+)
+
+// Endpoint адрес, на который доставляются события пользователя. Secret подписывает тело запроса.
+type Endpoint struct {
+	bun.BaseModel `bun:"table:webhook_endpoints,alias:we"`
+
+	ID        uuid.UUID `bun:"id,type:uuid,pk"              json:"id"`
+	UserID    uuid.UUID `bun:"user_id,type:uuid,notnull"    json:"-"`
+	URL       string    `bun:"url,notnull"                  json:"url"`
+	Secret    string    `bun:"secret,notnull"               json:"-"`
+	Events    []string  `bun:"events,array,notnull"         json:"events"`
+	CreatedAt time.Time `bun:"created_at,notnull"           json:"created_at"`
+}
+
+// Validate проверяет адрес и список событий. Пустой секрет заменяется случайным.
+func (e *Endpoint) Validate() error {
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &InvalidEndpoint{Message: "url must be an absolute http or https URL"}
+	}
+	if len(e.Events) == 0 {
+		return &InvalidEndpoint{Message: "at least one event is required"}
+	}
+	for _, event := range e.Events {
+		if event != EventOrderProcessed && event != EventOrderInvalid && event != EventBalanceWithdrawn {
+			return &InvalidEndpoint{Message: "unknown event " + event}
+		}
+	}
+	if e.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		e.Secret = hex.EncodeToString(secret)
+	}
+	if len(e.Secret) < minSecretLength || len(e.Secret) > maxSecretLength {
+		return &InvalidEndpoint{Message: "secret must be from 16 to 256 characters long"}
+	}
+
+	return nil
+}
+
+// Event событие для подписчиков пользователя UserID. Data попадает в тело запроса как есть.
+type Event struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Type      string    `json:"type"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery доставка события на один адрес. Тело запроса сохраняется при постановке в очередь,
+// поэтому повторные попытки отправляют его без изменений.
+type Delivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries,alias:wd"`
+
+	ID             uuid.UUID       `bun:"id,type:uuid,pk"                   json:"id"`
+	EndpointID     uuid.UUID       `bun:"endpoint_id,type:uuid,notnull"     json:"endpoint_id"`
+	EventID        uuid.UUID       `bun:"event_id,type:uuid,notnull"        json:"event_id"`
+	Event          string          `bun:"event,notnull"                     json:"event"`
+	Payload        json.RawMessage `bun:"payload,type:jsonb,notnull"        json:"payload"`
+	Status         string          `bun:"status,notnull"                    json:"status"`
+	Attempts       int             `bun:"attempts,notnull,default:0"        json:"attempts"`
+	NextAttemptAt  time.Time       `bun:"next_attempt_at,nullzero"          json:"next_attempt_at,omitempty"`
+	LockedUntil    time.Time       `bun:"locked_until,nullzero"             json:"-"`
+	LastStatusCode int             `bun:"last_status_code,nullzero"         json:"last_status_code,omitempty"`
+	LastError      string          `bun:"last_error,nullzero"               json:"last_error,omitempty"`
+	CreatedAt      time.Time       `bun:"created_at,notnull"                json:"created_at"`
+	DeliveredAt    time.Time       `bun:"delivered_at,nullzero"             json:"delivered_at,omitempty"`
+
+	// Адрес и секрет подгружаются вместе с доставкой, которую пора отправить
+	URL    string `bun:"url,scanonly"    json:"-"`
+	Secret string `bun:"secret,scanonly" json:"-"`
+}
+
+// OrderData данные событий order.processed и order.invalid
+type OrderData struct {
+	Number  string      `json:"number"`
+	Status  string      `json:"status"`
+	Accrual money.Money `json:"accrual,omitempty"`
+}
+
+// WithdrawalData данные события balance.withdrawn
+type WithdrawalData struct {
+	Order string      `json:"order"`
+	Sum   money.Money `json:"sum"`
+}
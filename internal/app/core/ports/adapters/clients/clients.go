@@ -1,7 +1,19 @@
 package clients
 
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+)
+
 type LoyalClient interface {
-	GetOrderProcessingInfo(order string) (OrderLoyaltyInfo, error)
+	GetOrderProcessingInfo(ctx context.Context, order string) (OrderLoyaltyInfo, error)
+}
+
+// WebhookClient отправляет тело события на адрес подписчика и возвращает код ответа
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=WebhookClient
+type WebhookClient interface {
+	Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error)
 }
 
 const (
@@ -12,7 +24,7 @@ const (
 )
 
 type OrderLoyaltyInfo struct {
-	Order   string  `json:"order"`
-	Status  string  `json:"status"`
-	Accrual float64 `json:"accrual"`
+	Order   string      `json:"order"`
+	Status  string      `json:"status"`
+	Accrual money.Money `json:"accrual"`
 }
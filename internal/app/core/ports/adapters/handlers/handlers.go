@@ -11,15 +11,38 @@ type (
 	UserHandler interface {
 		Register(w http.ResponseWriter, r *http.Request)
 		Login(w http.ResponseWriter, r *http.Request)
+		Refresh(w http.ResponseWriter, r *http.Request)
+		Logout(w http.ResponseWriter, r *http.Request)
+		LogoutAll(w http.ResponseWriter, r *http.Request)
+		ChangePassword(w http.ResponseWriter, r *http.Request)
 	}
 	OrderHandler interface {
 		LoadOrder(w http.ResponseWriter, r *http.Request)
+		LoadOrders(w http.ResponseWriter, r *http.Request)
 		GetUserOrders(w http.ResponseWriter, r *http.Request)
+		GetUserOrder(w http.ResponseWriter, r *http.Request)
+		StreamEvents(w http.ResponseWriter, r *http.Request)
 	}
 	BalanceHandler interface {
 		GetUserBalance(w http.ResponseWriter, r *http.Request)
 		Withdraw(w http.ResponseWriter, r *http.Request)
 		GetWithdrawals(w http.ResponseWriter, r *http.Request)
+		GetTransactions(w http.ResponseWriter, r *http.Request)
+	}
+	WebhookHandler interface {
+		CreateEndpoint(w http.ResponseWriter, r *http.Request)
+		GetEndpoints(w http.ResponseWriter, r *http.Request)
+		DeleteEndpoint(w http.ResponseWriter, r *http.Request)
+		GetDeliveries(w http.ResponseWriter, r *http.Request)
+	}
+	AdminHandler interface {
+		FindUser(w http.ResponseWriter, r *http.Request)
+		GetUser(w http.ResponseWriter, r *http.Request)
+		SetRole(w http.ResponseWriter, r *http.Request)
+		GetUserOrders(w http.ResponseWriter, r *http.Request)
+		GetUserTransactions(w http.ResponseWriter, r *http.Request)
+		AdjustBalance(w http.ResponseWriter, r *http.Request)
+		RequeueOrder(w http.ResponseWriter, r *http.Request)
 	}
 )
 
@@ -27,9 +50,15 @@ type (
 
 type (
 	OrderFetchInfoHandler interface {
-		FetchOrderStatus(ctx context.Context)
+		FetchOrderStatus(ctx context.Context, stop <-chan struct{})
 	}
 	OrderUpdateHandler interface {
-		UpdateStatusAndBalance(ctx context.Context)
+		UpdateStatusAndBalance(ctx context.Context, stop <-chan struct{})
+	}
+	WebhookDeliveryHandler interface {
+		DeliverWebhooks(ctx context.Context, stop <-chan struct{})
+	}
+	CleanupHandler interface {
+		Cleanup(ctx context.Context, stop <-chan struct{})
 	}
 )
@@ -1,7 +1,30 @@
 package repository
 
+import "errors"
+
+// Ограничения уникальности, нарушение которых сервисы превращают в ошибки предметной области
+const (
+	ConstraintUserLogin   = "users_login_key"
+	ConstraintOrderNumber = "orders_number_key"
+)
+
 type NoResultError struct{}
 
 func (NoResultError) Error() string {
 	return "No result"
 }
+
+// UniqueViolation означает, что запись нарушает ограничение уникальности Constraint
+type UniqueViolation struct {
+	Constraint string
+}
+
+func (e UniqueViolation) Error() string {
+	return "Unique constraint violation: " + e.Constraint
+}
+
+// ViolatesUnique сообщает, что err нарушает именно ограничение constraint, а не любое другое
+func ViolatesUnique(err error, constraint string) bool {
+	var violation UniqueViolation
+	return errors.As(err, &violation) && violation.Constraint == constraint
+}
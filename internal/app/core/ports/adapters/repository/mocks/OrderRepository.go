@@ -5,14 +5,16 @@ package mocks
 import (
 	context "context"
 
-	bun "github.com/uptrace/bun"
-
 	mock "github.com/stretchr/testify/mock"
 
+	bun "github.com/uptrace/bun"
+
 	order "github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
 
 	service "github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 
+	time "time"
+
 	transaction "github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
 
 	uuid "github.com/gofrs/uuid"
@@ -23,13 +25,13 @@ type OrderRepository struct {
 	mock.Mock
 }
 
-// BatchUpdateOrdersAndBalance provides a mock function with given fields: ctx, orders, transactions
-func (_m *OrderRepository) BatchUpdateOrdersAndBalance(ctx context.Context, orders []order.Order, transactions []transaction.Transaction) error {
-	ret := _m.Called(ctx, orders, transactions)
+// AddStatusChanges provides a mock function with given fields: ctx, changes, tx
+func (_m *OrderRepository) AddStatusChanges(ctx context.Context, changes []order.StatusChange, tx bun.IDB) error {
+	ret := _m.Called(ctx, changes, tx)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, []order.Order, []transaction.Transaction) error); ok {
-		r0 = rf(ctx, orders, transactions)
+	if rf, ok := ret.Get(0).(func(context.Context, []order.StatusChange, bun.IDB) error); ok {
+		r0 = rf(ctx, changes, tx)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -37,39 +39,51 @@ func (_m *OrderRepository) BatchUpdateOrdersAndBalance(ctx context.Context, orde
 	return r0
 }
 
-// CreateOrder provides a mock function with given fields: ctx, _a1, tx
-func (_m *OrderRepository) CreateOrder(ctx context.Context, _a1 order.Order, tx bun.IDB) error {
-	ret := _m.Called(ctx, _a1, tx)
+// BatchUpdateOrdersAndBalance provides a mock function with given fields: ctx, orders, transactions, tx
+func (_m *OrderRepository) BatchUpdateOrdersAndBalance(ctx context.Context, orders []order.Order, transactions []transaction.Transaction, tx bun.IDB) ([]string, error) {
+	ret := _m.Called(ctx, orders, transactions, tx)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, order.Order, bun.IDB) error); ok {
-		r0 = rf(ctx, _a1, tx)
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []order.Order, []transaction.Transaction, bun.IDB) ([]string, error)); ok {
+		return rf(ctx, orders, transactions, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []order.Order, []transaction.Transaction, bun.IDB) []string); ok {
+		r0 = rf(ctx, orders, transactions, tx)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, []order.Order, []transaction.Transaction, bun.IDB) error); ok {
+		r1 = rf(ctx, orders, transactions, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetAllByStatuses provides a mock function with given fields: ctx, statuses
-func (_m *OrderRepository) GetAllByStatuses(ctx context.Context, statuses []string) ([]order.Order, error) {
-	ret := _m.Called(ctx, statuses)
+// ClaimForPolling provides a mock function with given fields: ctx, statuses, limit, lease
+func (_m *OrderRepository) ClaimForPolling(ctx context.Context, statuses []string, limit int, lease time.Duration) ([]order.Order, error) {
+	ret := _m.Called(ctx, statuses, limit, lease)
 
 	var r0 []order.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]order.Order, error)); ok {
-		return rf(ctx, statuses)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, int, time.Duration) ([]order.Order, error)); ok {
+		return rf(ctx, statuses, limit, lease)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, []string) []order.Order); ok {
-		r0 = rf(ctx, statuses)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, int, time.Duration) []order.Order); ok {
+		r0 = rf(ctx, statuses, limit, lease)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]order.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
-		r1 = rf(ctx, statuses)
+	if rf, ok := ret.Get(1).(func(context.Context, []string, int, time.Duration) error); ok {
+		r1 = rf(ctx, statuses, limit, lease)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -77,25 +91,39 @@ func (_m *OrderRepository) GetAllByStatuses(ctx context.Context, statuses []stri
 	return r0, r1
 }
 
-// GetAllByUser provides a mock function with given fields: ctx, userID
-func (_m *OrderRepository) GetAllByUser(ctx context.Context, userID uuid.UUID) ([]service.OrderInfo, error) {
-	ret := _m.Called(ctx, userID)
+// CreateOrder provides a mock function with given fields: ctx, _a1, tx
+func (_m *OrderRepository) CreateOrder(ctx context.Context, _a1 order.Order, tx bun.IDB) error {
+	ret := _m.Called(ctx, _a1, tx)
 
-	var r0 []service.OrderInfo
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, order.Order, bun.IDB) error); ok {
+		r0 = rf(ctx, _a1, tx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateOrdersIfNotExist provides a mock function with given fields: ctx, orders, tx
+func (_m *OrderRepository) CreateOrdersIfNotExist(ctx context.Context, orders []order.Order, tx bun.IDB) ([]string, error) {
+	ret := _m.Called(ctx, orders, tx)
+
+	var r0 []string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]service.OrderInfo, error)); ok {
-		return rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, []order.Order, bun.IDB) ([]string, error)); ok {
+		return rf(ctx, orders, tx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []service.OrderInfo); ok {
-		r0 = rf(ctx, userID)
+	if rf, ok := ret.Get(0).(func(context.Context, []order.Order, bun.IDB) []string); ok {
+		r0 = rf(ctx, orders, tx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]service.OrderInfo)
+			r0 = ret.Get(0).([]string)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
-		r1 = rf(ctx, userID)
+	if rf, ok := ret.Get(1).(func(context.Context, []order.Order, bun.IDB) error); ok {
+		r1 = rf(ctx, orders, tx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -103,25 +131,25 @@ func (_m *OrderRepository) GetAllByUser(ctx context.Context, userID uuid.UUID) (
 	return r0, r1
 }
 
-// GetBatchByNumbers provides a mock function with given fields: ctx, orderNumbers
-func (_m *OrderRepository) GetBatchByNumbers(ctx context.Context, orderNumbers []string) ([]order.Order, error) {
-	ret := _m.Called(ctx, orderNumbers)
+// GetBatchByNumbers provides a mock function with given fields: ctx, orderNumbers, tx
+func (_m *OrderRepository) GetBatchByNumbers(ctx context.Context, orderNumbers []string, tx bun.IDB) ([]order.Order, error) {
+	ret := _m.Called(ctx, orderNumbers, tx)
 
 	var r0 []order.Order
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]order.Order, error)); ok {
-		return rf(ctx, orderNumbers)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, bun.IDB) ([]order.Order, error)); ok {
+		return rf(ctx, orderNumbers, tx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, []string) []order.Order); ok {
-		r0 = rf(ctx, orderNumbers)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, bun.IDB) []order.Order); ok {
+		r0 = rf(ctx, orderNumbers, tx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]order.Order)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
-		r1 = rf(ctx, orderNumbers)
+	if rf, ok := ret.Get(1).(func(context.Context, []string, bun.IDB) error); ok {
+		r1 = rf(ctx, orderNumbers, tx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -153,13 +181,153 @@ func (_m *OrderRepository) GetByNumber(ctx context.Context, number string, tx bu
 	return r0, r1
 }
 
-// UpdateOrder provides a mock function with given fields: ctx, _a1, tx
-func (_m *OrderRepository) UpdateOrder(ctx context.Context, _a1 order.Order, tx bun.IDB) error {
-	ret := _m.Called(ctx, _a1, tx)
+// GetPageByUser provides a mock function with given fields: ctx, userID, filter
+func (_m *OrderRepository) GetPageByUser(ctx context.Context, userID uuid.UUID, filter order.ListFilter) ([]service.OrderInfo, error) {
+	ret := _m.Called(ctx, userID, filter)
+
+	var r0 []service.OrderInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, order.ListFilter) ([]service.OrderInfo, error)); ok {
+		return rf(ctx, userID, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, order.ListFilter) []service.OrderInfo); ok {
+		r0 = rf(ctx, userID, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.OrderInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, order.ListFilter) error); ok {
+		r1 = rf(ctx, userID, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetStatusEventsByUser provides a mock function with given fields: ctx, userID, after, limit
+func (_m *OrderRepository) GetStatusEventsByUser(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]order.StatusEvent, error) {
+	ret := _m.Called(ctx, userID, after, limit)
+
+	var r0 []order.StatusEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int64, int) ([]order.StatusEvent, error)); ok {
+		return rf(ctx, userID, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, int64, int) []order.StatusEvent); ok {
+		r0 = rf(ctx, userID, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]order.StatusEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, int64, int) error); ok {
+		r1 = rf(ctx, userID, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetStatusHistory provides a mock function with given fields: ctx, orderID
+func (_m *OrderRepository) GetStatusHistory(ctx context.Context, orderID uuid.UUID) ([]order.StatusChange, error) {
+	ret := _m.Called(ctx, orderID)
+
+	var r0 []order.StatusChange
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]order.StatusChange, error)); ok {
+		return rf(ctx, orderID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []order.StatusChange); ok {
+		r0 = rf(ctx, orderID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]order.StatusChange)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, orderID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserOrderInfo provides a mock function with given fields: ctx, userID, number
+func (_m *OrderRepository) GetUserOrderInfo(ctx context.Context, userID uuid.UUID, number string) (service.OrderInfo, error) {
+	ret := _m.Called(ctx, userID, number)
+
+	var r0 service.OrderInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) (service.OrderInfo, error)); ok {
+		return rf(ctx, userID, number)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) service.OrderInfo); ok {
+		r0 = rf(ctx, userID, number)
+	} else {
+		r0 = ret.Get(0).(service.OrderInfo)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, userID, number)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkInvalid provides a mock function with given fields: ctx, id, tx
+func (_m *OrderRepository) MarkInvalid(ctx context.Context, id uuid.UUID, tx bun.IDB) (bool, error) {
+	ret := _m.Called(ctx, id, tx)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bun.IDB) (bool, error)); ok {
+		return rf(ctx, id, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bun.IDB) bool); ok {
+		r0 = rf(ctx, id, tx)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, bun.IDB) error); ok {
+		r1 = rf(ctx, id, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RequeueForPolling provides a mock function with given fields: ctx, orderID, nextCheckAt
+func (_m *OrderRepository) RequeueForPolling(ctx context.Context, orderID uuid.UUID, nextCheckAt time.Time) error {
+	ret := _m.Called(ctx, orderID, nextCheckAt)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, order.Order, bun.IDB) error); ok {
-		r0 = rf(ctx, _a1, tx)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, orderID, nextCheckAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ScheduleNextCheck provides a mock function with given fields: ctx, orderID, nextCheckAt
+func (_m *OrderRepository) ScheduleNextCheck(ctx context.Context, orderID uuid.UUID, nextCheckAt time.Time) error {
+	ret := _m.Called(ctx, orderID, nextCheckAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time) error); ok {
+		r0 = rf(ctx, orderID, nextCheckAt)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -9,6 +9,8 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 
+	money "github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+
 	transaction "github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
 
 	uuid "github.com/gofrs/uuid"
@@ -33,19 +35,59 @@ func (_m *TransactionRepository) CreateTransaction(ctx context.Context, _a1 tran
 	return r0
 }
 
+// CreateWithdrawalRequest provides a mock function with given fields: ctx, request, tx
+func (_m *TransactionRepository) CreateWithdrawalRequest(ctx context.Context, request transaction.WithdrawalRequest, tx bun.IDB) error {
+	ret := _m.Called(ctx, request, tx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, transaction.WithdrawalRequest, bun.IDB) error); ok {
+		r0 = rf(ctx, request, tx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAllByUser provides a mock function with given fields: ctx, userID
+func (_m *TransactionRepository) GetAllByUser(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []transaction.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]transaction.Transaction, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []transaction.Transaction); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]transaction.Transaction)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetBalanceByUser provides a mock function with given fields: ctx, userID, tx
-func (_m *TransactionRepository) GetBalanceByUser(ctx context.Context, userID uuid.UUID, tx bun.IDB) (float64, error) {
+func (_m *TransactionRepository) GetBalanceByUser(ctx context.Context, userID uuid.UUID, tx bun.IDB) (money.Money, error) {
 	ret := _m.Called(ctx, userID, tx)
 
-	var r0 float64
+	var r0 money.Money
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bun.IDB) (float64, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bun.IDB) (money.Money, error)); ok {
 		return rf(ctx, userID, tx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bun.IDB) float64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bun.IDB) money.Money); ok {
 		r0 = rf(ctx, userID, tx)
 	} else {
-		r0 = ret.Get(0).(float64)
+		r0 = ret.Get(0).(money.Money)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, bun.IDB) error); ok {
@@ -57,19 +99,93 @@ func (_m *TransactionRepository) GetBalanceByUser(ctx context.Context, userID uu
 	return r0, r1
 }
 
+// GetHistory provides a mock function with given fields: ctx, userID, filter
+func (_m *TransactionRepository) GetHistory(ctx context.Context, userID uuid.UUID, filter transaction.HistoryFilter) ([]transaction.HistoryEntry, error) {
+	ret := _m.Called(ctx, userID, filter)
+
+	var r0 []transaction.HistoryEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, transaction.HistoryFilter) ([]transaction.HistoryEntry, error)); ok {
+		return rf(ctx, userID, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, transaction.HistoryFilter) []transaction.HistoryEntry); ok {
+		r0 = rf(ctx, userID, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]transaction.HistoryEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, transaction.HistoryFilter) error); ok {
+		r1 = rf(ctx, userID, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetWithdrawalByOrder provides a mock function with given fields: ctx, userID, orderNumber, tx
+func (_m *TransactionRepository) GetWithdrawalByOrder(ctx context.Context, userID uuid.UUID, orderNumber string, tx bun.IDB) (transaction.Transaction, error) {
+	ret := _m.Called(ctx, userID, orderNumber, tx)
+
+	var r0 transaction.Transaction
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, bun.IDB) (transaction.Transaction, error)); ok {
+		return rf(ctx, userID, orderNumber, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, bun.IDB) transaction.Transaction); ok {
+		r0 = rf(ctx, userID, orderNumber, tx)
+	} else {
+		r0 = ret.Get(0).(transaction.Transaction)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string, bun.IDB) error); ok {
+		r1 = rf(ctx, userID, orderNumber, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetWithdrawalRequest provides a mock function with given fields: ctx, userID, key, tx
+func (_m *TransactionRepository) GetWithdrawalRequest(ctx context.Context, userID uuid.UUID, key string, tx bun.IDB) (transaction.WithdrawalRequest, error) {
+	ret := _m.Called(ctx, userID, key, tx)
+
+	var r0 transaction.WithdrawalRequest
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, bun.IDB) (transaction.WithdrawalRequest, error)); ok {
+		return rf(ctx, userID, key, tx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, bun.IDB) transaction.WithdrawalRequest); ok {
+		r0 = rf(ctx, userID, key, tx)
+	} else {
+		r0 = ret.Get(0).(transaction.WithdrawalRequest)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string, bun.IDB) error); ok {
+		r1 = rf(ctx, userID, key, tx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetWithdrawalSumByUser provides a mock function with given fields: ctx, userID
-func (_m *TransactionRepository) GetWithdrawalSumByUser(ctx context.Context, userID uuid.UUID) (float64, error) {
+func (_m *TransactionRepository) GetWithdrawalSumByUser(ctx context.Context, userID uuid.UUID) (money.Money, error) {
 	ret := _m.Called(ctx, userID)
 
-	var r0 float64
+	var r0 money.Money
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (float64, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (money.Money, error)); ok {
 		return rf(ctx, userID)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) float64); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) money.Money); ok {
 		r0 = rf(ctx, userID)
 	} else {
-		r0 = ret.Get(0).(float64)
+		r0 = ret.Get(0).(money.Money)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
@@ -107,6 +223,20 @@ func (_m *TransactionRepository) GetWithdrawalsByUser(ctx context.Context, userI
 	return r0, r1
 }
 
+// LockBalance provides a mock function with given fields: ctx, userID, tx
+func (_m *TransactionRepository) LockBalance(ctx context.Context, userID uuid.UUID, tx bun.IDB) error {
+	ret := _m.Called(ctx, userID, tx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bun.IDB) error); ok {
+		r0 = rf(ctx, userID, tx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewTransactionRepository creates a new instance of TransactionRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewTransactionRepository(t interface {
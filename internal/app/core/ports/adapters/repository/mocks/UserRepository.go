@@ -7,7 +7,11 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 
+	bun "github.com/uptrace/bun"
+
 	user "github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+
+	uuid "github.com/gofrs/uuid"
 )
 
 // UserRepository is an autogenerated mock type for the UserRepository type
@@ -39,6 +43,30 @@ func (_m *UserRepository) CreateUser(ctx context.Context, _a1 user.User) (user.U
 	return r0, r1
 }
 
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (user.User, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 user.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (user.User, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) user.User); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(user.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetByLogin provides a mock function with given fields: ctx, login
 func (_m *UserRepository) GetByLogin(ctx context.Context, login string) (user.User, error) {
 	ret := _m.Called(ctx, login)
@@ -63,6 +91,34 @@ func (_m *UserRepository) GetByLogin(ctx context.Context, login string) (user.Us
 	return r0, r1
 }
 
+// UpdatePassword provides a mock function with given fields: ctx, id, passwordHash, tx
+func (_m *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, tx bun.IDB) error {
+	ret := _m.Called(ctx, id, passwordHash, tx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, bun.IDB) error); ok {
+		r0 = rf(ctx, id, passwordHash, tx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateRole provides a mock function with given fields: ctx, id, role, tx
+func (_m *UserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role user.Role, tx bun.IDB) error {
+	ret := _m.Called(ctx, id, role, tx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, user.Role, bun.IDB) error); ok {
+		r0 = rf(ctx, id, role, tx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUserRepository creates a new instance of UserRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserRepository(t interface {
@@ -2,35 +2,114 @@ package repository
 
 import (
 	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/audit"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/session"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/gofrs/uuid"
 	"github.com/uptrace/bun"
+	"time"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=UserRepository
 type UserRepository interface {
 	CreateUser(ctx context.Context, user user.User) (user.User, error)
 	GetByLogin(ctx context.Context, login string) (user.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (user.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string, tx bun.IDB) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role user.Role, tx bun.IDB) error
+}
+
+// LoginAttemptStore счётчики неудачных попыток входа. Счётчик удаляется через ttl после последней неудачи.
+// TryAcquire атомарно проверяет блокировку и, если её нет, заранее засчитывает попытку неудачной,
+// возвращая оставшееся время блокировки. Так параллельные попытки не проходят проверку до того, как
+// засчитана неудача предыдущих. Удачная попытка возвращается через ReleaseAttempt или ResetAttempts.
+// DeleteExpired удаляет истёкшие счётчики.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=LoginAttemptStore
+type LoginAttemptStore interface {
+	GetAttempts(ctx context.Context, key string) (user.LoginAttempts, error)
+	TryAcquire(ctx context.Context, key string, lockout user.Lockout, at time.Time, ttl time.Duration) (time.Duration, error)
+	ReleaseAttempt(ctx context.Context, key string) error
+	ResetAttempts(ctx context.Context, key string) error
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=LoginAuditRepository
+type LoginAuditRepository interface {
+	RecordFailure(ctx context.Context, failure user.LoginFailure) error
+	DeleteFailuresBefore(ctx context.Context, before time.Time) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=SessionRepository
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session session.Session, tx bun.IDB) error
+	GetSession(ctx context.Context, id uuid.UUID) (session.Session, error)
+	GetSessionByRefreshHash(ctx context.Context, hash string, tx bun.IDB) (session.Session, error)
+	UpdateSession(ctx context.Context, session session.Session, tx bun.IDB) error
+	RevokeSession(ctx context.Context, id uuid.UUID, revokedAt time.Time) error
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID, revokedAt time.Time, tx bun.IDB) error
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=OrderRepository
 type OrderRepository interface {
 	CreateOrder(ctx context.Context, order order.Order, tx bun.IDB) error
+	CreateOrdersIfNotExist(ctx context.Context, orders []order.Order, tx bun.IDB) ([]string, error)
 	GetByNumber(ctx context.Context, number string, tx bun.IDB) (order.Order, error)
-	GetAllByUser(ctx context.Context, userID uuid.UUID) ([]service.OrderInfo, error)
-	UpdateOrder(ctx context.Context, order order.Order, tx bun.IDB) error
-	BatchUpdateOrdersAndBalance(ctx context.Context, orders []order.Order, transactions []transaction.Transaction) error
-	GetAllByStatuses(ctx context.Context, statuses []string) ([]order.Order, error)
-	GetBatchByNumbers(ctx context.Context, orderNumbers []string) ([]order.Order, error)
+	GetPageByUser(ctx context.Context, userID uuid.UUID, filter order.ListFilter) ([]service.OrderInfo, error)
+	GetUserOrderInfo(ctx context.Context, userID uuid.UUID, number string) (service.OrderInfo, error)
+	MarkInvalid(ctx context.Context, id uuid.UUID, tx bun.IDB) (bool, error)
+	BatchUpdateOrdersAndBalance(ctx context.Context, orders []order.Order, transactions []transaction.Transaction, tx bun.IDB) ([]string, error)
+	AddStatusChanges(ctx context.Context, changes []order.StatusChange, tx bun.IDB) error
+	GetStatusHistory(ctx context.Context, orderID uuid.UUID) ([]order.StatusChange, error)
+	GetStatusEventsByUser(ctx context.Context, userID uuid.UUID, after int64, limit int) ([]order.StatusEvent, error)
+	ClaimForPolling(ctx context.Context, statuses []string, limit int, lease time.Duration) ([]order.Order, error)
+	ScheduleNextCheck(ctx context.Context, orderID uuid.UUID, nextCheckAt time.Time) error
+	RequeueForPolling(ctx context.Context, orderID uuid.UUID, nextCheckAt time.Time) error
+	GetBatchByNumbers(ctx context.Context, orderNumbers []string, tx bun.IDB) ([]order.Order, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=AccrualResultRepository
+type AccrualResultRepository interface {
+	SaveResult(ctx context.Context, result order.AccrualResult) error
+	ClaimPending(ctx context.Context, limit int, tx bun.IDB) ([]order.AccrualResult, error)
+	MarkProcessed(ctx context.Context, orderNumbers []string, tx bun.IDB) error
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=TransactionRepository
 type TransactionRepository interface {
 	CreateTransaction(ctx context.Context, transaction transaction.Transaction, tx bun.IDB) error
-	GetBalanceByUser(ctx context.Context, userID uuid.UUID, tx bun.IDB) (float64, error)
-	GetWithdrawalSumByUser(ctx context.Context, userID uuid.UUID) (float64, error)
+	LockBalance(ctx context.Context, userID uuid.UUID, tx bun.IDB) error
+	GetBalanceByUser(ctx context.Context, userID uuid.UUID, tx bun.IDB) (money.Money, error)
+	GetWithdrawalSumByUser(ctx context.Context, userID uuid.UUID) (money.Money, error)
 	GetWithdrawalsByUser(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error)
+	GetAllByUser(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error)
+	GetHistory(ctx context.Context, userID uuid.UUID, filter transaction.HistoryFilter) ([]transaction.HistoryEntry, error)
+	GetWithdrawalByOrder(ctx context.Context, userID uuid.UUID, orderNumber string, tx bun.IDB) (transaction.Transaction, error)
+	GetWithdrawalRequest(ctx context.Context, userID uuid.UUID, key string, tx bun.IDB) (transaction.WithdrawalRequest, error)
+	CreateWithdrawalRequest(ctx context.Context, request transaction.WithdrawalRequest, tx bun.IDB) error
+}
+
+// AuditLogRepository журнал действий сотрудников. Записи только добавляются.
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=AuditLogRepository
+type AuditLogRepository interface {
+	Record(ctx context.Context, entry audit.Entry, tx bun.IDB) error
+}
+
+// WebhookRepository адреса для webhook и очередь доставок событий на них
+//
+//go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=WebhookRepository
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint webhook.Endpoint) error
+	GetEndpointsByUser(ctx context.Context, userID uuid.UUID) ([]webhook.Endpoint, error)
+	DeleteEndpoint(ctx context.Context, userID uuid.UUID, endpointID uuid.UUID) error
+	Enqueue(ctx context.Context, event webhook.Event, tx bun.IDB) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]webhook.Delivery, error)
+	SaveAttempt(ctx context.Context, delivery webhook.Delivery) error
+	GetDeliveries(ctx context.Context, userID uuid.UUID, endpointID uuid.UUID, limit int) ([]webhook.Delivery, error)
 }
@@ -2,9 +2,11 @@ package service
 
 import (
 	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
 	"github.com/gofrs/uuid"
 	"time"
@@ -12,15 +14,46 @@ import (
 
 type UserService interface {
 	Register(ctx context.Context, login, password string) (user.User, error)
-	Login(ctx context.Context, login, password string) (user.User, error)
+	Login(ctx context.Context, login, password, ip string) (user.User, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) (Tokens, error)
+	GetUser(ctx context.Context, userID uuid.UUID) (user.User, error)
+	FindByLogin(ctx context.Context, login string) (user.User, error)
+	SetRole(ctx context.Context, userID uuid.UUID, role user.Role) error
+	CleanupLoginHistory(ctx context.Context) error
+}
+
+type SessionService interface {
+	Create(ctx context.Context, userID uuid.UUID) (Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (Tokens, error)
+	Logout(ctx context.Context, sessionID uuid.UUID) error
+	LogoutAll(ctx context.Context, userID uuid.UUID) error
+	ChangePassword(ctx context.Context, userID uuid.UUID, passwordHash string) (Tokens, error)
+	ChangeRole(ctx context.Context, userID uuid.UUID, role user.Role) error
+	IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error)
 }
 
 type OrderService interface {
 	LoadOrderByNumber(ctx context.Context, number string, userID uuid.UUID) error
-	GetUserOrders(ctx context.Context, userID uuid.UUID) ([]OrderInfo, error)
-	UpdateOrdersAndBalance(ctx context.Context, info map[string]clients.OrderLoyaltyInfo) []error
+	LoadOrders(ctx context.Context, numbers []string, userID uuid.UUID) ([]order.LoadResult, error)
+	GetUserOrders(ctx context.Context, userID uuid.UUID, filter order.ListFilter) (OrderPage, error)
+	GetUserOrder(ctx context.Context, userID uuid.UUID, number string) (OrderDetails, error)
+	GetUserOrderEvents(ctx context.Context, userID uuid.UUID, after int64) ([]order.StatusEvent, error)
+	SaveAccrualResult(ctx context.Context, info clients.OrderLoyaltyInfo) error
+	UpdateOrdersAndBalance(ctx context.Context, limit int) (int, []error)
 	InvalidateOrder(ctx context.Context, number string) error
-	GetUnprocessedOrders(ctx context.Context) ([]order.Order, error)
+	ClaimUnprocessedOrders(ctx context.Context, limit int) ([]order.Order, error)
+	ScheduleNextCheck(ctx context.Context, o order.Order) error
+	RequeueOrder(ctx context.Context, number string) error
+}
+
+// OrderEventPublisher рассылает изменения заказов подписчикам их владельца
+type OrderEventPublisher interface {
+	Publish(userID uuid.UUID, event order.StatusEvent)
+}
+
+// OrderEventSubscriber подписка на изменения заказов пользователя. Функция отписки закрывает подписку.
+type OrderEventSubscriber interface {
+	Subscribe(userID uuid.UUID) (<-chan order.StatusEvent, func())
 }
 
 type NewOrderProcessor interface {
@@ -28,20 +61,61 @@ type NewOrderProcessor interface {
 }
 
 type BalanceService interface {
-	GetUserBalance(ctx context.Context, userID uuid.UUID) (float64, error)
-	GetUserWithdrawalSum(ctx context.Context, userID uuid.UUID) (float64, error)
+	GetUserBalance(ctx context.Context, userID uuid.UUID) (money.Money, error)
+	GetUserWithdrawalSum(ctx context.Context, userID uuid.UUID) (money.Money, error)
 	GetUserWithdraws(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error)
-	Withdraw(ctx context.Context, sum float64, orderNumber string, userID uuid.UUID) error
+	GetUserTransactions(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error)
+	GetUserHistory(ctx context.Context, userID uuid.UUID, filter transaction.HistoryFilter) (TransactionHistory, error)
+	Withdraw(ctx context.Context, sum money.Money, orderNumber string, userID uuid.UUID, idempotencyKey string) error
+	AdjustBalance(
+		ctx context.Context, userID uuid.UUID, sum money.Money, reason string, actorID uuid.UUID,
+	) (transaction.Transaction, error)
+}
+
+type WebhookService interface {
+	CreateEndpoint(
+		ctx context.Context, userID uuid.UUID, url, secret string, events []string,
+	) (webhook.Endpoint, error)
+	GetEndpoints(ctx context.Context, userID uuid.UUID) ([]webhook.Endpoint, error)
+	DeleteEndpoint(ctx context.Context, userID uuid.UUID, endpointID uuid.UUID) error
+	GetDeliveries(ctx context.Context, userID uuid.UUID, endpointID uuid.UUID) ([]webhook.Delivery, error)
+	DeliverDue(ctx context.Context, limit int) (int, []error)
 }
 
 type OrderInfo struct {
-	Number     string    `json:"number"`
-	Status     string    `json:"status"`
-	Accrual    float64   `json:"accrual,omitempty"`
-	UploadedAt time.Time `json:"uploaded_at"`
+	ID         uuid.UUID   `json:"-"`
+	Number     string      `json:"number"`
+	Status     string      `json:"status"`
+	Accrual    money.Money `json:"accrual,omitempty"`
+	UploadedAt time.Time   `json:"uploaded_at"`
+}
+
+// OrderPage страница заказов. NextCursor пуст, если страница последняя.
+type OrderPage struct {
+	Orders     []OrderInfo
+	NextCursor string
+}
+
+// OrderDetails заказ с историей статусов от первого к последнему
+type OrderDetails struct {
+	OrderInfo
+	History []order.StatusChange `json:"history"`
 }
 
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   money.Money `json:"current"`
+	Withdrawn money.Money `json:"withdrawn"`
+}
+
+// TransactionHistory страница истории транзакций. NextCursor пуст, если страница последняя.
+type TransactionHistory struct {
+	Transactions []transaction.HistoryEntry `json:"transactions"`
+	NextCursor   string                     `json:"next_cursor,omitempty"`
+}
+
+type Tokens struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
 }
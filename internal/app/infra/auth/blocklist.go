@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// LoadPasswordBlocklist читает список утёкших паролей, по одному в строке. Пустые строки
+// и строки, начинающиеся с #, пропускаются, пароли сравниваются без учёта регистра.
+func LoadPasswordBlocklist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	blocklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocklist, nil
+}
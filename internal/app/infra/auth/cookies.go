@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	AccessTokenCookie = "token"
+	CSRFCookie        = "csrf_token"
+	CSRFHeader        = "X-CSRF-Token"
+)
+
+// CookieConfig атрибуты cookie с токенами. При включённом CSRF запросы, изменяющие состояние
+// и авторизованные cookie, должны повторять значение cookie csrf_token в заголовке X-CSRF-Token.
+type CookieConfig struct {
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+	CSRF     bool
+}
+
+func NewCookieConfig(secure bool, sameSite, domain string, csrf bool) (CookieConfig, error) {
+	config := CookieConfig{Secure: secure, Domain: domain, CSRF: csrf}
+	switch strings.ToLower(sameSite) {
+	case "", "lax":
+		config.SameSite = http.SameSiteLaxMode
+	case "strict":
+		config.SameSite = http.SameSiteStrictMode
+	case "none":
+		if !secure {
+			return CookieConfig{}, fmt.Errorf("SameSite=None cookies must be Secure")
+		}
+		config.SameSite = http.SameSiteNoneMode
+	default:
+		return CookieConfig{}, fmt.Errorf("unknown SameSite mode %q", sameSite)
+	}
+
+	return config, nil
+}
+
+// NewCSRFToken случайное значение для cookie csrf_token
+func NewCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CheckCSRF проверяет, что заголовок X-CSRF-Token совпадает с cookie csrf_token.
+// Безопасные методы и выключенная защита проверку не требуют.
+func (c CookieConfig) CheckCSRF(r *http.Request) bool {
+	if !c.CSRF {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFHeader)
+
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
+
+// BearerToken возвращает токен из заголовка Authorization: Bearer
+func BearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
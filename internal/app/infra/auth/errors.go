@@ -0,0 +1,20 @@
+package auth
+
+import "fmt"
+
+type InvalidToken struct {
+	Reason string
+}
+
+func (e InvalidToken) Error() string {
+	return fmt.Sprintf("invalid token: %s", e.Reason)
+}
+
+type InvalidKey struct {
+	KID    string
+	Reason string
+}
+
+func (e InvalidKey) Error() string {
+	return fmt.Sprintf("invalid signing key %q: %s", e.KID, e.Reason)
+}
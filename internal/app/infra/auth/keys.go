@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+)
+
+// minSecretLength минимальная длина секрета для HS256, более короткий секрет подбирается перебором
+const minSecretLength = 32
+
+// Key ключ подписи токенов. Ключ без SignKey используется только для проверки токенов,
+// выпущенных до ротации.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// KeySet набор ключей, которыми проверяются токены. Новые токены подписываются ключом Active.
+type KeySet struct {
+	Active string
+	Keys   map[string]Key
+}
+
+type keysFile struct {
+	Active string          `json:"active"`
+	Keys   []keyFileRecord `json:"keys"`
+}
+
+type keyFileRecord struct {
+	KID            string `json:"kid"`
+	Alg            string `json:"alg"`
+	Secret         string `json:"secret"`
+	PrivateKey     string `json:"private_key"`
+	PrivateKeyFile string `json:"private_key_file"`
+	PublicKey      string `json:"public_key"`
+	PublicKeyFile  string `json:"public_key_file"`
+}
+
+// NewSecretKeySet набор из одного ключа HS256
+func NewSecretKeySet(kid string, secret []byte) (KeySet, error) {
+	key, err := newHMACKey(kid, secret)
+	if err != nil {
+		return KeySet{}, err
+	}
+
+	return KeySet{Active: kid, Keys: map[string]Key{kid: key}}, nil
+}
+
+// NewRandomKeySet набор из случайного ключа HS256. Выпущенные им токены перестают действовать
+// после перезапуска сервиса.
+func NewRandomKeySet() (KeySet, error) {
+	secret := make([]byte, minSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return KeySet{}, err
+	}
+
+	return NewSecretKeySet("random", secret)
+}
+
+// LoadKeySet читает набор ключей из JSON файла вида
+//
+//	{"active": "2024-02", "keys": [
+//		{"kid": "2024-01", "alg": "HS256", "secret": "..."},
+//		{"kid": "2024-02", "alg": "EdDSA", "private_key_file": "ed25519.pem"}
+//	]}
+//
+// Для RS256 и EdDSA ключи задаются в PEM, для выведенных из оборота ключей достаточно публичного.
+func LoadKeySet(path string) (KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return KeySet{}, err
+	}
+	var file keysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return KeySet{}, err
+	}
+
+	set := KeySet{Active: file.Active, Keys: make(map[string]Key, len(file.Keys))}
+	for _, record := range file.Keys {
+		if record.KID == "" {
+			return KeySet{}, InvalidKey{Reason: "kid is empty"}
+		}
+		if _, ok := set.Keys[record.KID]; ok {
+			return KeySet{}, InvalidKey{KID: record.KID, Reason: "duplicate kid"}
+		}
+		key, err := record.key()
+		if err != nil {
+			return KeySet{}, err
+		}
+		set.Keys[record.KID] = key
+	}
+	if err := set.validate(); err != nil {
+		return KeySet{}, err
+	}
+
+	return set, nil
+}
+
+func (s KeySet) validate() error {
+	active, ok := s.Keys[s.Active]
+	if !ok {
+		return InvalidKey{KID: s.Active, Reason: "active key is not in the key set"}
+	}
+	if active.SignKey == nil {
+		return InvalidKey{KID: s.Active, Reason: "active key has no private part"}
+	}
+
+	return nil
+}
+
+func (r keyFileRecord) key() (Key, error) {
+	switch r.Alg {
+	case jwt.SigningMethodHS256.Alg():
+		return newHMACKey(r.KID, []byte(r.Secret))
+	case jwt.SigningMethodRS256.Alg():
+		return r.asymmetricKey(
+			jwt.SigningMethodRS256,
+			func(pem []byte) (interface{}, interface{}, error) {
+				private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+				if err != nil {
+					return nil, nil, err
+				}
+				return private, &private.PublicKey, nil
+			},
+			func(pem []byte) (interface{}, error) {
+				return jwt.ParseRSAPublicKeyFromPEM(pem)
+			},
+		)
+	case jwt.SigningMethodEdDSA.Alg():
+		return r.asymmetricKey(
+			jwt.SigningMethodEdDSA,
+			func(pem []byte) (interface{}, interface{}, error) {
+				private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+				if err != nil {
+					return nil, nil, err
+				}
+				return private, private.(ed25519.PrivateKey).Public(), nil
+			},
+			func(pem []byte) (interface{}, error) {
+				return jwt.ParseEdPublicKeyFromPEM(pem)
+			},
+		)
+	default:
+		return Key{}, InvalidKey{KID: r.KID, Reason: "unsupported alg " + r.Alg}
+	}
+}
+
+func (r keyFileRecord) asymmetricKey(
+	method jwt.SigningMethod,
+	parsePrivate func(pem []byte) (interface{}, interface{}, error),
+	parsePublic func(pem []byte) (interface{}, error),
+) (Key, error) {
+	key := Key{ID: r.KID, Method: method}
+	privatePEM, err := readPEM(r.PrivateKey, r.PrivateKeyFile)
+	if err != nil {
+		return Key{}, err
+	}
+	if privatePEM != nil {
+		key.SignKey, key.VerifyKey, err = parsePrivate(privatePEM)
+		if err != nil {
+			return Key{}, InvalidKey{KID: r.KID, Reason: err.Error()}
+		}
+		return key, nil
+	}
+
+	publicPEM, err := readPEM(r.PublicKey, r.PublicKeyFile)
+	if err != nil {
+		return Key{}, err
+	}
+	if publicPEM == nil {
+		return Key{}, InvalidKey{KID: r.KID, Reason: "neither private nor public key is set"}
+	}
+	key.VerifyKey, err = parsePublic(publicPEM)
+	if err != nil {
+		return Key{}, InvalidKey{KID: r.KID, Reason: err.Error()}
+	}
+
+	return key, nil
+}
+
+func newHMACKey(kid string, secret []byte) (Key, error) {
+	if len(secret) < minSecretLength {
+		return Key{}, InvalidKey{KID: kid, Reason: "secret must be at least 32 bytes long"}
+	}
+
+	return Key{ID: kid, Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}, nil
+}
+
+func readPEM(value, path string) ([]byte, error) {
+	if value != "" {
+		return []byte(value), nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	return os.ReadFile(path)
+}
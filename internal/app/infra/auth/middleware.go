@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/gofrs/uuid"
+	"net/http"
+)
+
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error)
+}
+
+// Middleware пропускает запросы с действующим токеном доступа, сессия которого не отозвана.
+// Токен берётся из заголовка Authorization: Bearer, а если его нет, из cookie, и тогда
+// для изменяющих запросов проверяется CSRF токен.
+func Middleware(tokens *TokenManager, sessions SessionChecker, cookies CookieConfig) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				token, ok := BearerToken(r)
+				if !ok {
+					c, err := r.Cookie(AccessTokenCookie)
+					if err != nil {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					if !cookies.CheckCSRF(r) {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					token = c.Value
+				}
+				claims, err := tokens.ParseJWT(token)
+				if err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				active, err := sessions.IsSessionActive(r.Context(), claims.SessionID)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				if !active {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				ctx := context.WithValue(r.Context(), ContextUserID, claims.UserID)
+				ctx = context.WithValue(ctx, ContextSessionID, claims.SessionID)
+				ctx = context.WithValue(ctx, ContextRole, claims.Role)
+				h.ServeHTTP(w, r.WithContext(ctx))
+			},
+		)
+	}
+}
+
+// RequirePermission пропускает запросы пользователей, роль которых даёт право permission.
+// Используется после Middleware, который кладёт роль из токена в контекст.
+func RequirePermission(permission user.Permission) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				role, ok := GetRole(r)
+				if !ok {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if !role.Can(permission) {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				h.ServeHTTP(w, r)
+			},
+		)
+	}
+}
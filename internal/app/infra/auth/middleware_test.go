@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type sessionsStub map[uuid.UUID]bool
+
+func (s sessionsStub) IsSessionActive(_ context.Context, sessionID uuid.UUID) (bool, error) {
+	return s[sessionID], nil
+}
+
+func TestMiddleware(t *testing.T) {
+	keys, err := NewRandomKeySet()
+	require.NoError(t, err)
+	tm, err := NewTokenManager(keys, "gophermart", time.Hour)
+	require.NoError(t, err)
+	userID, _ := uuid.NewV7()
+	activeSession, _ := uuid.NewV7()
+	revokedSession, _ := uuid.NewV7()
+	sessions := sessionsStub{activeSession: true}
+	activeToken, err := tm.GenerateJWT(userID, activeSession, user.RoleUser)
+	require.NoError(t, err)
+	revokedToken, err := tm.GenerateJWT(userID, revokedSession, user.RoleUser)
+	require.NoError(t, err)
+	cookies, err := NewCookieConfig(false, "lax", "", true)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		bearer     string
+		cookie     string
+		csrfCookie string
+		csrfHeader string
+		wantStatus int
+	}{
+		{
+			name:       "Test_1. Токен в заголовке Authorization",
+			method:     http.MethodPost,
+			bearer:     activeToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test_2. Токен в cookie, чтение без CSRF токена",
+			method:     http.MethodGet,
+			cookie:     activeToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test_3. Токен в cookie, изменение с CSRF токеном",
+			method:     http.MethodPost,
+			cookie:     activeToken,
+			csrfCookie: "csrf",
+			csrfHeader: "csrf",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test_4. Токен в cookie, изменение без CSRF токена",
+			method:     http.MethodPost,
+			cookie:     activeToken,
+			csrfCookie: "csrf",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test_5. Отозванная сессия",
+			method:     http.MethodGet,
+			bearer:     revokedToken,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test_6. Нет токена",
+			method:     http.MethodGet,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var gotUserID uuid.UUID
+				h := Middleware(tm, sessions, cookies)(
+					http.HandlerFunc(
+						func(w http.ResponseWriter, r *http.Request) {
+							gotUserID, _ = GetUserID(r)
+						},
+					),
+				)
+				r := httptest.NewRequest(tt.method, "/api/user/orders", nil)
+				if tt.bearer != "" {
+					r.Header.Set("Authorization", "Bearer "+tt.bearer)
+				}
+				if tt.cookie != "" {
+					r.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: tt.cookie})
+				}
+				if tt.csrfCookie != "" {
+					r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tt.csrfCookie})
+				}
+				if tt.csrfHeader != "" {
+					r.Header.Set(CSRFHeader, tt.csrfHeader)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				require.Equal(t, tt.wantStatus, w.Code)
+				if tt.wantStatus == http.StatusOK {
+					require.Equal(t, userID, gotUserID)
+				}
+			},
+		)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	keys, err := NewRandomKeySet()
+	require.NoError(t, err)
+	tm, err := NewTokenManager(keys, "gophermart", time.Hour)
+	require.NoError(t, err)
+	userID, _ := uuid.NewV7()
+	sessionID, _ := uuid.NewV7()
+	sessions := sessionsStub{sessionID: true}
+	cookies, err := NewCookieConfig(false, "lax", "", false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		role       user.Role
+		permission user.Permission
+		wantStatus int
+	}{
+		{
+			name:       "Test_1. Администратор ставит заказ в очередь",
+			role:       user.RoleAdmin,
+			permission: user.PermissionRequeueOrders,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test_2. Поддержка смотрит пользователей",
+			role:       user.RoleSupport,
+			permission: user.PermissionViewUsers,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test_3. Поддержка не меняет роли",
+			role:       user.RoleSupport,
+			permission: user.PermissionManageRoles,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test_4. Обычный пользователь не смотрит чужие заказы",
+			role:       user.RoleUser,
+			permission: user.PermissionViewOrders,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test_5. Токен без роли выпущен для обычного пользователя",
+			role:       "",
+			permission: user.PermissionViewUsers,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				token, err := tm.GenerateJWT(userID, sessionID, tt.role)
+				require.NoError(t, err)
+				h := Middleware(tm, sessions, cookies)(
+					RequirePermission(tt.permission)(
+						http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+					),
+				)
+				r := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+				r.Header.Set("Authorization", "Bearer "+token)
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				require.Equal(t, tt.wantStatus, w.Code)
+			},
+		)
+	}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// PasswordHasher хеширует пароли одним алгоритмом
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify сообщает, подходит ли пароль к хешу. Хеши чужого формата не подходят.
+	Verify(password, hash string) bool
+	// Recognizes сообщает, создан ли хеш этим алгоритмом
+	Recognizes(hash string) bool
+	// Outdated сообщает, что хеш этого алгоритма создан с параметрами, отличными от текущих
+	Outdated(hash string) bool
+}
+
+// Passwords хеширует новые пароли алгоритмом current и проверяет хеши всех известных алгоритмов,
+// чтобы пароли, сохранённые до смены настроек, продолжали работать до перехеширования
+type Passwords struct {
+	current PasswordHasher
+	known   []PasswordHasher
+}
+
+func NewPasswords(current PasswordHasher, known ...PasswordHasher) *Passwords {
+	return &Passwords{current: current, known: append([]PasswordHasher{current}, known...)}
+}
+
+func (p *Passwords) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+func (p *Passwords) Verify(password, hash string) bool {
+	for _, h := range p.known {
+		if h.Recognizes(hash) {
+			return h.Verify(password, hash)
+		}
+	}
+
+	return false
+}
+
+// NeedsRehash сообщает, что хеш создан другим алгоритмом или с устаревшими параметрами
+func (p *Passwords) NeedsRehash(hash string) bool {
+	return !p.current.Recognizes(hash) || p.current.Outdated(hash)
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+func (h BcryptHasher) Verify(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (h BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher хранит хеш в формате PHC: $argon2id$v=19$m=65536,t=3,p=2$<соль>$<хеш>
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+type argon2idHash struct {
+	params Argon2idHasher
+	salt   []byte
+	key    []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(password, hash string) bool {
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false
+	}
+	p := parsed.params
+	key := argon2.IDKey([]byte(password), parsed.salt, p.Time, p.Memory, p.Threads, uint32(len(parsed.key)))
+
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1
+}
+
+func (h Argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h Argon2idHasher) Outdated(hash string) bool {
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	p := parsed.params
+
+	return p.Time != h.Time || p.Memory != h.Memory || p.Threads != h.Threads ||
+		p.KeyLen != h.KeyLen || p.SaltLen != h.SaltLen
+}
+
+func parseArgon2idHash(hash string) (argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return argon2idHash{}, fmt.Errorf("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2idHash{}, fmt.Errorf("unsupported argon2id version")
+	}
+	var parsed argon2idHash
+	p := &parsed.params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2idHash{}, err
+	}
+	if parsed.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return argon2idHash{}, err
+	}
+	p.SaltLen = uint32(len(parsed.salt))
+	p.KeyLen = uint32(len(parsed.key))
+
+	return parsed, nil
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestPasswords(t *testing.T) {
+	bcryptHasher := BcryptHasher{Cost: bcrypt.MinCost}
+	argon2idHasher := Argon2idHasher{Time: 1, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16}
+	bcryptHash, err := bcryptHasher.Hash("secret")
+	require.NoError(t, err)
+	outdatedBcryptHash, err := BcryptHasher{Cost: bcrypt.MinCost + 1}.Hash("secret")
+	require.NoError(t, err)
+	argon2idHash, err := argon2idHasher.Hash("secret")
+	require.NoError(t, err)
+	outdatedArgon2idHash, err := Argon2idHasher{Time: 2, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16}.Hash("secret")
+	require.NoError(t, err)
+	passwords := NewPasswords(argon2idHasher, bcryptHasher)
+
+	tests := []struct {
+		name          string
+		password      string
+		hash          string
+		wantValid     bool
+		wantNeedsHash bool
+	}{
+		{name: "Test_1. Текущий алгоритм", password: "secret", hash: argon2idHash, wantValid: true},
+		{name: "Test_2. Неверный пароль", password: "wrong", hash: argon2idHash},
+		{
+			name: "Test_3. Устаревшие параметры argon2id", password: "secret", hash: outdatedArgon2idHash,
+			wantValid: true, wantNeedsHash: true,
+		},
+		{
+			name: "Test_4. Прежний алгоритм bcrypt", password: "secret", hash: bcryptHash,
+			wantValid: true, wantNeedsHash: true,
+		},
+		{name: "Test_5. Неверный пароль bcrypt", password: "wrong", hash: bcryptHash, wantNeedsHash: true},
+		{name: "Test_6. Неизвестный формат", password: "secret", hash: "secret", wantNeedsHash: true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				require.Equal(t, tt.wantValid, passwords.Verify(tt.password, tt.hash))
+				require.Equal(t, tt.wantNeedsHash, passwords.NeedsRehash(tt.hash))
+			},
+		)
+	}
+
+	require.True(t, NewPasswords(bcryptHasher).NeedsRehash(outdatedBcryptHash))
+	require.False(t, NewPasswords(bcryptHasher).NeedsRehash(bcryptHash))
+}
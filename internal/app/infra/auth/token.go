@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+type TokenManager struct {
+	keys   KeySet
+	issuer string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewTokenManager(keys KeySet, issuer string, ttl time.Duration) (*TokenManager, error) {
+	if err := keys.validate(); err != nil {
+		return nil, err
+	}
+
+	return &TokenManager{keys: keys, issuer: issuer, ttl: ttl, now: time.Now}, nil
+}
+
+// GenerateJWT выпускает токен доступа для сессии пользователя с его ролью, подписанный активным ключом
+func (tm *TokenManager) GenerateJWT(id, sessionID uuid.UUID, role user.Role) (string, error) {
+	key := tm.keys.Keys[tm.keys.Active]
+	jti, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	now := tm.now()
+	token := jwt.NewWithClaims(
+		key.Method, Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    tm.issuer,
+				ExpiresAt: jwt.NewNumericDate(now.Add(tm.ttl)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ID:        jti.String(),
+			},
+			UserID:    id,
+			SessionID: sessionID,
+			Role:      role,
+		},
+	)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.SignKey)
+}
+
+// ParseJWT проверяет подпись ключом из заголовка kid и обязательные claims
+func (tm *TokenManager) ParseJWT(tokenString string) (Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(
+		tokenString, claims, tm.verifyKey,
+		jwt.WithIssuer(tm.issuer),
+		jwt.WithIssuedAt(),
+		jwt.WithTimeFunc(tm.now),
+	)
+	if err != nil {
+		var invalid InvalidToken
+		if errors.As(err, &invalid) {
+			return Claims{}, invalid
+		}
+		return Claims{}, InvalidToken{Reason: err.Error()}
+	}
+	if claims.ExpiresAt == nil {
+		return Claims{}, InvalidToken{Reason: "exp claim is required"}
+	}
+	if claims.ID == "" {
+		return Claims{}, InvalidToken{Reason: "jti claim is required"}
+	}
+	if claims.UserID == uuid.Nil {
+		return Claims{}, InvalidToken{Reason: "user id is empty"}
+	}
+	if claims.SessionID == uuid.Nil {
+		return Claims{}, InvalidToken{Reason: "sid claim is required"}
+	}
+	// Токены, выпущенные до появления ролей, принадлежат обычным пользователям
+	if claims.Role == "" {
+		claims.Role = user.RoleUser
+	}
+	if !claims.Role.IsValid() {
+		return Claims{}, InvalidToken{Reason: "unknown role " + string(claims.Role)}
+	}
+
+	return *claims, nil
+}
+
+// TTL срок жизни токенов доступа
+func (tm *TokenManager) TTL() time.Duration {
+	return tm.ttl
+}
+
+func (tm *TokenManager) verifyKey(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := tm.keys.Keys[kid]
+	if !ok {
+		return nil, InvalidToken{Reason: "unknown kid"}
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, InvalidToken{Reason: "unexpected signing method " + t.Method.Alg()}
+	}
+
+	return key.VerifyKey, nil
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func newTestManager(t *testing.T, keys KeySet) *TokenManager {
+	t.Helper()
+	tm, err := NewTokenManager(keys, "gophermart", time.Hour)
+	require.NoError(t, err)
+	return tm
+}
+
+func writeKeysFile(t *testing.T, file keysFile) string {
+	t.Helper()
+	data, err := json.Marshal(file)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestTokenManager_ParseJWT(t *testing.T) {
+	keys, err := NewSecretKeySet("k1", []byte(testSecret))
+	require.NoError(t, err)
+	userID, _ := uuid.NewV7()
+	sessionID, _ := uuid.NewV7()
+	tm := newTestManager(t, keys)
+	valid, err := tm.GenerateJWT(userID, sessionID, user.RoleUser)
+	require.NoError(t, err)
+
+	expired := newTestManager(t, keys)
+	expired.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	expiredToken, err := expired.GenerateJWT(userID, sessionID, user.RoleUser)
+	require.NoError(t, err)
+
+	otherIssuer, err := NewTokenManager(keys, "other", time.Hour)
+	require.NoError(t, err)
+	otherIssuerToken, err := otherIssuer.GenerateJWT(userID, sessionID, user.RoleUser)
+	require.NoError(t, err)
+
+	forgedKeys, err := NewSecretKeySet("k1", []byte(strings.Repeat("x", 32)))
+	require.NoError(t, err)
+	forgedToken, err := newTestManager(t, forgedKeys).GenerateJWT(userID, sessionID, user.RoleUser)
+	require.NoError(t, err)
+
+	noExpToken, err := jwt.NewWithClaims(
+		jwt.SigningMethodHS256, Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Issuer: "gophermart", ID: "1"},
+			UserID:           userID,
+			SessionID:        sessionID,
+		},
+	).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "Test_1. Валидный токен", token: valid},
+		{name: "Test_2. Истёкший токен", token: expiredToken, wantErr: true},
+		{name: "Test_3. Чужой издатель", token: otherIssuerToken, wantErr: true},
+		{name: "Test_4. Подделанная подпись", token: forgedToken, wantErr: true},
+		{name: "Test_5. Токен без exp и kid", token: noExpToken, wantErr: true},
+		{name: "Test_6. Мусор", token: "not a token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := tm.ParseJWT(tt.token)
+				if tt.wantErr {
+					require.Error(t, err)
+					require.Equal(t, uuid.Nil, got.UserID)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, userID, got.UserID)
+				require.Equal(t, sessionID, got.SessionID)
+			},
+		)
+	}
+}
+
+func TestTokenManager_Rotation(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	privatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	userID, _ := uuid.NewV7()
+	sessionID, _ := uuid.NewV7()
+
+	oldKeys, err := LoadKeySet(
+		writeKeysFile(
+			t, keysFile{
+				Active: "2024-01",
+				Keys:   []keyFileRecord{{KID: "2024-01", Alg: "HS256", Secret: testSecret}},
+			},
+		),
+	)
+	require.NoError(t, err)
+	oldToken, err := newTestManager(t, oldKeys).GenerateJWT(userID, sessionID, user.RoleUser)
+	require.NoError(t, err)
+
+	rotatedKeys, err := LoadKeySet(
+		writeKeysFile(
+			t, keysFile{
+				Active: "2024-02",
+				Keys: []keyFileRecord{
+					{KID: "2024-01", Alg: "HS256", Secret: testSecret},
+					{KID: "2024-02", Alg: "EdDSA", PrivateKey: privatePEM},
+				},
+			},
+		),
+	)
+	require.NoError(t, err)
+	rotated := newTestManager(t, rotatedKeys)
+
+	// Токены, выпущенные до ротации, продолжают действовать
+	got, err := rotated.ParseJWT(oldToken)
+	require.NoError(t, err)
+	require.Equal(t, userID, got.UserID)
+
+	newToken, err := rotated.GenerateJWT(userID, sessionID, user.RoleUser)
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	require.Equal(t, "2024-02", parsed.Header["kid"])
+	require.Equal(t, "EdDSA", parsed.Method.Alg())
+
+	// Старый ключ не принимает токены нового
+	_, err = newTestManager(t, oldKeys).ParseJWT(newToken)
+	require.Error(t, err)
+}
+
+func TestLoadKeySet(t *testing.T) {
+	tests := []struct {
+		name string
+		file keysFile
+	}{
+		{
+			name: "Test_1. Активный ключ отсутствует",
+			file: keysFile{Active: "2", Keys: []keyFileRecord{{KID: "1", Alg: "HS256", Secret: testSecret}}},
+		},
+		{
+			name: "Test_2. Короткий секрет",
+			file: keysFile{Active: "1", Keys: []keyFileRecord{{KID: "1", Alg: "HS256", Secret: "short"}}},
+		},
+		{
+			name: "Test_3. Неподдерживаемый алгоритм",
+			file: keysFile{Active: "1", Keys: []keyFileRecord{{KID: "1", Alg: "none"}}},
+		},
+		{
+			name: "Test_4. Повтор kid",
+			file: keysFile{
+				Active: "1", Keys: []keyFileRecord{
+					{KID: "1", Alg: "HS256", Secret: testSecret},
+					{KID: "1", Alg: "HS256", Secret: testSecret},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				_, err := LoadKeySet(writeKeysFile(t, tt.file))
+				require.Error(t, err)
+			},
+		)
+	}
+}
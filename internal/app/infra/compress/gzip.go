@@ -31,6 +31,16 @@ func (c *gzipWriter) WriteHeader(statusCode int) {
 	c.w.WriteHeader(statusCode)
 }
 
+// Flush отправляет клиенту уже сжатые данные, без этого не работают потоковые ответы
+func (c *gzipWriter) Flush() {
+	if err := c.zw.Flush(); err != nil {
+		return
+	}
+	if f, ok := c.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (c *gzipWriter) Close() error {
 	return c.zw.Close()
 }
@@ -3,6 +3,8 @@ package config
 import (
 	"flag"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -10,6 +12,36 @@ type Config struct {
 	RunAddress           string
 	AccrualSystemAddress string
 	LogLevel             string
+	AutoMigrate          bool
+	ShutdownTimeout      time.Duration
+	AccrualTimeout       time.Duration
+	AccrualRetryCount    int
+	AccrualRetryWait     time.Duration
+	AccrualRetryMaxWait  time.Duration
+	WebhookTimeout       time.Duration
+	JWTSecret            string
+	JWTKeysFile          string
+	JWTDevRandomKey      bool
+	JWTIssuer            string
+	JWTTTL               time.Duration
+	RefreshTTL           time.Duration
+	CookieSecure         bool
+	CookieSameSite       string
+	CookieDomain         string
+	CSRFProtection       bool
+	LoginMinLength       int
+	LoginMaxLength       int
+	LoginPattern         string
+	PasswordMinLength    int
+	PasswordMaxLength    int
+	PasswordCharClasses  int
+	PasswordBlocklist    string
+	PasswordHash         string
+	BcryptCost           int
+	Argon2Time           int
+	Argon2Memory         int
+	Argon2Threads        int
+	Args                 []string
 } //
 
 func MakeConfig() Config {
@@ -21,7 +53,46 @@ func MakeConfig() Config {
 		&config.DatabaseURI, "d", "", "database connection",
 	)
 	flag.StringVar(&config.LogLevel, "l", "info", "log level")
+	flag.BoolVar(&config.AutoMigrate, "auto-migrate", true, "apply pending database migrations on startup")
+	flag.DurationVar(&config.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "graceful shutdown timeout")
+	flag.DurationVar(&config.AccrualTimeout, "accrual-timeout", 5*time.Second, "accrual system request timeout")
+	flag.IntVar(&config.AccrualRetryCount, "accrual-retry-count", 3, "accrual system request retries on 5xx and network errors")
+	flag.DurationVar(&config.AccrualRetryWait, "accrual-retry-wait", 100*time.Millisecond, "accrual system initial retry backoff")
+	flag.DurationVar(&config.AccrualRetryMaxWait, "accrual-retry-max-wait", 2*time.Second, "accrual system max retry backoff")
+	flag.DurationVar(&config.WebhookTimeout, "webhook-timeout", 5*time.Second, "webhook delivery request timeout")
+	flag.StringVar(&config.JWTSecret, "jwt-secret", "", "HS256 token signing secret, at least 32 bytes")
+	flag.StringVar(&config.JWTKeysFile, "jwt-keys-file", "", "JSON file with token signing keys, overrides jwt-secret")
+	flag.BoolVar(
+		&config.JWTDevRandomKey, "jwt-dev-random-key", false,
+		"sign tokens with a random key when no key is configured, for development only",
+	)
+	flag.StringVar(&config.JWTIssuer, "jwt-issuer", "gophermart", "token issuer")
+	flag.DurationVar(&config.JWTTTL, "jwt-ttl", 15*time.Minute, "access token lifetime")
+	flag.DurationVar(&config.RefreshTTL, "refresh-ttl", 30*24*time.Hour, "refresh token lifetime")
+	flag.BoolVar(&config.CookieSecure, "cookie-secure", false, "send auth cookies over HTTPS only")
+	flag.StringVar(&config.CookieSameSite, "cookie-samesite", "lax", "SameSite mode of auth cookies: lax, strict or none")
+	flag.StringVar(&config.CookieDomain, "cookie-domain", "", "domain of auth cookies")
+	flag.BoolVar(
+		&config.CSRFProtection, "csrf", true,
+		"require X-CSRF-Token for cookie-authenticated state-changing requests",
+	)
+	flag.IntVar(&config.LoginMinLength, "login-min-length", 3, "minimal login length")
+	flag.IntVar(&config.LoginMaxLength, "login-max-length", 64, "maximal login length")
+	flag.StringVar(&config.LoginPattern, "login-pattern", `^[a-zA-Z0-9._@-]+$`, "regular expression for logins")
+	flag.IntVar(&config.PasswordMinLength, "password-min-length", 8, "minimal password length")
+	flag.IntVar(&config.PasswordMaxLength, "password-max-length", 72, "maximal password length in bytes")
+	flag.IntVar(
+		&config.PasswordCharClasses, "password-char-classes", 0,
+		"number of character classes (lowercase, uppercase, digits, symbols) a password must contain",
+	)
+	flag.StringVar(&config.PasswordBlocklist, "password-blocklist", "", "file with breached passwords, one per line")
+	flag.StringVar(&config.PasswordHash, "password-hash", "bcrypt", "password hashing algorithm: bcrypt or argon2id")
+	flag.IntVar(&config.BcryptCost, "bcrypt-cost", 12, "bcrypt cost")
+	flag.IntVar(&config.Argon2Time, "argon2-time", 3, "argon2id iterations")
+	flag.IntVar(&config.Argon2Memory, "argon2-memory", 64*1024, "argon2id memory in KiB")
+	flag.IntVar(&config.Argon2Threads, "argon2-threads", 2, "argon2id parallelism")
 	flag.Parse()
+	config.Args = flag.Args()
 
 	if envRunAddress := os.Getenv("RUN_ADDRESS"); envRunAddress != "" {
 		config.RunAddress = envRunAddress
@@ -35,5 +106,121 @@ func MakeConfig() Config {
 		config.AccrualSystemAddress = envAccrualSystemAddress
 	}
 
+	if envAutoMigrate, err := strconv.ParseBool(os.Getenv("AUTO_MIGRATE")); err == nil {
+		config.AutoMigrate = envAutoMigrate
+	}
+
+	if envShutdownTimeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil {
+		config.ShutdownTimeout = envShutdownTimeout
+	}
+
+	if envAccrualTimeout, err := time.ParseDuration(os.Getenv("ACCRUAL_TIMEOUT")); err == nil {
+		config.AccrualTimeout = envAccrualTimeout
+	}
+
+	if envAccrualRetryCount, err := strconv.Atoi(os.Getenv("ACCRUAL_RETRY_COUNT")); err == nil {
+		config.AccrualRetryCount = envAccrualRetryCount
+	}
+
+	if envAccrualRetryWait, err := time.ParseDuration(os.Getenv("ACCRUAL_RETRY_WAIT")); err == nil {
+		config.AccrualRetryWait = envAccrualRetryWait
+	}
+
+	if envAccrualRetryMaxWait, err := time.ParseDuration(os.Getenv("ACCRUAL_RETRY_MAX_WAIT")); err == nil {
+		config.AccrualRetryMaxWait = envAccrualRetryMaxWait
+	}
+
+	if envWebhookTimeout, err := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT")); err == nil {
+		config.WebhookTimeout = envWebhookTimeout
+	}
+
+	if envJWTSecret := os.Getenv("JWT_SECRET"); envJWTSecret != "" {
+		config.JWTSecret = envJWTSecret
+	}
+
+	if envJWTKeysFile := os.Getenv("JWT_KEYS_FILE"); envJWTKeysFile != "" {
+		config.JWTKeysFile = envJWTKeysFile
+	}
+
+	if envJWTDevRandomKey, err := strconv.ParseBool(os.Getenv("JWT_DEV_RANDOM_KEY")); err == nil {
+		config.JWTDevRandomKey = envJWTDevRandomKey
+	}
+
+	if envJWTIssuer := os.Getenv("JWT_ISSUER"); envJWTIssuer != "" {
+		config.JWTIssuer = envJWTIssuer
+	}
+
+	if envJWTTTL, err := time.ParseDuration(os.Getenv("JWT_TTL")); err == nil {
+		config.JWTTTL = envJWTTTL
+	}
+
+	if envRefreshTTL, err := time.ParseDuration(os.Getenv("REFRESH_TTL")); err == nil {
+		config.RefreshTTL = envRefreshTTL
+	}
+
+	if envCookieSecure, err := strconv.ParseBool(os.Getenv("COOKIE_SECURE")); err == nil {
+		config.CookieSecure = envCookieSecure
+	}
+
+	if envCookieSameSite := os.Getenv("COOKIE_SAMESITE"); envCookieSameSite != "" {
+		config.CookieSameSite = envCookieSameSite
+	}
+
+	if envCookieDomain := os.Getenv("COOKIE_DOMAIN"); envCookieDomain != "" {
+		config.CookieDomain = envCookieDomain
+	}
+
+	if envCSRFProtection, err := strconv.ParseBool(os.Getenv("CSRF_PROTECTION")); err == nil {
+		config.CSRFProtection = envCSRFProtection
+	}
+
+	if envLoginMinLength, err := strconv.Atoi(os.Getenv("LOGIN_MIN_LENGTH")); err == nil {
+		config.LoginMinLength = envLoginMinLength
+	}
+
+	if envLoginMaxLength, err := strconv.Atoi(os.Getenv("LOGIN_MAX_LENGTH")); err == nil {
+		config.LoginMaxLength = envLoginMaxLength
+	}
+
+	if envLoginPattern := os.Getenv("LOGIN_PATTERN"); envLoginPattern != "" {
+		config.LoginPattern = envLoginPattern
+	}
+
+	if envPasswordMinLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+		config.PasswordMinLength = envPasswordMinLength
+	}
+
+	if envPasswordMaxLength, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH")); err == nil {
+		config.PasswordMaxLength = envPasswordMaxLength
+	}
+
+	if envPasswordCharClasses, err := strconv.Atoi(os.Getenv("PASSWORD_CHAR_CLASSES")); err == nil {
+		config.PasswordCharClasses = envPasswordCharClasses
+	}
+
+	if envPasswordBlocklist := os.Getenv("PASSWORD_BLOCKLIST"); envPasswordBlocklist != "" {
+		config.PasswordBlocklist = envPasswordBlocklist
+	}
+
+	if envPasswordHash := os.Getenv("PASSWORD_HASH"); envPasswordHash != "" {
+		config.PasswordHash = envPasswordHash
+	}
+
+	if envBcryptCost, err := strconv.Atoi(os.Getenv("BCRYPT_COST")); err == nil {
+		config.BcryptCost = envBcryptCost
+	}
+
+	if envArgon2Time, err := strconv.Atoi(os.Getenv("ARGON2_TIME")); err == nil {
+		config.Argon2Time = envArgon2Time
+	}
+
+	if envArgon2Memory, err := strconv.Atoi(os.Getenv("ARGON2_MEMORY")); err == nil {
+		config.Argon2Memory = envArgon2Memory
+	}
+
+	if envArgon2Threads, err := strconv.Atoi(os.Getenv("ARGON2_THREADS")); err == nil {
+		config.Argon2Threads = envArgon2Threads
+	}
+
 	return config
 }
@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
+	"github.com/gofrs/uuid"
+	"sync"
+)
+
+// subscriberBuffer столько событий может накопиться у подписчика, прежде чем он будет отключён
+const subscriberBuffer = 64
+
+// Hub рассылает изменения заказов подписчикам внутри одного процесса. Каждый подписчик получает
+// только события своего пользователя. Подписчик, который не успевает читать события, отключается
+// закрытием канала: клиент переподключается и получает пропущенное по Last-Event-ID.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan order.StatusEvent]struct{}
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[uuid.UUID]map[chan order.StatusEvent]struct{})}
+}
+
+// Subscribe подписывает на события пользователя. Возвращённую функцию нужно вызвать, чтобы отписаться.
+func (h *Hub) Subscribe(userID uuid.UUID) (<-chan order.StatusEvent, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan order.StatusEvent, subscriberBuffer)
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan order.StatusEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(userID, ch)
+	}
+}
+
+// Publish не блокируется: медленные подписчики отключаются
+func (h *Hub) Publish(userID uuid.UUID, event order.StatusEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			h.remove(userID, ch)
+		}
+	}
+}
+
+// Close отключает всех подписчиков, чтобы открытые потоки не задерживали остановку сервера
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for userID, subscribers := range h.subscribers {
+		for ch := range subscribers {
+			h.remove(userID, ch)
+		}
+	}
+	h.closed = true
+}
+
+func (h *Hub) remove(userID uuid.UUID, ch chan order.StatusEvent) {
+	subscribers := h.subscribers[userID]
+	if _, ok := subscribers[ch]; !ok {
+		return
+	}
+	delete(subscribers, ch)
+	close(ch)
+	if len(subscribers) == 0 {
+		delete(h.subscribers, userID)
+	}
+}
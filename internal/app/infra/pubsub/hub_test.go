@@ -0,0 +1,61 @@
+package pubsub
+
+import (
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	userID, _ := uuid.NewV7()
+	otherUserID, _ := uuid.NewV7()
+	eventID, _ := uuid.NewV7()
+	event := order.StatusEvent{ID: eventID, Number: "12345678903", Status: order.StatusProcessed}
+
+	t.Run(
+		"Test_1. События получают только подписчики владельца", func(t *testing.T) {
+			hub := NewHub()
+			first, unsubscribeFirst := hub.Subscribe(userID)
+			defer unsubscribeFirst()
+			second, unsubscribeSecond := hub.Subscribe(userID)
+			defer unsubscribeSecond()
+			other, unsubscribeOther := hub.Subscribe(otherUserID)
+			defer unsubscribeOther()
+
+			hub.Publish(userID, event)
+			require.Equal(t, event, <-first)
+			require.Equal(t, event, <-second)
+			require.Len(t, other, 0)
+		},
+	)
+	t.Run(
+		"Test_2. Медленный подписчик отключается", func(t *testing.T) {
+			hub := NewHub()
+			events, unsubscribe := hub.Subscribe(userID)
+			for i := 0; i <= subscriberBuffer; i++ {
+				hub.Publish(userID, event)
+			}
+			received := 0
+			for range events {
+				received++
+			}
+			require.Equal(t, subscriberBuffer, received)
+			unsubscribe()
+		},
+	)
+	t.Run(
+		"Test_3. Остановка закрывает подписки", func(t *testing.T) {
+			hub := NewHub()
+			events, unsubscribe := hub.Subscribe(userID)
+			hub.Close()
+			_, ok := <-events
+			require.False(t, ok)
+			unsubscribe()
+
+			late, _ := hub.Subscribe(userID)
+			_, ok = <-late
+			require.False(t, ok)
+		},
+	)
+}
@@ -4,9 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
-	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
-	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
@@ -33,22 +30,6 @@ func NewPostgresConnection(ctx context.Context, dsn string) (*Client, error) {
 	return &Client{bunDB}, nil
 }
 
-func (c Client) CreateTables(ctx context.Context) error {
-	u := new(user.User)
-	o := new(order.Order)
-	t := new(transaction.Transaction)
-	if _, err := c.NewCreateTable().Model(u).IfNotExists().Exec(ctx); err != nil {
-		return err
-	}
-	if _, err := c.NewCreateTable().Model(o).IfNotExists().Exec(ctx); err != nil {
-		return err
-	}
-	if _, err := c.NewCreateTable().Model(t).IfNotExists().Exec(ctx); err != nil {
-		return err
-	}
-	return nil
-}
-
 //go:generate go run github.com/vektra/mockery/v2@v2.33.1 --name=TransactionHelper
 type TransactionHelper struct {
 	db *Client
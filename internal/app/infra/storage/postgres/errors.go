@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+type SchemaOutdated struct {
+	Pending []string
+}
+
+func (e SchemaOutdated) Error() string {
+	return fmt.Sprintf(
+		"database schema is behind the code, pending migrations: %s", strings.Join(e.Pending, ", "),
+	)
+}
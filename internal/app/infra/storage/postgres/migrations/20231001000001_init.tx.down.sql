@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "transactions";
+--bun:split
+
+DROP TABLE IF EXISTS "orders";
+--bun:split
+
+DROP TABLE IF EXISTS "users";
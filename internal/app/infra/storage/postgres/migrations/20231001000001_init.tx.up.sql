@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS "users" (
+    "id"       uuid    NOT NULL,
+    "login"    VARCHAR NOT NULL,
+    "password" VARCHAR NOT NULL,
+    PRIMARY KEY ("id"),
+    UNIQUE ("login")
+);
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "orders" (
+    "id"          uuid        NOT NULL,
+    "user_id"     uuid,
+    "number"      VARCHAR     NOT NULL,
+    "status"      VARCHAR     NOT NULL,
+    "uploaded_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("id"),
+    UNIQUE ("number")
+);
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "transactions" (
+    "id"           uuid             NOT NULL,
+    "user_id"      uuid,
+    "order"        VARCHAR          NOT NULL,
+    "sum"          DOUBLE PRECISION NOT NULL,
+    "processed_at" TIMESTAMPTZ      NOT NULL,
+    "type"         VARCHAR,
+    PRIMARY KEY ("id")
+);
@@ -0,0 +1,2 @@
+ALTER TABLE "transactions"
+    ALTER COLUMN "sum" TYPE DOUBLE PRECISION USING "sum" / 100.0;
@@ -0,0 +1,3 @@
+-- Суммы хранятся в сотых долях балла, см. money.Money
+ALTER TABLE "transactions"
+    ALTER COLUMN "sum" TYPE BIGINT USING round("sum" * 100)::BIGINT;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "withdrawal_requests";
+--bun:split
+
+DROP INDEX IF EXISTS "transactions_withdraw_user_order_key";
@@ -0,0 +1,39 @@
+-- Повторные списания по одному заказу, созданные до появления ограничения, не удаляются: строки журнала
+-- транзакций нельзя терять. Миграция останавливается и перечисляет повторы, чтобы оператор разобрал их вручную.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('user %s order %s: %s withdrawals', "user_id", "order", n), '; ')
+    INTO duplicates
+    FROM (
+        SELECT "user_id", "order", count(*) AS n
+        FROM "transactions"
+        WHERE "type" = 'WITHDRAW'
+        GROUP BY "user_id", "order"
+        HAVING count(*) > 1
+        ORDER BY "user_id", "order"
+        LIMIT 100
+    ) AS d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'duplicate withdrawals must be resolved before creating transactions_withdraw_user_order_key: %',
+            duplicates;
+    END IF;
+END;
+$$;
+--bun:split
+
+CREATE UNIQUE INDEX IF NOT EXISTS "transactions_withdraw_user_order_key"
+    ON "transactions" ("user_id", "order")
+    WHERE "type" = 'WITHDRAW';
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "withdrawal_requests" (
+    "user_id"         uuid        NOT NULL,
+    "idempotency_key" VARCHAR     NOT NULL,
+    "order"           VARCHAR     NOT NULL,
+    "sum"             BIGINT      NOT NULL,
+    "result"          VARCHAR     NOT NULL,
+    "created_at"      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("user_id", "idempotency_key")
+);
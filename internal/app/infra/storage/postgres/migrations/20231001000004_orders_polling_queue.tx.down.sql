@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "orders_polling_idx";
+--bun:split
+
+ALTER TABLE "orders"
+    DROP COLUMN IF EXISTS "locked_until",
+    DROP COLUMN IF EXISTS "attempts",
+    DROP COLUMN IF EXISTS "next_check_at";
@@ -0,0 +1,9 @@
+ALTER TABLE "orders"
+    ADD COLUMN IF NOT EXISTS "next_check_at" TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    ADD COLUMN IF NOT EXISTS "attempts"      INTEGER     NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "locked_until"  TIMESTAMPTZ;
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "orders_polling_idx"
+    ON "orders" ("next_check_at")
+    WHERE "status" IN ('NEW', 'PROCESSING');
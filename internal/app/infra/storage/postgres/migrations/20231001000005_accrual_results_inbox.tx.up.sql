@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "accrual_results" (
+    "order"        VARCHAR     NOT NULL,
+    "status"       VARCHAR     NOT NULL,
+    "accrual"      BIGINT      NOT NULL DEFAULT 0,
+    "received_at"  TIMESTAMPTZ NOT NULL,
+    "processed_at" TIMESTAMPTZ,
+    PRIMARY KEY ("order")
+);
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "accrual_results_pending_idx"
+    ON "accrual_results" ("received_at")
+    WHERE "processed_at" IS NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "transactions_income_order_key";
@@ -0,0 +1,27 @@
+-- Повторные начисления по одному заказу, созданные до появления ограничения, не удаляются: строки журнала
+-- транзакций нельзя терять. Миграция останавливается и перечисляет повторы, чтобы оператор разобрал их вручную.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('order %s: %s incomes', "order", n), '; ')
+    INTO duplicates
+    FROM (
+        SELECT "order", count(*) AS n
+        FROM "transactions"
+        WHERE "type" = 'INCOME'
+        GROUP BY "order"
+        HAVING count(*) > 1
+        ORDER BY "order"
+        LIMIT 100
+    ) AS d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'duplicate incomes must be resolved before creating transactions_income_order_key: %', duplicates;
+    END IF;
+END;
+$$;
+--bun:split
+
+CREATE UNIQUE INDEX IF NOT EXISTS "transactions_income_order_key"
+    ON "transactions" ("order")
+    WHERE "type" = 'INCOME';
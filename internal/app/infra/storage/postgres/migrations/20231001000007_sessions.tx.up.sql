@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id"                          uuid        NOT NULL,
+    "user_id"                     uuid        NOT NULL REFERENCES "users" ("id"),
+    "refresh_token_hash"          VARCHAR     NOT NULL,
+    "previous_refresh_token_hash" VARCHAR,
+    "created_at"                  TIMESTAMPTZ NOT NULL,
+    "last_used_at"                TIMESTAMPTZ NOT NULL,
+    "expires_at"                  TIMESTAMPTZ NOT NULL,
+    "revoked_at"                  TIMESTAMPTZ,
+    PRIMARY KEY ("id")
+);
+--bun:split
+
+CREATE UNIQUE INDEX IF NOT EXISTS "sessions_refresh_token_hash_key" ON "sessions" ("refresh_token_hash");
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "sessions_previous_refresh_token_hash_idx" ON "sessions" ("previous_refresh_token_hash");
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "sessions_user_id_idx" ON "sessions" ("user_id") WHERE "revoked_at" IS NULL;
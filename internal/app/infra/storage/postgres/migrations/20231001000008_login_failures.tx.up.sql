@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "login_failures" (
+    "id"           uuid        NOT NULL,
+    "login"        VARCHAR     NOT NULL,
+    "ip"           VARCHAR     NOT NULL,
+    "reason"       VARCHAR     NOT NULL,
+    "attempted_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("id")
+);
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "login_failures_login_idx" ON "login_failures" ("login", "attempted_at");
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "login_failures_ip_idx" ON "login_failures" ("ip", "attempted_at");
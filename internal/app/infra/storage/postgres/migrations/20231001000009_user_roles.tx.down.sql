@@ -0,0 +1,4 @@
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_role_check";
+--bun:split
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "role";
@@ -0,0 +1,4 @@
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "role" VARCHAR NOT NULL DEFAULT 'user';
+--bun:split
+
+ALTER TABLE "users" ADD CONSTRAINT "users_role_check" CHECK ("role" IN ('user', 'support', 'admin'));
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS "audit_log";
+--bun:split
+
+DROP FUNCTION IF EXISTS "audit_log_append_only"();
+--bun:split
+
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "reason";
+--bun:split
+
+UPDATE "transactions" SET "order" = '' WHERE "order" IS NULL;
+--bun:split
+
+ALTER TABLE "transactions" ALTER COLUMN "order" SET NOT NULL;
@@ -0,0 +1,38 @@
+-- Корректировки баланса не привязаны к заказу и хранят причину
+ALTER TABLE "transactions" ALTER COLUMN "order" DROP NOT NULL;
+--bun:split
+
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "reason" VARCHAR;
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "id"             uuid        NOT NULL,
+    "actor_id"       uuid        NOT NULL,
+    "action"         VARCHAR     NOT NULL,
+    "target_user_id" uuid,
+    "entity_id"      uuid,
+    "details"        JSONB       NOT NULL DEFAULT '{}',
+    "created_at"     TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("id")
+);
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "audit_log_target_user_idx" ON "audit_log" ("target_user_id", "created_at");
+--bun:split
+
+-- Журнал аудита только пополняется: изменение и удаление записей запрещены
+CREATE OR REPLACE FUNCTION "audit_log_append_only"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+--bun:split
+
+CREATE TRIGGER "audit_log_no_update_delete"
+    BEFORE UPDATE OR DELETE ON "audit_log"
+    FOR EACH ROW EXECUTE FUNCTION "audit_log_append_only"();
+--bun:split
+
+CREATE TRIGGER "audit_log_no_truncate"
+    BEFORE TRUNCATE ON "audit_log"
+    FOR EACH STATEMENT EXECUTE FUNCTION "audit_log_append_only"();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "transactions_user_id_idx";
+-- Прежние UUIDv4 не восстанавливаются: новые идентификаторы так же уникальны
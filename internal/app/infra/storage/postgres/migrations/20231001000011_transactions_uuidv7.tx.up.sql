@@ -0,0 +1,18 @@
+-- История транзакций упорядочивается по UUIDv7 идентификаторам. Начисления раньше получали UUIDv4,
+-- поэтому их идентификаторы пересобираются как UUIDv7 из processed_at, а случайная часть берётся из старого id.
+UPDATE "transactions"
+SET "id" = (
+    substr(ts, 1, 8) || '-' || substr(ts, 9, 4) || '-7' || substr(h, 1, 3) || '-8' || substr(h, 4, 3) || '-' ||
+    substr(h, 7, 12)
+)::uuid
+FROM (
+    SELECT "id" AS old_id,
+           lpad(to_hex((extract(EPOCH FROM "processed_at") * 1000)::bigint), 12, '0') AS ts,
+           md5("id"::text) AS h
+    FROM "transactions"
+    WHERE substr("id"::text, 15, 1) <> '7'
+) AS legacy
+WHERE "transactions"."id" = legacy.old_id;
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "transactions_user_id_idx" ON "transactions" ("user_id", "id");
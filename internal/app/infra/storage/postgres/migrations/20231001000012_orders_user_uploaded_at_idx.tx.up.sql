@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS "orders_user_uploaded_at_idx" ON "orders" ("user_id", "uploaded_at", "id");
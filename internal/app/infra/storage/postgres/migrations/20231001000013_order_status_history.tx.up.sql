@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "order_status_history" (
+    "id"         uuid        NOT NULL,
+    "order_id"   uuid        NOT NULL REFERENCES "orders" ("id") ON DELETE CASCADE,
+    "status"     VARCHAR     NOT NULL,
+    "changed_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("id")
+);
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "order_status_history_order_idx" ON "order_status_history" ("order_id", "changed_at");
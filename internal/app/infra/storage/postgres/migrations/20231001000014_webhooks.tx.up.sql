@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS "webhook_endpoints" (
+    "id"         uuid        NOT NULL,
+    "user_id"    uuid        NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+    "url"        VARCHAR     NOT NULL,
+    "secret"     VARCHAR     NOT NULL,
+    "events"     VARCHAR[]   NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("id")
+);
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_endpoints_user_idx" ON "webhook_endpoints" ("user_id");
+--bun:split
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id"               uuid        NOT NULL,
+    "endpoint_id"      uuid        NOT NULL REFERENCES "webhook_endpoints" ("id") ON DELETE CASCADE,
+    "event_id"         uuid        NOT NULL,
+    "event"            VARCHAR     NOT NULL,
+    "payload"          JSONB       NOT NULL,
+    "status"           VARCHAR     NOT NULL,
+    "attempts"         INTEGER     NOT NULL DEFAULT 0,
+    "next_attempt_at"  TIMESTAMPTZ,
+    "locked_until"     TIMESTAMPTZ,
+    "last_status_code" INTEGER,
+    "last_error"       VARCHAR,
+    "created_at"       TIMESTAMPTZ NOT NULL,
+    "delivered_at"     TIMESTAMPTZ,
+    PRIMARY KEY ("id")
+);
+--bun:split
+
+-- Очередь: недоставленные события, отсортированные по времени следующей попытки
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_pending_idx" ON "webhook_deliveries" ("next_attempt_at")
+    WHERE "status" = 'PENDING';
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_endpoint_idx" ON "webhook_deliveries" ("endpoint_id", "id");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "order_status_history_seq_idx";
+--bun:split
+
+-- Последовательность принадлежит столбцу и удаляется вместе с ним
+ALTER TABLE "order_status_history" DROP COLUMN IF EXISTS "seq";
@@ -0,0 +1,30 @@
+-- Поток событий заказов продолжается с места обрыва по seq. UUIDv7 выдаётся до фиксации транзакции и не
+-- отражает порядок фиксации, поэтому курсором служит последовательность, а записи в историю сериализуются
+-- блокировкой до конца транзакции. Существующие записи нумеруются в порядке изменения.
+CREATE SEQUENCE IF NOT EXISTS "order_status_history_seq_seq";
+--bun:split
+
+ALTER TABLE "order_status_history" ADD COLUMN IF NOT EXISTS "seq" bigint;
+--bun:split
+
+UPDATE "order_status_history"
+SET "seq" = numbered.seq
+FROM (
+    SELECT "id", row_number() OVER (ORDER BY "changed_at", "id") AS seq
+    FROM "order_status_history"
+) AS numbered
+WHERE "order_status_history"."id" = numbered."id";
+--bun:split
+
+SELECT setval('order_status_history_seq_seq', coalesce(max("seq"), 0) + 1, false) FROM "order_status_history";
+--bun:split
+
+ALTER TABLE "order_status_history"
+    ALTER COLUMN "seq" SET DEFAULT nextval('order_status_history_seq_seq'),
+    ALTER COLUMN "seq" SET NOT NULL;
+--bun:split
+
+ALTER SEQUENCE "order_status_history_seq_seq" OWNED BY "order_status_history"."seq";
+--bun:split
+
+CREATE UNIQUE INDEX IF NOT EXISTS "order_status_history_seq_idx" ON "order_status_history" ("seq");
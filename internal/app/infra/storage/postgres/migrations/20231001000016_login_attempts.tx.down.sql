@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "login_failures_attempted_at_idx";
+--bun:split
+
+DROP TABLE IF EXISTS "login_attempts";
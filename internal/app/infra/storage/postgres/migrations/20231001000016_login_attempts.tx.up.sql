@@ -0,0 +1,14 @@
+-- Счётчики попыток входа общие для всех экземпляров сервиса и переживают перезапуск
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    "key"             VARCHAR     NOT NULL,
+    "failures"        INTEGER     NOT NULL,
+    "last_failure_at" TIMESTAMPTZ NOT NULL,
+    "expires_at"      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("key")
+);
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "login_attempts_expires_at_idx" ON "login_attempts" ("expires_at");
+--bun:split
+
+CREATE INDEX IF NOT EXISTS "login_failures_attempted_at_idx" ON "login_failures" ("attempted_at");
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"embed"
+	"github.com/uptrace/bun/migrate"
+)
+
+//go:embed *.sql
+var sqlMigrations embed.FS
+
+// Migrations содержит все SQL-миграции схемы, встроенные в бинарный файл.
+// Имя файла: <версия>_<описание>.tx.(up|down).sql, версии применяются по возрастанию.
+var Migrations = migrate.NewMigrations()
+
+func init() {
+	if err := Migrations.Discover(sqlMigrations); err != nil {
+		panic(err)
+	}
+}
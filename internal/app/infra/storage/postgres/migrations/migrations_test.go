@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	ms := Migrations.Sorted()
+	require.NotEmpty(t, ms)
+	for _, m := range ms {
+		require.NotNil(t, m.Up, "migration %s has no up script", m)
+		require.NotNil(t, m.Down, "migration %s has no down script", m)
+	}
+}
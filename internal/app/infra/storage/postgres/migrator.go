@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres/migrations"
+	"github.com/uptrace/bun/migrate"
+)
+
+const (
+	migrationsTable      = "schema_migrations"
+	migrationsLocksTable = "schema_migrations_locks"
+)
+
+type Migrator struct {
+	migrator *migrate.Migrator
+}
+
+func NewMigrator(db *Client) *Migrator {
+	return &Migrator{
+		migrator: migrate.NewMigrator(
+			db.OrigClient, migrations.Migrations,
+			migrate.WithTableName(migrationsTable),
+			migrate.WithLocksTableName(migrationsLocksTable),
+			migrate.WithMarkAppliedOnSuccess(true),
+		),
+	}
+}
+
+func (m Migrator) Up(ctx context.Context) (*migrate.MigrationGroup, error) {
+	if err := m.migrator.Init(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.migrator.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.migrator.Unlock(ctx) //nolint:errcheck
+
+	return m.migrator.Migrate(ctx)
+}
+
+func (m Migrator) Down(ctx context.Context) (*migrate.MigrationGroup, error) {
+	if err := m.migrator.Init(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.migrator.Lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.migrator.Unlock(ctx) //nolint:errcheck
+
+	return m.migrator.Rollback(ctx)
+}
+
+func (m Migrator) Status(ctx context.Context) (migrate.MigrationSlice, error) {
+	if err := m.migrator.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	return m.migrator.MigrationsWithStatus(ctx)
+}
+
+func (m Migrator) CheckSchema(ctx context.Context) error {
+	ms, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	unapplied := ms.Unapplied()
+	if len(unapplied) == 0 {
+		return nil
+	}
+	pending := make([]string, len(unapplied))
+	for i, migration := range unapplied {
+		pending[i] = migration.String()
+	}
+
+	return &SchemaOutdated{Pending: pending}
+}
@@ -0,0 +1,74 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"github.com/ShiraazMoollatjie/goluhn"
+	pgrepo "github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/repository/postgres"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/service"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBalanceService_ConcurrentWithdraw(t *testing.T) {
+	const (
+		withdrawals             = 300
+		initial     money.Money = 100_00
+		sum         money.Money = 1_00
+	)
+	client := newTestClient(t)
+	client.SetMaxOpenConns(20)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := pgrepo.NewTransactionRepository(client)
+	bs := service.NewBalanceService(
+		repo, pgrepo.NewAuditLogRepository(client), pgrepo.NewWebhookRepository(client), postgres.NewTransactionHelper(client),
+	)
+
+	id, _ := uuid.NewV7()
+	require.NoError(
+		t, repo.CreateTransaction(
+			ctx, transaction.Transaction{
+				ID:          id,
+				UserID:      userID,
+				OrderNumber: goluhn.Generate(12),
+				Sum:         initial,
+				ProcessedAt: time.Now(),
+				Type:        transaction.TypeIncome,
+			}, nil,
+		),
+	)
+
+	var succeeded, rejected atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < withdrawals; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := bs.Withdraw(ctx, sum, goluhn.Generate(12), userID, "")
+			var notEnoughMoney *transaction.NotEnoughMoney
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.As(err, &notEnoughMoney):
+				rejected.Add(1)
+			default:
+				t.Errorf("Withdraw() unexpected error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	balance, err := repo.GetBalanceByUser(ctx, userID, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(initial/sum), succeeded.Load())
+	require.Equal(t, int64(withdrawals)-int64(initial/sum), rejected.Load())
+	require.Equal(t, money.Money(0), balance)
+}
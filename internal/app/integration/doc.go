@@ -0,0 +1,3 @@
+// Package integration содержит тесты сервисов вместе с репозиториями PostgreSQL.
+// Тесты выполняются на реальной базе и пропускаются, если TEST_DATABASE_URI не задан.
+package integration
@@ -0,0 +1,136 @@
+package integration
+
+import (
+	"context"
+	"github.com/ShiraazMoollatjie/goluhn"
+	pgrepo "github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/repository/postgres"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/pubsub"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/postgres"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/service"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestOrderService_DuplicateProcessedResponses(t *testing.T) {
+	const accrual money.Money = 42_10
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := pgrepo.NewOrderRepository(client)
+	transactionRepo := pgrepo.NewTransactionRepository(client)
+	os := service.NewOrderService(
+		repo, pgrepo.NewAccrualResultRepository(client), pgrepo.NewWebhookRepository(client), postgres.NewTransactionHelper(client),
+		pubsub.NewHub(),
+	)
+
+	id, _ := uuid.NewV7()
+	number := goluhn.Generate(16)
+	require.NoError(
+		t, repo.CreateOrder(
+			ctx, order.Order{
+				ID:         id,
+				UserID:     userID,
+				Number:     number,
+				Status:     order.StatusNew,
+				UploadedAt: time.Now(),
+			}, nil,
+		),
+	)
+
+	info := clients.OrderLoyaltyInfo{Order: number, Status: clients.StatusProcessed, Accrual: accrual}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, os.SaveAccrualResult(ctx, info))
+		_, errs := os.UpdateOrdersAndBalance(ctx, 100)
+		require.Empty(t, errs)
+	}
+
+	balance, err := transactionRepo.GetBalanceByUser(ctx, userID, nil)
+	require.NoError(t, err)
+	require.Equal(t, accrual, balance)
+}
+
+func TestOrderService_GetUserOrder(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	otherUserID := createTestUser(t, client)
+	repo := pgrepo.NewOrderRepository(client)
+	os := service.NewOrderService(
+		repo, pgrepo.NewAccrualResultRepository(client), pgrepo.NewWebhookRepository(client), postgres.NewTransactionHelper(client),
+		pubsub.NewHub(),
+	)
+
+	id, _ := uuid.NewV7()
+	number := goluhn.Generate(16)
+	require.NoError(
+		t, repo.CreateOrder(
+			ctx, order.Order{
+				ID:         id,
+				UserID:     userID,
+				Number:     number,
+				Status:     order.StatusNew,
+				UploadedAt: time.Now(),
+			}, nil,
+		),
+	)
+	// Повторный ответ с тем же статусом не добавляет запись в историю
+	responses := []clients.OrderLoyaltyInfo{
+		{Order: number, Status: clients.StatusProcessing},
+		{Order: number, Status: clients.StatusProcessing},
+		{Order: number, Status: clients.StatusProcessed, Accrual: 100},
+	}
+	for _, info := range responses {
+		require.NoError(t, os.SaveAccrualResult(ctx, info))
+		_, errs := os.UpdateOrdersAndBalance(ctx, 100)
+		require.Empty(t, errs)
+	}
+
+	details, err := os.GetUserOrder(ctx, userID, number)
+	require.NoError(t, err)
+	require.Equal(t, order.StatusProcessed, details.Status)
+	require.Equal(t, money.Money(100), details.Accrual)
+	statuses := make([]string, len(details.History))
+	for i, c := range details.History {
+		statuses[i] = c.Status
+	}
+	require.Equal(t, []string{order.StatusNew, order.StatusProcessing, order.StatusProcessed}, statuses)
+
+	_, err = os.GetUserOrder(ctx, otherUserID, number)
+	require.Equal(t, &order.NoSuchOrder{OrderNumber: number}, err)
+}
+
+func TestOrderService_LoadOrders(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	otherUserID := createTestUser(t, client)
+	repo := pgrepo.NewOrderRepository(client)
+	os := service.NewOrderService(
+		repo, pgrepo.NewAccrualResultRepository(client), pgrepo.NewWebhookRepository(client), postgres.NewTransactionHelper(client),
+		pubsub.NewHub(),
+	)
+
+	ownNumber := goluhn.Generate(16)
+	foreignNumber := goluhn.Generate(16)
+	require.NoError(t, os.LoadOrderByNumber(ctx, ownNumber, userID))
+	require.NoError(t, os.LoadOrderByNumber(ctx, foreignNumber, otherUserID))
+
+	newNumber := goluhn.Generate(16)
+	results, err := os.LoadOrders(ctx, []string{ownNumber, newNumber, foreignNumber}, userID)
+	require.NoError(t, err)
+	require.Equal(
+		t, []order.LoadResult{
+			{Number: ownNumber, Result: order.LoadAlreadyUploaded},
+			{Number: newNumber, Result: order.LoadAccepted},
+			{Number: foreignNumber, Result: order.LoadUploadedByOthers},
+		}, results,
+	)
+	o, err := repo.GetByNumber(ctx, newNumber, nil)
+	require.NoError(t, err)
+	require.Equal(t, userID, o.UserID)
+}
@@ -0,0 +1,64 @@
+package integration
+
+import (
+	"context"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/repository/memory"
+	pgrepo "github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/repository/postgres"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/service"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// Параллельные регистрации одного логина создают одного пользователя, остальные получают LoginAlreadyExists
+func TestUserService_ConcurrentRegister(t *testing.T) {
+	const registrations = 10
+	client := newTestClient(t)
+	ctx := context.Background()
+	us := service.NewUserService(
+		pgrepo.NewUserRepository(client),
+		memory.NewLoginAttemptStore(),
+		pgrepo.NewLoginAuditRepository(client),
+		nil,
+		user.Policy{
+			LoginMinLength:    3,
+			LoginMaxLength:    64,
+			LoginPattern:      regexp.MustCompile(`^[a-zA-Z0-9._@-]+$`),
+			PasswordMinLength: 8,
+			PasswordMaxLength: 72,
+		},
+		auth.NewPasswords(auth.BcryptHasher{Cost: bcrypt.MinCost}),
+	)
+	id, _ := uuid.NewV7()
+	login := id.String()
+
+	var mu sync.Mutex
+	var created, exists int
+	var wg sync.WaitGroup
+	for i := 0; i < registrations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := us.Register(ctx, login, "correct-horse-battery")
+			mu.Lock()
+			defer mu.Unlock()
+			switch err.(type) {
+			case nil:
+				created++
+			case *user.LoginAlreadyExists:
+				exists++
+			default:
+				t.Errorf("Register() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, created)
+	require.Equal(t, registrations-1, exists)
+}
@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	pgrepo "github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/repository/postgres"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/service"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// localWebhookClient отправляет webhook на тестовый сервер: настоящий клиент не соединяется с локальными адресами
+type localWebhookClient struct{}
+
+func (localWebhookClient) Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func TestWebhookService_DeliverDue(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	userID := createTestUser(t, client)
+	repo := pgrepo.NewWebhookRepository(client)
+	ws := service.NewWebhookService(repo, localWebhookClient{}, time.Second, 5)
+
+	var received, verified atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	receiver := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				received.Add(1)
+				if fail.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				body, _ := io.ReadAll(r.Body)
+				unix, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+				if webhook.Verify("0123456789abcdef", time.Unix(unix, 0), body, r.Header.Get(webhook.HeaderSignature)) {
+					verified.Add(1)
+				}
+				w.WriteHeader(http.StatusOK)
+			},
+		),
+	)
+	defer receiver.Close()
+
+	endpoint, err := ws.CreateEndpoint(
+		ctx, userID, receiver.URL, "0123456789abcdef", []string{webhook.EventOrderProcessed},
+	)
+	require.NoError(t, err)
+
+	eventID, _ := uuid.NewV7()
+	require.NoError(
+		t, repo.Enqueue(
+			ctx, webhook.Event{
+				ID:        eventID,
+				UserID:    userID,
+				Type:      webhook.EventOrderProcessed,
+				Data:      webhook.OrderData{Number: "9278923470", Status: "PROCESSED", Accrual: 500},
+				CreatedAt: time.Now(),
+			}, nil,
+		),
+	)
+	// На событие без подписки доставка не создаётся
+	require.NoError(
+		t, repo.Enqueue(
+			ctx, webhook.Event{
+				ID: eventID, UserID: userID, Type: webhook.EventBalanceWithdrawn, CreatedAt: time.Now(),
+			}, nil,
+		),
+	)
+
+	_, errs := ws.DeliverDue(ctx, 1000)
+	require.Empty(t, errs)
+	deliveries, err := repo.GetDeliveries(ctx, userID, endpoint.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, webhook.DeliveryPending, deliveries[0].Status)
+	require.Equal(t, 1, deliveries[0].Attempts)
+	require.Equal(t, http.StatusServiceUnavailable, deliveries[0].LastStatusCode)
+	require.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+
+	// Отложенная доставка не выбирается до наступления времени следующей попытки
+	fail.Store(false)
+	_, errs = ws.DeliverDue(ctx, 1000)
+	require.Empty(t, errs)
+	require.EqualValues(t, 1, received.Load())
+
+	_, err = client.NewUpdate().Model((*webhook.Delivery)(nil)).
+		Set("next_attempt_at = now()").
+		Where("id = ?", deliveries[0].ID).
+		Exec(ctx)
+	require.NoError(t, err)
+	_, errs = ws.DeliverDue(ctx, 1000)
+	require.Empty(t, errs)
+	require.EqualValues(t, 1, verified.Load())
+
+	deliveries, err = repo.GetDeliveries(ctx, userID, endpoint.ID, 10)
+	require.NoError(t, err)
+	require.Equal(t, webhook.DeliveryDelivered, deliveries[0].Status)
+	require.Equal(t, 2, deliveries[0].Attempts)
+	require.False(t, deliveries[0].DeliveredAt.IsZero())
+}
@@ -2,30 +2,43 @@ package service
 
 import (
 	"context"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/audit"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage"
 	"github.com/gofrs/uuid"
-	"math"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+const maxAdjustmentReasonLength = 500
+
 type BalanceService struct {
 	repo     repository.TransactionRepository
+	audit    repository.AuditLogRepository
+	webhooks repository.WebhookRepository
 	txHelper storage.TransactionHelper
 }
 
-func NewBalanceService(repo repository.TransactionRepository, txHelper storage.TransactionHelper) *BalanceService {
-	return &BalanceService{repo: repo, txHelper: txHelper}
+func NewBalanceService(
+	repo repository.TransactionRepository, audit repository.AuditLogRepository, webhooks repository.WebhookRepository,
+	txHelper storage.TransactionHelper,
+) *BalanceService {
+	return &BalanceService{repo: repo, audit: audit, webhooks: webhooks, txHelper: txHelper}
 }
 
-func (bs BalanceService) GetUserBalance(ctx context.Context, userID uuid.UUID) (float64, error) {
+func (bs BalanceService) GetUserBalance(ctx context.Context, userID uuid.UUID) (money.Money, error) {
 	return bs.repo.GetBalanceByUser(ctx, userID, nil)
 }
 
-func (bs BalanceService) GetUserWithdrawalSum(ctx context.Context, userID uuid.UUID) (float64, error) {
+func (bs BalanceService) GetUserWithdrawalSum(ctx context.Context, userID uuid.UUID) (money.Money, error) {
 	return bs.repo.GetWithdrawalSumByUser(ctx, userID)
 }
 
@@ -38,13 +51,55 @@ func (bs BalanceService) GetUserWithdraws(ctx context.Context, userID uuid.UUID)
 		return nil, &service.NoData{}
 	}
 	for i := range withdraws {
-		withdraws[i].Sum = math.Abs(withdraws[i].Sum)
+		withdraws[i].Sum = withdraws[i].Sum.Abs()
 	}
 
 	return withdraws, nil
 }
 
-func (bs BalanceService) Withdraw(ctx context.Context, sum float64, orderNumber string, userID uuid.UUID) error {
+func (bs BalanceService) GetUserTransactions(ctx context.Context, userID uuid.UUID) ([]transaction.Transaction, error) {
+	transactions, err := bs.repo.GetAllByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return nil, &service.NoData{}
+	}
+
+	return transactions, nil
+}
+
+// GetUserHistory возвращает страницу истории транзакций пользователя. Запрашивается на одну запись больше,
+// чтобы понять, есть ли следующая страница.
+func (bs BalanceService) GetUserHistory(
+	ctx context.Context, userID uuid.UUID, filter transaction.HistoryFilter,
+) (service.TransactionHistory, error) {
+	if err := filter.Validate(); err != nil {
+		return service.TransactionHistory{}, err
+	}
+	limit := filter.Limit
+	filter.Limit++
+	entries, err := bs.repo.GetHistory(ctx, userID, filter)
+	if err != nil {
+		return service.TransactionHistory{}, err
+	}
+	if len(entries) == 0 {
+		return service.TransactionHistory{}, &service.NoData{}
+	}
+	history := service.TransactionHistory{Transactions: entries}
+	if len(entries) > limit {
+		history.Transactions = entries[:limit]
+		history.NextCursor = entries[limit-1].ID.String()
+	}
+
+	return history, nil
+}
+
+// Withdraw списывает баллы в счёт заказа. Повторное списание по тому же заказу не создаёт новую транзакцию,
+// а при переданном idempotencyKey повторный запрос получает исход исходного запроса.
+func (bs BalanceService) Withdraw(
+	ctx context.Context, sum money.Money, orderNumber string, userID uuid.UUID, idempotencyKey string,
+) error {
 	if !order.ValidateOrderFormat(orderNumber) {
 		return &order.InvalidFormat{OrderNumber: orderNumber}
 	}
@@ -52,30 +107,179 @@ func (bs BalanceService) Withdraw(ctx context.Context, sum float64, orderNumber
 	if err != nil {
 		return err
 	}
-	balance, err := bs.repo.GetBalanceByUser(ctx, userID, tx.GetTransaction())
+	if err := bs.repo.LockBalance(ctx, userID, tx.GetTransaction()); err != nil {
+		return rollback(tx, err)
+	}
+	if idempotencyKey != "" {
+		request, err := bs.repo.GetWithdrawalRequest(ctx, userID, idempotencyKey, tx.GetTransaction())
+		if err == nil {
+			if err := tx.Rollback(); err != nil {
+				return err
+			}
+			return request.Replay(orderNumber, sum)
+		}
+		if !errors.Is(err, repository.NoResultError{}) {
+			return rollback(tx, err)
+		}
+	}
+	result, err := bs.withdraw(ctx, tx, sum, orderNumber, userID)
 	if err != nil {
+		return rollback(tx, err)
+	}
+	if idempotencyKey != "" {
+		if err := bs.repo.CreateWithdrawalRequest(
+			ctx, transaction.WithdrawalRequest{
+				UserID:         userID,
+				IdempotencyKey: idempotencyKey,
+				OrderNumber:    orderNumber,
+				Sum:            sum,
+				Result:         result,
+				CreatedAt:      time.Now(),
+			}, tx.GetTransaction(),
+		); err != nil {
+			return rollback(tx, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
 		return err
 	}
-	if balance < sum {
+	if result == transaction.WithdrawalResultNotEnoughMoney {
 		return &transaction.NotEnoughMoney{}
 	}
+
+	return nil
+}
+
+func (bs BalanceService) withdraw(
+	ctx context.Context, tx storage.Transaction, sum money.Money, orderNumber string, userID uuid.UUID,
+) (string, error) {
+	withdrawal, err := bs.repo.GetWithdrawalByOrder(ctx, userID, orderNumber, tx.GetTransaction())
+	if err == nil {
+		if withdrawal.Sum.Abs() != sum {
+			return "", &transaction.AlreadyWithdrawn{OrderNumber: orderNumber}
+		}
+		return transaction.WithdrawalResultSuccess, nil
+	}
+	if !errors.Is(err, repository.NoResultError{}) {
+		return "", err
+	}
+	balance, err := bs.repo.GetBalanceByUser(ctx, userID, tx.GetTransaction())
+	if err != nil {
+		return "", err
+	}
+	if balance < sum {
+		return transaction.WithdrawalResultNotEnoughMoney, nil
+	}
 	id, err := uuid.NewV7()
 	if err != nil {
-		return err
+		return "", err
+	}
+	now := time.Now()
+	if err := bs.repo.CreateTransaction(
+		ctx, transaction.Transaction{
+			ID:          id,
+			UserID:      userID,
+			OrderNumber: orderNumber,
+			Sum:         -sum,
+			ProcessedAt: now,
+			Type:        transaction.TypeWithdraw,
+		}, tx.GetTransaction(),
+	); err != nil {
+		return "", err
+	}
+	if err := bs.webhooks.Enqueue(
+		ctx, webhook.Event{
+			ID:        id,
+			UserID:    userID,
+			Type:      webhook.EventBalanceWithdrawn,
+			Data:      webhook.WithdrawalData{Order: orderNumber, Sum: sum},
+			CreatedAt: now,
+		}, tx.GetTransaction(),
+	); err != nil {
+		return "", err
+	}
+
+	return transaction.WithdrawalResultSuccess, nil
+}
+
+// AdjustBalance начисляет (sum > 0) или списывает (sum < 0) баллы вручную. Корректировка и запись
+// в журнал аудита с причиной и сотрудником actorID сохраняются в одной транзакции. Списание
+// не может сделать баланс отрицательным.
+func (bs BalanceService) AdjustBalance(
+	ctx context.Context, userID uuid.UUID, sum money.Money, reason string, actorID uuid.UUID,
+) (transaction.Transaction, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return transaction.Transaction{}, &transaction.InvalidAdjustment{Message: "reason is required"}
+	}
+	if utf8.RuneCountInString(reason) > maxAdjustmentReasonLength {
+		return transaction.Transaction{}, &transaction.InvalidAdjustment{Message: "reason is too long"}
+	}
+	if sum == 0 {
+		return transaction.Transaction{}, &transaction.InvalidAdjustment{Message: "sum must not be zero"}
+	}
+	// Корректировки проверяет другой сотрудник, поэтому своему счёту начислять нельзя
+	if actorID == userID {
+		return transaction.Transaction{}, &transaction.SelfAdjustment{}
+	}
+	tx, err := bs.txHelper.StartTransaction(ctx)
+	if err != nil {
+		return transaction.Transaction{}, err
+	}
+	if err := bs.repo.LockBalance(ctx, userID, tx.GetTransaction()); err != nil {
+		if errors.Is(err, repository.NoResultError{}) {
+			return transaction.Transaction{}, rollback(tx, &user.NoSuchUser{ID: userID.String()})
+		}
+		return transaction.Transaction{}, rollback(tx, err)
+	}
+	if sum < 0 {
+		balance, err := bs.repo.GetBalanceByUser(ctx, userID, tx.GetTransaction())
+		if err != nil {
+			return transaction.Transaction{}, rollback(tx, err)
+		}
+		if balance+sum < 0 {
+			return transaction.Transaction{}, rollback(tx, &transaction.NotEnoughMoney{})
+		}
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return transaction.Transaction{}, rollback(tx, err)
 	}
-	if err := bs.repo.CreateTransaction(ctx, transaction.Transaction{
+	now := time.Now()
+	t := transaction.Transaction{
 		ID:          id,
 		UserID:      userID,
-		OrderNumber: orderNumber,
-		Sum:         -sum,
-		ProcessedAt: time.Now(),
-		Type:        transaction.TypeWithdraw,
-	}, tx.GetTransaction()); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return err
-		}
-		return err
+		Sum:         sum,
+		ProcessedAt: now,
+		Type:        transaction.TypeAdjustment,
+		Reason:      reason,
+	}
+	if err := bs.repo.CreateTransaction(ctx, t, tx.GetTransaction()); err != nil {
+		return transaction.Transaction{}, rollback(tx, err)
+	}
+	entryID, err := uuid.NewV7()
+	if err != nil {
+		return transaction.Transaction{}, rollback(tx, err)
+	}
+	if err := bs.audit.Record(
+		ctx, audit.Entry{
+			ID:           entryID,
+			ActorID:      actorID,
+			Action:       audit.ActionBalanceAdjusted,
+			TargetUserID: userID,
+			EntityID:     id,
+			Details: map[string]any{
+				"sum":    sum,
+				"reason": reason,
+			},
+			CreatedAt: now,
+		}, tx.GetTransaction(),
+	); err != nil {
+		return transaction.Transaction{}, rollback(tx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return transaction.Transaction{}, err
 	}
 
-	return tx.Commit()
+	return t, nil
 }
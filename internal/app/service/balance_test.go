@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/audit"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository/mocks"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	storagemocks "github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/mocks"
@@ -26,7 +30,7 @@ func TestBalanceService_GetUserBalance(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		mockRes float64
+		mockRes money.Money
 		mockErr error
 		wantErr bool
 	}{
@@ -66,7 +70,7 @@ func TestBalanceService_GetUserBalance(t *testing.T) {
 				ctx:    ctx,
 				userID: userID,
 			},
-			mockRes: 0.11111,
+			mockRes: money.Money(11111),
 			mockErr: nil,
 			wantErr: false,
 		},
@@ -76,7 +80,7 @@ func TestBalanceService_GetUserBalance(t *testing.T) {
 			tt.name, func(t *testing.T) {
 				rep := mocks.TransactionRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				bs := NewBalanceService(&rep, &txHelper)
+				bs := NewBalanceService(&rep, &mocks.AuditLogRepository{}, &mocks.WebhookRepository{}, &txHelper)
 				rep.On("GetBalanceByUser", tt.args.ctx, tt.args.userID, nil).Return(tt.mockRes, tt.mockErr)
 				balance, err := bs.GetUserBalance(tt.args.ctx, tt.args.userID)
 				if (err != nil) != tt.wantErr {
@@ -99,8 +103,8 @@ func TestBalanceService_GetUserWithdrawalSum(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		want    float64
-		mockRes float64
+		want    money.Money
+		mockRes money.Money
 	}{
 		{
 			name: "Test_1.Списание - целое число",
@@ -132,7 +136,7 @@ func TestBalanceService_GetUserWithdrawalSum(t *testing.T) {
 				ctx:    ctx,
 				userID: userID,
 			},
-			mockRes: 0.1,
+			mockRes: money.FromFloat(0.1),
 		},
 	}
 	for _, tt := range tests {
@@ -140,7 +144,7 @@ func TestBalanceService_GetUserWithdrawalSum(t *testing.T) {
 			tt.name, func(t *testing.T) {
 				rep := mocks.TransactionRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				bs := NewBalanceService(&rep, &txHelper)
+				bs := NewBalanceService(&rep, &mocks.AuditLogRepository{}, &mocks.WebhookRepository{}, &txHelper)
 				rep.On("GetWithdrawalSumByUser", tt.args.ctx, tt.args.userID).Return(tt.mockRes, nil)
 				withdrawal, err := bs.GetUserWithdrawalSum(tt.args.ctx, tt.args.userID)
 				if err != nil {
@@ -222,7 +226,7 @@ func TestBalanceService_GetUserWithdraws(t *testing.T) {
 			tt.name, func(t *testing.T) {
 				rep := mocks.TransactionRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				bs := NewBalanceService(&rep, &txHelper)
+				bs := NewBalanceService(&rep, &mocks.AuditLogRepository{}, &mocks.WebhookRepository{}, &txHelper)
 				rep.On("GetWithdrawalsByUser", tt.args.ctx, tt.args.userID).Return(tt.transaction, nil)
 				withdrawal, err := bs.GetUserWithdraws(tt.args.ctx, tt.args.userID)
 				if (err != nil) != tt.wantErr {
@@ -242,18 +246,25 @@ func TestBalanceService_Withdraw(t *testing.T) {
 	ctx := context.Background()
 	userID, _ := uuid.NewV7()
 	orderNumber := goluhn.Generate(10)
+	idempotencyKey := uuid.Must(uuid.NewV4()).String()
 	type args struct {
-		ctx         context.Context
-		userID      uuid.UUID
-		orderNumber string
-		sum         float64
+		ctx            context.Context
+		userID         uuid.UUID
+		orderNumber    string
+		sum            money.Money
+		idempotencyKey string
 	}
 	tests := []struct {
-		name        string
-		args        args
-		wantErr     bool
-		mockBalance float64
-		mockErr     error
+		name              string
+		args              args
+		wantErr           bool
+		mockBalance       money.Money
+		mockErr           error
+		mockRequest       transaction.WithdrawalRequest
+		mockRequestErr    error
+		mockWithdrawal    transaction.Transaction
+		mockWithdrawalErr error
+		wantCreated       bool
 	}{
 		{
 			name: "Test_1.Есть остаток после списания",
@@ -263,9 +274,11 @@ func TestBalanceService_Withdraw(t *testing.T) {
 				orderNumber: orderNumber,
 				sum:         499,
 			},
-			mockBalance: 500,
-			mockErr:     nil,
-			wantErr:     false,
+			mockBalance:       500,
+			mockErr:           nil,
+			mockWithdrawalErr: repository.NoResultError{},
+			wantErr:           false,
+			wantCreated:       true,
 		},
 		{
 			name: "Test_2.Нулевой остаток после списания",
@@ -275,9 +288,11 @@ func TestBalanceService_Withdraw(t *testing.T) {
 				orderNumber: orderNumber,
 				sum:         500,
 			},
-			mockBalance: 500,
-			mockErr:     nil,
-			wantErr:     false,
+			mockBalance:       500,
+			mockErr:           nil,
+			mockWithdrawalErr: repository.NoResultError{},
+			wantErr:           false,
+			wantCreated:       true,
 		},
 		{
 			name: "Test_3.Метод возвращает ошибку. Баланс меньше списания",
@@ -287,9 +302,10 @@ func TestBalanceService_Withdraw(t *testing.T) {
 				orderNumber: orderNumber,
 				sum:         501,
 			},
-			mockBalance: 500,
-			mockErr:     &transaction.NotEnoughMoney{},
-			wantErr:     true,
+			mockBalance:       500,
+			mockErr:           &transaction.NotEnoughMoney{},
+			mockWithdrawalErr: repository.NoResultError{},
+			wantErr:           true,
 		},
 		{
 			name: "Test_4.Метод возвращает ошибку. Невалидный формат номера заказа",
@@ -303,27 +319,302 @@ func TestBalanceService_Withdraw(t *testing.T) {
 			mockErr:     &order.InvalidFormat{OrderNumber: "123"},
 			wantErr:     true,
 		},
+		{
+			name: "Test_5.Повторное списание по тому же заказу не создаёт транзакцию",
+			args: args{
+				ctx:         ctx,
+				userID:      userID,
+				orderNumber: orderNumber,
+				sum:         100,
+			},
+			mockBalance:    0,
+			mockWithdrawal: transaction.Transaction{OrderNumber: orderNumber, Sum: -100},
+			wantErr:        false,
+		},
+		{
+			name: "Test_6.Метод возвращает ошибку. Заказ уже оплачен другой суммой",
+			args: args{
+				ctx:         ctx,
+				userID:      userID,
+				orderNumber: orderNumber,
+				sum:         200,
+			},
+			mockBalance:    500,
+			mockWithdrawal: transaction.Transaction{OrderNumber: orderNumber, Sum: -100},
+			mockErr:        &transaction.AlreadyWithdrawn{OrderNumber: orderNumber},
+			wantErr:        true,
+		},
+		{
+			name: "Test_7.Новый ключ идемпотентности сохраняется вместе со списанием",
+			args: args{
+				ctx:            ctx,
+				userID:         userID,
+				orderNumber:    orderNumber,
+				sum:            100,
+				idempotencyKey: idempotencyKey,
+			},
+			mockBalance:       500,
+			mockRequestErr:    repository.NoResultError{},
+			mockWithdrawalErr: repository.NoResultError{},
+			wantErr:           false,
+			wantCreated:       true,
+		},
+		{
+			name: "Test_8.Повтор запроса с ключом возвращает успешный ответ",
+			args: args{
+				ctx:            ctx,
+				userID:         userID,
+				orderNumber:    orderNumber,
+				sum:            100,
+				idempotencyKey: idempotencyKey,
+			},
+			mockBalance: 0,
+			mockRequest: transaction.WithdrawalRequest{
+				IdempotencyKey: idempotencyKey,
+				OrderNumber:    orderNumber,
+				Sum:            100,
+				Result:         transaction.WithdrawalResultSuccess,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test_9.Повтор запроса с ключом возвращает ошибку исходного запроса",
+			args: args{
+				ctx:            ctx,
+				userID:         userID,
+				orderNumber:    orderNumber,
+				sum:            100,
+				idempotencyKey: idempotencyKey,
+			},
+			mockBalance: 500,
+			mockRequest: transaction.WithdrawalRequest{
+				IdempotencyKey: idempotencyKey,
+				OrderNumber:    orderNumber,
+				Sum:            100,
+				Result:         transaction.WithdrawalResultNotEnoughMoney,
+			},
+			mockErr: &transaction.NotEnoughMoney{},
+			wantErr: true,
+		},
+		{
+			name: "Test_10.Метод возвращает ошибку. Ключ использован для другого запроса",
+			args: args{
+				ctx:            ctx,
+				userID:         userID,
+				orderNumber:    orderNumber,
+				sum:            200,
+				idempotencyKey: idempotencyKey,
+			},
+			mockBalance: 500,
+			mockRequest: transaction.WithdrawalRequest{
+				IdempotencyKey: idempotencyKey,
+				OrderNumber:    orderNumber,
+				Sum:            100,
+				Result:         transaction.WithdrawalResultSuccess,
+			},
+			mockErr: &transaction.IdempotencyKeyReused{Key: idempotencyKey},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				rep := mocks.TransactionRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				bs := NewBalanceService(&rep, &txHelper)
+				webhooks := mocks.WebhookRepository{}
+				bs := NewBalanceService(&rep, &mocks.AuditLogRepository{}, &webhooks, &txHelper)
 				tx := storagemocks.Transaction{}
 				txHelper.On("StartTransaction", tt.args.ctx).Return(&tx, nil)
+				webhooks.On("Enqueue", tt.args.ctx, mock.AnythingOfType("webhook.Event"), &bun.Tx{}).Return(nil)
+				rep.On("LockBalance", tt.args.ctx, tt.args.userID, &bun.Tx{}).Return(nil)
+				rep.On("GetWithdrawalRequest", tt.args.ctx, tt.args.userID, tt.args.idempotencyKey, &bun.Tx{}).
+					Return(tt.mockRequest, tt.mockRequestErr)
+				rep.On("GetWithdrawalByOrder", tt.args.ctx, tt.args.userID, tt.args.orderNumber, &bun.Tx{}).
+					Return(tt.mockWithdrawal, tt.mockWithdrawalErr)
 				rep.On("GetBalanceByUser", tt.args.ctx, tt.args.userID, &bun.Tx{}).Return(tt.mockBalance, nil)
 				rep.On("CreateTransaction", tt.args.ctx, mock.AnythingOfType("transaction.Transaction"), &bun.Tx{}).Return(nil)
+				rep.On("CreateWithdrawalRequest", tt.args.ctx, mock.AnythingOfType("transaction.WithdrawalRequest"), &bun.Tx{}).
+					Return(nil)
 				tx.On("Rollback").Return(nil)
 				tx.On("Commit").Return(nil)
 				tx.On("GetTransaction").Return(&bun.Tx{})
-				err := bs.Withdraw(tt.args.ctx, tt.args.sum, tt.args.orderNumber, tt.args.userID)
+				err := bs.Withdraw(tt.args.ctx, tt.args.sum, tt.args.orderNumber, tt.args.userID, tt.args.idempotencyKey)
 				if (err != nil) != tt.wantErr {
 					t.Errorf("Withdraw() error = %v, wantErr %v", err, tt.wantErr)
 				}
 				if tt.wantErr {
 					require.Equal(t, tt.mockErr, err)
 				}
+				if tt.wantCreated {
+					rep.AssertCalled(t, "CreateTransaction", tt.args.ctx, mock.AnythingOfType("transaction.Transaction"), &bun.Tx{})
+					webhooks.AssertNumberOfCalls(t, "Enqueue", 1)
+				} else {
+					rep.AssertNotCalled(t, "CreateTransaction", tt.args.ctx, mock.AnythingOfType("transaction.Transaction"), &bun.Tx{})
+					webhooks.AssertNotCalled(t, "Enqueue", tt.args.ctx, mock.AnythingOfType("webhook.Event"), &bun.Tx{})
+				}
+			},
+		)
+	}
+}
+
+func TestBalanceService_AdjustBalance(t *testing.T) {
+	ctx := context.Background()
+	userID, _ := uuid.NewV7()
+	actorID, _ := uuid.NewV7()
+	tests := []struct {
+		name        string
+		sum         money.Money
+		reason      string
+		mockBalance money.Money
+		mockLockErr error
+		actorID     uuid.UUID
+		wantErr     error
+		wantCreated bool
+	}{
+		{
+			name:        "Test_1. Начисление",
+			sum:         1500,
+			reason:      "компенсация за потерянный заказ",
+			wantCreated: true,
+		},
+		{
+			name:        "Test_2. Списание в пределах баланса",
+			sum:         -500,
+			reason:      "ошибочное начисление",
+			mockBalance: 500,
+			wantCreated: true,
+		},
+		{
+			name:        "Test_3. Списание больше баланса",
+			sum:         -501,
+			reason:      "ошибочное начисление",
+			mockBalance: 500,
+			wantErr:     &transaction.NotEnoughMoney{},
+		},
+		{
+			name:    "Test_4. Без причины",
+			sum:     100,
+			reason:  "   ",
+			wantErr: &transaction.InvalidAdjustment{Message: "reason is required"},
+		},
+		{
+			name:    "Test_5. Нулевая сумма",
+			reason:  "проверка",
+			wantErr: &transaction.InvalidAdjustment{Message: "sum must not be zero"},
+		},
+		{
+			name:        "Test_6. Пользователь не найден",
+			sum:         100,
+			reason:      "проверка",
+			mockLockErr: repository.NoResultError{},
+			wantErr:     &user.NoSuchUser{ID: userID.String()},
+		},
+		{
+			name:    "Test_7. Корректировка собственного баланса",
+			sum:     100,
+			reason:  "премия",
+			actorID: userID,
+			wantErr: &transaction.SelfAdjustment{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.TransactionRepository{}
+				auditRep := mocks.AuditLogRepository{}
+				txHelper := storagemocks.TransactionHelper{}
+				bs := NewBalanceService(&rep, &auditRep, &mocks.WebhookRepository{}, &txHelper)
+				tx := storagemocks.Transaction{}
+				txHelper.On("StartTransaction", ctx).Return(&tx, nil)
+				tx.On("Rollback").Return(nil)
+				tx.On("Commit").Return(nil)
+				tx.On("GetTransaction").Return(&bun.Tx{})
+				rep.On("LockBalance", ctx, userID, &bun.Tx{}).Return(tt.mockLockErr)
+				rep.On("GetBalanceByUser", ctx, userID, &bun.Tx{}).Return(tt.mockBalance, nil)
+				rep.On("CreateTransaction", ctx, mock.AnythingOfType("transaction.Transaction"), &bun.Tx{}).Return(nil)
+				auditRep.On("Record", ctx, mock.AnythingOfType("audit.Entry"), &bun.Tx{}).Return(nil)
+
+				actor := actorID
+				if tt.actorID != uuid.Nil {
+					actor = tt.actorID
+				}
+				got, err := bs.AdjustBalance(ctx, userID, tt.sum, tt.reason, actor)
+				require.Equal(t, tt.wantErr, err)
+				if !tt.wantCreated {
+					rep.AssertNotCalled(t, "CreateTransaction", ctx, mock.AnythingOfType("transaction.Transaction"), &bun.Tx{})
+					auditRep.AssertNotCalled(t, "Record", ctx, mock.AnythingOfType("audit.Entry"), &bun.Tx{})
+					return
+				}
+				require.Equal(t, transaction.TypeAdjustment, got.Type)
+				require.Equal(t, tt.sum, got.Sum)
+				require.Equal(t, tt.reason, got.Reason)
+				entry := auditRep.Calls[0].Arguments.Get(1).(audit.Entry)
+				require.Equal(t, actorID, entry.ActorID)
+				require.Equal(t, userID, entry.TargetUserID)
+				require.Equal(t, got.ID, entry.EntityID)
+				require.Equal(t, tt.reason, entry.Details["reason"])
+			},
+		)
+	}
+}
+
+func TestBalanceService_GetUserHistory(t *testing.T) {
+	ctx := context.Background()
+	userID, _ := uuid.NewV7()
+	entries := make([]transaction.HistoryEntry, 3)
+	for i := range entries {
+		id, _ := uuid.NewV7()
+		entries[i] = transaction.HistoryEntry{ID: id, Type: transaction.TypeIncome, Sum: 100, Balance: money.Money(300 - 100*i)}
+	}
+	tests := []struct {
+		name        string
+		filter      transaction.HistoryFilter
+		mockEntries []transaction.HistoryEntry
+		want        service.TransactionHistory
+		wantErr     error
+	}{
+		{
+			name:        "Test_1. Есть следующая страница",
+			filter:      transaction.HistoryFilter{Limit: 2},
+			mockEntries: entries,
+			want:        service.TransactionHistory{Transactions: entries[:2], NextCursor: entries[1].ID.String()},
+		},
+		{
+			name:        "Test_2. Последняя страница",
+			filter:      transaction.HistoryFilter{Limit: 3},
+			mockEntries: entries,
+			want:        service.TransactionHistory{Transactions: entries},
+		},
+		{
+			name:        "Test_3. Нет транзакций",
+			filter:      transaction.HistoryFilter{Limit: 3},
+			mockEntries: []transaction.HistoryEntry{},
+			wantErr:     &service.NoData{},
+		},
+		{
+			name:    "Test_4. Неизвестный тип",
+			filter:  transaction.HistoryFilter{Types: []string{"BONUS"}, Limit: 3},
+			wantErr: &transaction.InvalidHistoryFilter{Message: "unknown transaction type BONUS"},
+		},
+		{
+			name:    "Test_5. Слишком большой limit",
+			filter:  transaction.HistoryFilter{Limit: transaction.MaxHistoryLimit + 1},
+			wantErr: &transaction.InvalidHistoryFilter{Message: "limit is out of range"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.TransactionRepository{}
+				bs := NewBalanceService(
+					&rep, &mocks.AuditLogRepository{}, &mocks.WebhookRepository{}, &storagemocks.TransactionHelper{},
+				)
+				requested := tt.filter
+				requested.Limit++
+				rep.On("GetHistory", ctx, userID, requested).Return(tt.mockEntries, nil)
+				got, err := bs.GetUserHistory(ctx, userID, tt.filter)
+				require.Equal(t, tt.wantErr, err)
+				require.Equal(t, tt.want, got)
 			},
 		)
 	}
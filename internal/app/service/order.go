@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/money"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
@@ -12,74 +15,285 @@ import (
 	"time"
 )
 
+const (
+	pollingLease       = time.Minute
+	pollingBackoffBase = time.Second
+	pollingBackoffMax  = 5 * time.Minute
+)
+
 type OrderService struct {
-	orderRepo repository.OrderRepository
-	txHelper  storage.TransactionHelper
+	orderRepo   repository.OrderRepository
+	accrualRepo repository.AccrualResultRepository
+	webhooks    repository.WebhookRepository
+	txHelper    storage.TransactionHelper
+	events      service.OrderEventPublisher
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, txHelper storage.TransactionHelper) *OrderService {
-	return &OrderService{orderRepo: orderRepo, txHelper: txHelper}
+func NewOrderService(
+	orderRepo repository.OrderRepository, accrualRepo repository.AccrualResultRepository,
+	webhooks repository.WebhookRepository, txHelper storage.TransactionHelper, events service.OrderEventPublisher,
+) *OrderService {
+	return &OrderService{
+		orderRepo: orderRepo, accrualRepo: accrualRepo, webhooks: webhooks, txHelper: txHelper, events: events,
+	}
 }
 
 func (os OrderService) LoadOrderByNumber(ctx context.Context, number string, userID uuid.UUID) error {
 	if !order.ValidateOrderFormat(number) {
 		return &order.InvalidFormat{OrderNumber: number}
 	}
-	tx, err := os.txHelper.StartTransaction(ctx)
-	if err != nil {
-		return err
-	}
-	if o, err := os.orderRepo.GetByNumber(ctx, number, tx.GetTransaction()); err == nil {
-		return &order.AlreadyLoaded{
-			OrderNumber: o.Number,
-			UserID:      o.UserID,
-		}
-	}
 	id, err := uuid.NewV7()
 	if err != nil {
 		return err
 	}
-	if err := os.orderRepo.CreateOrder(
+	// Повторную загрузку номера отклоняет уникальный индекс, а владельца заказа узнаём уже после вставки
+	err = os.orderRepo.CreateOrder(
 		ctx, order.Order{
 			ID:         id,
 			UserID:     userID,
 			Number:     number,
 			Status:     order.StatusNew,
 			UploadedAt: time.Now(),
-		}, tx.GetTransaction(),
-	); err != nil {
-		if err := tx.Rollback(); err != nil {
-			return err
-		}
+		}, nil,
+	)
+	if !repository.ViolatesUnique(err, repository.ConstraintOrderNumber) {
 		return err
 	}
-	if err := tx.Commit(); err != nil {
+	o, err := os.orderRepo.GetByNumber(ctx, number, nil)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return &order.AlreadyLoaded{
+		OrderNumber: o.Number,
+		UserID:      o.UserID,
+	}
 }
 
-func (os OrderService) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]service.OrderInfo, error) {
-	orders, err := os.orderRepo.GetAllByUser(ctx, userID)
+// LoadOrders загружает пакет номеров заказов в одной транзакции и возвращает результат по каждому номеру
+// в порядке запроса. Повторы номера внутри пакета схлопываются в один результат.
+func (os OrderService) LoadOrders(ctx context.Context, numbers []string, userID uuid.UUID) ([]order.LoadResult, error) {
+	if len(numbers) == 0 {
+		return nil, &order.InvalidBatch{Message: "no order numbers"}
+	}
+	if len(numbers) > order.MaxBatchSize {
+		return nil, &order.InvalidBatch{Message: "too many order numbers"}
+	}
+	results := make([]order.LoadResult, 0, len(numbers))
+	seen := make(map[string]bool, len(numbers))
+	var orders []order.Order
+	for _, number := range numbers {
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+		if !order.ValidateOrderFormat(number) {
+			results = append(results, order.LoadResult{Number: number, Result: order.LoadInvalidFormat})
+			continue
+		}
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+		orders = append(
+			orders, order.Order{
+				ID:         id,
+				UserID:     userID,
+				Number:     number,
+				Status:     order.StatusNew,
+				UploadedAt: time.Now(),
+			},
+		)
+		results = append(results, order.LoadResult{Number: number})
+	}
+	if len(orders) == 0 {
+		return results, nil
+	}
+
+	tx, err := os.txHelper.StartTransaction(ctx)
 	if err != nil {
 		return nil, err
 	}
+	created, err := os.orderRepo.CreateOrdersIfNotExist(ctx, orders, tx.GetTransaction())
+	if err != nil {
+		return nil, rollback(tx, err)
+	}
+	isCreated := make(map[string]bool, len(created))
+	for _, number := range created {
+		isCreated[number] = true
+	}
+	var existingNumbers []string
+	for _, o := range orders {
+		if !isCreated[o.Number] {
+			existingNumbers = append(existingNumbers, o.Number)
+		}
+	}
+	owners := make(map[string]uuid.UUID, len(existingNumbers))
+	if len(existingNumbers) > 0 {
+		existing, err := os.orderRepo.GetBatchByNumbers(ctx, existingNumbers, tx.GetTransaction())
+		if err != nil {
+			return nil, rollback(tx, err)
+		}
+		for _, o := range existing {
+			owners[o.Number] = o.UserID
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i, r := range results {
+		switch {
+		case r.Result != "":
+		case isCreated[r.Number]:
+			results[i].Result = order.LoadAccepted
+		case owners[r.Number] == userID:
+			results[i].Result = order.LoadAlreadyUploaded
+		default:
+			results[i].Result = order.LoadUploadedByOthers
+		}
+	}
+
+	return results, nil
+}
+
+// GetUserOrders возвращает страницу заказов пользователя. Запрашивается на один заказ больше,
+// чтобы понять, есть ли следующая страница.
+func (os OrderService) GetUserOrders(
+	ctx context.Context, userID uuid.UUID, filter order.ListFilter,
+) (service.OrderPage, error) {
+	if err := filter.Validate(); err != nil {
+		return service.OrderPage{}, err
+	}
+	limit := filter.Limit
+	filter.Limit++
+	orders, err := os.orderRepo.GetPageByUser(ctx, userID, filter)
+	if err != nil {
+		return service.OrderPage{}, err
+	}
 	if len(orders) == 0 {
-		return nil, &service.NoData{}
+		return service.OrderPage{}, &service.NoData{}
+	}
+	page := service.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := orders[limit-1]
+		page.NextCursor = order.Cursor{UploadedAt: last.UploadedAt, ID: last.ID}.Encode()
 	}
 
-	return orders, nil
+	return page, nil
 }
 
-func (os OrderService) UpdateOrdersAndBalance(ctx context.Context, info map[string]clients.OrderLoyaltyInfo) []error {
-	orders, transactions, errors := os.makeOrdersAndTransactions(ctx, info)
+// SaveAccrualResult сохраняет ответ системы лояльности, чтобы он был применён даже после перезапуска сервиса
+func (os OrderService) SaveAccrualResult(ctx context.Context, info clients.OrderLoyaltyInfo) error {
+	return os.accrualRepo.SaveResult(
+		ctx, order.AccrualResult{
+			OrderNumber: info.Order,
+			Status:      info.Status,
+			Accrual:     info.Accrual,
+			ReceivedAt:  time.Now(),
+		},
+	)
+}
 
-	if err := os.orderRepo.BatchUpdateOrdersAndBalance(ctx, orders, transactions); err != nil {
-		errors = append(errors, err)
+// UpdateOrdersAndBalance применяет до limit сохранённых ответов системы лояльности к заказам и балансу
+// и отмечает их применёнными в той же транзакции. Возвращает количество обработанных ответов.
+func (os OrderService) UpdateOrdersAndBalance(ctx context.Context, limit int) (int, []error) {
+	tx, err := os.txHelper.StartTransaction(ctx)
+	if err != nil {
+		return 0, []error{err}
+	}
+	results, err := os.accrualRepo.ClaimPending(ctx, limit, tx.GetTransaction())
+	if err != nil {
+		return 0, []error{rollback(tx, err)}
+	}
+	if len(results) == 0 {
+		if err := tx.Rollback(); err != nil {
+			return 0, []error{err}
+		}
+		return 0, nil
+	}
+	info := make(map[string]clients.OrderLoyaltyInfo, len(results))
+	receivedAt := make(map[string]time.Time, len(results))
+	orderNumbers := make([]string, len(results))
+	for i, r := range results {
+		info[r.OrderNumber] = clients.OrderLoyaltyInfo{
+			Order:   r.OrderNumber,
+			Status:  r.Status,
+			Accrual: r.Accrual,
+		}
+		receivedAt[r.OrderNumber] = r.ReceivedAt
+		orderNumbers[i] = r.OrderNumber
 	}
 
-	return errors
+	orders, err := os.orderRepo.GetBatchByNumbers(ctx, orderNumbers, tx.GetTransaction())
+	if err != nil {
+		return 0, []error{rollback(tx, err)}
+	}
+	previousStatus := make(map[string]string, len(orders))
+	for _, o := range orders {
+		previousStatus[o.Number] = o.Status
+	}
+
+	orders, transactions, errors := os.makeOrdersAndTransactions(info, orders)
+	updated, err := os.orderRepo.BatchUpdateOrdersAndBalance(ctx, orders, transactions, tx.GetTransaction())
+	if err != nil {
+		return 0, append(errors, rollback(tx, err))
+	}
+	// В историю попадают только заказы, которые действительно перешли в новый статус в этой транзакции
+	isUpdated := make(map[string]bool, len(updated))
+	for _, number := range updated {
+		isUpdated[number] = true
+	}
+	var changes []order.StatusChange
+	var changedOrders []order.Order
+	for _, o := range orders {
+		if !isUpdated[o.Number] || previousStatus[o.Number] == o.Status {
+			continue
+		}
+		change, err := newStatusChange(o, receivedAt[o.Number])
+		if err != nil {
+			return 0, append(errors, rollback(tx, err))
+		}
+		changes = append(changes, change)
+		changedOrders = append(changedOrders, o)
+	}
+	if err := os.orderRepo.AddStatusChanges(ctx, changes, tx.GetTransaction()); err != nil {
+		return 0, append(errors, rollback(tx, err))
+	}
+	accruals := make(map[string]money.Money, len(transactions))
+	for _, t := range transactions {
+		accruals[t.OrderNumber] = t.Sum
+	}
+	events := make([]order.StatusEvent, len(changes))
+	for i, change := range changes {
+		events[i] = order.StatusEvent{
+			ID:        change.ID,
+			Seq:       change.Seq,
+			Number:    changedOrders[i].Number,
+			Status:    change.Status,
+			ChangedAt: change.ChangedAt,
+		}
+		if change.Status == order.StatusProcessed {
+			events[i].Accrual = accruals[events[i].Number]
+		}
+		if err := os.enqueueWebhook(ctx, changedOrders[i].UserID, events[i], tx); err != nil {
+			return 0, append(errors, rollback(tx, err))
+		}
+	}
+	if err := os.accrualRepo.MarkProcessed(ctx, orderNumbers, tx.GetTransaction()); err != nil {
+		return 0, append(errors, rollback(tx, err))
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, append(errors, err)
+	}
+
+	// Подписчики узнают об изменениях только после фиксации транзакции
+	for i, event := range events {
+		os.events.Publish(changedOrders[i].UserID, event)
+	}
+
+	return len(results), errors
 }
 
 func (os OrderService) InvalidateOrder(ctx context.Context, number string) error {
@@ -94,31 +308,79 @@ func (os OrderService) InvalidateOrder(ctx context.Context, number string) error
 		}
 		return err
 	}
-	o.Status = order.StatusInvalid
-	if err := os.orderRepo.UpdateOrder(ctx, o, tx.GetTransaction()); err != nil {
+	if o.Status == order.StatusProcessed || o.Status == order.StatusInvalid {
+		return rollback(tx, &order.AlreadyFinal{OrderNumber: number, Status: o.Status})
+	}
+	// Статус мог измениться после чтения, поэтому условие повторяется в запросе
+	changed, err := os.orderRepo.MarkInvalid(ctx, o.ID, tx.GetTransaction())
+	if err != nil {
 		if err := tx.Rollback(); err != nil {
 			return err
 		}
 		return err
 	}
+	if !changed {
+		status := order.StatusProcessed
+		if current, err := os.orderRepo.GetByNumber(ctx, number, tx.GetTransaction()); err == nil {
+			status = current.Status
+		}
+		return rollback(tx, &order.AlreadyFinal{OrderNumber: number, Status: status})
+	}
+	o.Status = order.StatusInvalid
+	change, err := newStatusChange(o, time.Now())
+	if err != nil {
+		return rollback(tx, err)
+	}
+	if err := os.orderRepo.AddStatusChanges(ctx, []order.StatusChange{change}, tx.GetTransaction()); err != nil {
+		return rollback(tx, err)
+	}
+	event := order.StatusEvent{
+		ID: change.ID, Seq: change.Seq, Number: o.Number, Status: change.Status, ChangedAt: change.ChangedAt,
+	}
+	if err := os.enqueueWebhook(ctx, o.UserID, event, tx); err != nil {
+		return rollback(tx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	os.events.Publish(o.UserID, event)
+
+	return nil
+}
+
+// GetUserOrderEvents возвращает до order.MaxReplayEvents изменений заказов пользователя,
+// зафиксированных после события с номером after, от старых к новым
+func (os OrderService) GetUserOrderEvents(
+	ctx context.Context, userID uuid.UUID, after int64,
+) ([]order.StatusEvent, error) {
+	return os.orderRepo.GetStatusEventsByUser(ctx, userID, after, order.MaxReplayEvents)
+}
+
+// GetUserOrder возвращает заказ пользователя с историей статусов. История начинается с загрузки заказа:
+// статус NEW заказ получает при создании, и отдельной записи для него нет.
+func (os OrderService) GetUserOrder(ctx context.Context, userID uuid.UUID, number string) (service.OrderDetails, error) {
+	info, err := os.orderRepo.GetUserOrderInfo(ctx, userID, number)
+	if err != nil {
+		if errors.Is(err, repository.NoResultError{}) {
+			return service.OrderDetails{}, &order.NoSuchOrder{OrderNumber: number}
+		}
+		return service.OrderDetails{}, err
+	}
+	changes, err := os.orderRepo.GetStatusHistory(ctx, info.ID)
+	if err != nil {
+		return service.OrderDetails{}, err
+	}
+	history := make([]order.StatusChange, 0, len(changes)+1)
+	history = append(history, order.StatusChange{OrderID: info.ID, Status: order.StatusNew, ChangedAt: info.UploadedAt})
 
-	return tx.Commit()
+	return service.OrderDetails{OrderInfo: info, History: append(history, changes...)}, nil
 }
 
 func (os OrderService) makeOrdersAndTransactions(
-	ctx context.Context, info map[string]clients.OrderLoyaltyInfo,
+	info map[string]clients.OrderLoyaltyInfo, orders []order.Order,
 ) ([]order.Order, []transaction.Transaction, []error) {
 	var errors []error
 	var transactions []transaction.Transaction
-	orderNumbers := os.getOrderNumbersByOrderInfos(info)
-	orders, err := os.orderRepo.GetBatchByNumbers(ctx, orderNumbers)
-	if err != nil {
-		errors = append(errors, err)
-		return orders, transactions, errors
-	}
-	if len(orders) == 0 {
-		return orders, transactions, errors
-	}
 	for n, o := range orders {
 		i, ok := info[o.Number]
 		if !ok {
@@ -139,7 +401,7 @@ func (os OrderService) makeOrdersAndTransactions(
 		}
 		orders[n].Status = orderStatus
 		if i.Accrual > 0 {
-			id, _ := uuid.NewV4()
+			id, _ := uuid.NewV7()
 			transactions = append(
 				transactions, transaction.Transaction{
 					ID:          id,
@@ -155,9 +417,77 @@ func (os OrderService) makeOrdersAndTransactions(
 	return orders, transactions, errors
 }
 
-func (os OrderService) GetUnprocessedOrders(ctx context.Context) ([]order.Order, error) {
+// ClaimUnprocessedOrders забирает из очереди опроса до limit заказов, время проверки которых наступило
+func (os OrderService) ClaimUnprocessedOrders(ctx context.Context, limit int) ([]order.Order, error) {
 	notFinalStatuses := []string{order.StatusNew, order.StatusProcessing}
-	return os.orderRepo.GetAllByStatuses(ctx, notFinalStatuses)
+	return os.orderRepo.ClaimForPolling(ctx, notFinalStatuses, limit, pollingLease)
+}
+
+// ScheduleNextCheck снимает блокировку с опрошенного заказа и откладывает следующую проверку
+// с экспоненциально растущим интервалом
+func (os OrderService) ScheduleNextCheck(ctx context.Context, o order.Order) error {
+	return os.orderRepo.ScheduleNextCheck(ctx, o.ID, time.Now().Add(pollingBackoff(o.Attempts)))
+}
+
+// RequeueOrder ставит заказ в очередь опроса немедленно. Заказы с конечным статусом не опрашиваются.
+func (os OrderService) RequeueOrder(ctx context.Context, number string) error {
+	o, err := os.orderRepo.GetByNumber(ctx, number, nil)
+	if err != nil {
+		if errors.Is(err, repository.NoResultError{}) {
+			return &order.NoSuchOrder{OrderNumber: number}
+		}
+		return err
+	}
+	if o.Status == order.StatusProcessed || o.Status == order.StatusInvalid {
+		return &order.AlreadyFinal{OrderNumber: number, Status: o.Status}
+	}
+
+	return os.orderRepo.RequeueForPolling(ctx, o.ID, time.Now())
+}
+
+// enqueueWebhook ставит в очередь webhook о переходе заказа в конечный статус в транзакции перехода
+func (os OrderService) enqueueWebhook(
+	ctx context.Context, userID uuid.UUID, event order.StatusEvent, tx storage.Transaction,
+) error {
+	var eventType string
+	switch event.Status {
+	case order.StatusProcessed:
+		eventType = webhook.EventOrderProcessed
+	case order.StatusInvalid:
+		eventType = webhook.EventOrderInvalid
+	default:
+		return nil
+	}
+
+	return os.webhooks.Enqueue(
+		ctx, webhook.Event{
+			ID:        event.ID,
+			UserID:    userID,
+			Type:      eventType,
+			Data:      webhook.OrderData{Number: event.Number, Status: event.Status, Accrual: event.Accrual},
+			CreatedAt: time.Now(),
+		}, tx.GetTransaction(),
+	)
+}
+
+func newStatusChange(o order.Order, changedAt time.Time) (order.StatusChange, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return order.StatusChange{}, err
+	}
+
+	return order.StatusChange{ID: id, OrderID: o.ID, Status: o.Status, ChangedAt: changedAt}, nil
+}
+
+func pollingBackoff(attempts int) time.Duration {
+	backoff := pollingBackoffBase
+	for i := 1; i < attempts && backoff < pollingBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > pollingBackoffMax {
+		return pollingBackoffMax
+	}
+	return backoff
 }
 
 func (os OrderService) getOrderStatusFromLoyalty(loyaltyStatus string) (string, bool) {
@@ -171,14 +501,3 @@ func (os OrderService) getOrderStatusFromLoyalty(loyaltyStatus string) (string,
 	status, ok := statusMap[loyaltyStatus]
 	return status, ok
 }
-
-func (os OrderService) getOrderNumbersByOrderInfos(info map[string]clients.OrderLoyaltyInfo) []string {
-	orderNumbers := make([]string, len(info))
-	n := 0
-	for _, i := range info {
-		orderNumbers[n] = i.Order
-		n++
-	}
-
-	return orderNumbers
-}
@@ -8,13 +8,12 @@ import (
 )
 
 type OrderProcessor struct {
-	orderInfosChannel chan<- clients.OrderLoyaltyInfo
-	loyaltyClient     clients.LoyalClient
-	os                service.OrderService
+	loyaltyClient clients.LoyalClient
+	os            service.OrderService
 }
 
-func NewOrderProcessor(orderInfosChannel chan<- clients.OrderLoyaltyInfo, loyaltyClient clients.LoyalClient, os service.OrderService) *OrderProcessor {
-	return &OrderProcessor{orderInfosChannel: orderInfosChannel, loyaltyClient: loyaltyClient, os: os}
+func NewOrderProcessor(loyaltyClient clients.LoyalClient, os service.OrderService) *OrderProcessor {
+	return &OrderProcessor{loyaltyClient: loyaltyClient, os: os}
 }
 
 func (op OrderProcessor) ProcessNewOrder(ctx context.Context, number string) error {
@@ -22,7 +21,7 @@ func (op OrderProcessor) ProcessNewOrder(ctx context.Context, number string) err
 	case <-ctx.Done():
 		return errors.New("context canceled")
 	default:
-		orderInfo, err := op.loyaltyClient.GetOrderProcessingInfo(number)
+		orderInfo, err := op.loyaltyClient.GetOrderProcessingInfo(ctx, number)
 		if err != nil {
 			if errors.Is(err, clients.NoOrderError{}) {
 				_ = op.os.InvalidateOrder(ctx, number)
@@ -31,8 +30,6 @@ func (op OrderProcessor) ProcessNewOrder(ctx context.Context, number string) err
 			return err
 		}
 
-		op.orderInfosChannel <- orderInfo
-
-		return nil
+		return op.os.SaveAccrualResult(ctx, orderInfo)
 	}
 }
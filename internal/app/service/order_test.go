@@ -5,10 +5,12 @@ import (
 	"errors"
 	"github.com/ShiraazMoollatjie/goluhn"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/order"
-	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/transaction"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository/mocks"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/pubsub"
 	storagemocks "github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/mocks"
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/mock"
@@ -23,16 +25,25 @@ func TestOrderService_GetUserOrders(t *testing.T) {
 	orderNumber := goluhn.Generate(10)
 	ctx := context.Background()
 	userID, _ := uuid.NewV7()
+	uploadedAt := time.Now().Truncate(time.Microsecond)
+	pageOrders := make([]service.OrderInfo, 3)
+	for i := range pageOrders {
+		id, _ := uuid.NewV7()
+		pageOrders[i] = service.OrderInfo{
+			ID: id, Number: goluhn.Generate(10), Status: order.StatusNew, UploadedAt: uploadedAt.Add(-time.Duration(i) * time.Second),
+		}
+	}
 	type args struct {
 		ctx    context.Context
 		userID uuid.UUID
+		filter order.ListFilter
 	}
 	tests := []struct {
 		name      string
 		args      args
 		wantErr   bool
 		wantedErr error
-		wantedRes []service.OrderInfo
+		wantedRes service.OrderPage
 		mockRes   []service.OrderInfo
 		mockErr   error
 	}{
@@ -41,6 +52,7 @@ func TestOrderService_GetUserOrders(t *testing.T) {
 			args: args{
 				ctx:    ctx,
 				userID: userID,
+				filter: order.ListFilter{Limit: order.DefaultListLimit},
 			},
 			mockRes: []service.OrderInfo{
 				{
@@ -50,12 +62,14 @@ func TestOrderService_GetUserOrders(t *testing.T) {
 					UploadedAt: time.Time{},
 				},
 			},
-			wantedRes: []service.OrderInfo{
-				{
-					Number:     orderNumber,
-					Status:     order.StatusNew,
-					Accrual:    100,
-					UploadedAt: time.Time{},
+			wantedRes: service.OrderPage{
+				Orders: []service.OrderInfo{
+					{
+						Number:     orderNumber,
+						Status:     order.StatusNew,
+						Accrual:    100,
+						UploadedAt: time.Time{},
+					},
 				},
 			},
 		},
@@ -64,23 +78,49 @@ func TestOrderService_GetUserOrders(t *testing.T) {
 			args: args{
 				ctx:    ctx,
 				userID: userID,
+				filter: order.ListFilter{Limit: order.DefaultListLimit},
 			},
 			wantErr:   true,
 			mockRes:   []service.OrderInfo{},
 			wantedErr: &service.NoData{},
-			wantedRes: nil,
+			wantedRes: service.OrderPage{},
 		},
 		{
 			name: "Test_3. Ошибка репозитория",
 			args: args{
 				ctx:    ctx,
 				userID: userID,
+				filter: order.ListFilter{Limit: order.DefaultListLimit},
 			},
 			wantErr:   true,
 			mockRes:   []service.OrderInfo{},
 			mockErr:   errors.New("db gone away"),
 			wantedErr: errors.New("db gone away"),
-			wantedRes: nil,
+			wantedRes: service.OrderPage{},
+		},
+		{
+			name: "Test_4. Есть следующая страница",
+			args: args{
+				ctx:    ctx,
+				userID: userID,
+				filter: order.ListFilter{Limit: 2},
+			},
+			mockRes: pageOrders,
+			wantedRes: service.OrderPage{
+				Orders:     pageOrders[:2],
+				NextCursor: order.Cursor{UploadedAt: pageOrders[1].UploadedAt, ID: pageOrders[1].ID}.Encode(),
+			},
+		},
+		{
+			name: "Test_5. Неизвестный статус",
+			args: args{
+				ctx:    ctx,
+				userID: userID,
+				filter: order.ListFilter{Statuses: []string{"LOST"}, Limit: 2},
+			},
+			wantErr:   true,
+			wantedErr: &order.InvalidListFilter{Message: "unknown order status LOST"},
+			wantedRes: service.OrderPage{},
 		},
 	}
 	for _, tt := range tests {
@@ -88,11 +128,15 @@ func TestOrderService_GetUserOrders(t *testing.T) {
 			tt.name, func(t *testing.T) {
 				rep := mocks.OrderRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				os := NewOrderService(&rep, &txHelper)
+				os := NewOrderService(
+					&rep, &mocks.AccrualResultRepository{}, &mocks.WebhookRepository{}, &txHelper, pubsub.NewHub(),
+				)
 
-				rep.On("GetAllByUser", tt.args.ctx, tt.args.userID).Return(tt.mockRes, tt.mockErr)
+				requested := tt.args.filter
+				requested.Limit++
+				rep.On("GetPageByUser", tt.args.ctx, tt.args.userID, requested).Return(tt.mockRes, tt.mockErr)
 
-				expOrder, err := os.GetUserOrders(tt.args.ctx, tt.args.userID)
+				expOrder, err := os.GetUserOrders(tt.args.ctx, tt.args.userID, tt.args.filter)
 				if (err != nil) != tt.wantErr {
 					t.Errorf("GetUserOrders() error = %v, wantErr %v", err, tt.wantErr)
 					return
@@ -106,9 +150,10 @@ func TestOrderService_GetUserOrders(t *testing.T) {
 	}
 }
 
-func TestOrderService_GetUnprocessedOrders(t *testing.T) {
+func TestOrderService_ClaimUnprocessedOrders(t *testing.T) {
 	type args struct {
-		ctx context.Context
+		ctx   context.Context
+		limit int
 	}
 	notFinalStatuses := []string{order.StatusNew, order.StatusProcessing}
 	ctx := context.Background()
@@ -124,7 +169,8 @@ func TestOrderService_GetUnprocessedOrders(t *testing.T) {
 		{
 			name: "Test_1. Заказы есть",
 			args: args{
-				ctx: ctx,
+				ctx:   ctx,
+				limit: 10,
 			},
 			mockRes: []order.Order{
 				{},
@@ -136,7 +182,8 @@ func TestOrderService_GetUnprocessedOrders(t *testing.T) {
 		{
 			name: "Test_2. Заказов нет",
 			args: args{
-				ctx: ctx,
+				ctx:   ctx,
+				limit: 10,
 			},
 			mockRes:   []order.Order{},
 			wantedRes: []order.Order{},
@@ -144,7 +191,8 @@ func TestOrderService_GetUnprocessedOrders(t *testing.T) {
 		{
 			name: "Test_3. Ошибка репозитория",
 			args: args{
-				ctx: ctx,
+				ctx:   ctx,
+				limit: 10,
 			},
 			wantErr:   true,
 			mockRes:   nil,
@@ -158,21 +206,71 @@ func TestOrderService_GetUnprocessedOrders(t *testing.T) {
 			tt.name, func(t *testing.T) {
 				rep := mocks.OrderRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				os := NewOrderService(&rep, &txHelper)
-				rep.On("GetAllByStatuses", tt.args.ctx, notFinalStatuses).Return(tt.mockRes, tt.mockErr)
-				orders, err := os.GetUnprocessedOrders(tt.args.ctx)
+				os := NewOrderService(
+					&rep, &mocks.AccrualResultRepository{}, &mocks.WebhookRepository{}, &txHelper, pubsub.NewHub(),
+				)
+				rep.On("ClaimForPolling", tt.args.ctx, notFinalStatuses, tt.args.limit, pollingLease).Return(tt.mockRes, tt.mockErr)
+				orders, err := os.ClaimUnprocessedOrders(tt.args.ctx, tt.args.limit)
 				if (err != nil) != tt.wantErr {
-					t.Errorf("GetUnprocessedOrders() error = %v, wantErr %v", err, tt.wantErr)
+					t.Errorf("ClaimUnprocessedOrders() error = %v, wantErr %v", err, tt.wantErr)
 					return
 				}
 				if !reflect.DeepEqual(orders, tt.wantedRes) {
-					t.Errorf("GetUnprocessedOrders() got = %v, want %v", orders, tt.wantedRes)
+					t.Errorf("ClaimUnprocessedOrders() got = %v, want %v", orders, tt.wantedRes)
 				}
 			},
 		)
 	}
 }
 
+func TestOrderService_ScheduleNextCheck(t *testing.T) {
+	ctx := context.Background()
+	orderID, _ := uuid.NewV7()
+	tests := []struct {
+		name     string
+		attempts int
+		backoff  time.Duration
+	}{
+		{
+			name:     "Test_1. Первая попытка",
+			attempts: 1,
+			backoff:  pollingBackoffBase,
+		},
+		{
+			name:     "Test_2. Интервал удваивается с каждой попыткой",
+			attempts: 4,
+			backoff:  8 * pollingBackoffBase,
+		},
+		{
+			name:     "Test_3. Интервал ограничен сверху",
+			attempts: 100,
+			backoff:  pollingBackoffMax,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.OrderRepository{}
+				txHelper := storagemocks.TransactionHelper{}
+				os := NewOrderService(
+					&rep, &mocks.AccrualResultRepository{}, &mocks.WebhookRepository{}, &txHelper, pubsub.NewHub(),
+				)
+				before := time.Now()
+				rep.On(
+					"ScheduleNextCheck", ctx, orderID, mock.MatchedBy(
+						func(nextCheckAt time.Time) bool {
+							return !nextCheckAt.Before(before.Add(tt.backoff)) && !nextCheckAt.After(time.Now().Add(tt.backoff))
+						},
+					),
+				).Return(nil)
+				err := os.ScheduleNextCheck(ctx, order.Order{ID: orderID, Attempts: tt.attempts})
+				require.NoError(t, err)
+				rep.AssertExpectations(t)
+			},
+		)
+	}
+}
+
 func TestOrderService_InvalidateOrder(t *testing.T) {
 	type args struct {
 		ctx    context.Context
@@ -188,6 +286,7 @@ func TestOrderService_InvalidateOrder(t *testing.T) {
 		mockRes         order.Order
 		mockGetOrderErr error
 		mockUpdateErr   error
+		mockNotChanged  bool
 	}{
 		{
 			name: "Test_1. Успешная инвалидация",
@@ -228,28 +327,76 @@ func TestOrderService_InvalidateOrder(t *testing.T) {
 			mockUpdateErr:   errors.New("can not update"),
 			wantedErr:       errors.New("can not update"),
 		},
+		{
+			name: "Test_4. Заказ уже начислен",
+			args: args{
+				ctx:    ctx,
+				number: orderNumber,
+			},
+			wantErr: true,
+			mockRes: order.Order{
+				Number: orderNumber,
+				Status: order.StatusProcessed,
+			},
+			wantedErr: &order.AlreadyFinal{OrderNumber: orderNumber, Status: order.StatusProcessed},
+		},
+		{
+			name: "Test_5. Заказ начислен параллельно",
+			args: args{
+				ctx:    ctx,
+				number: orderNumber,
+			},
+			wantErr: true,
+			mockRes: order.Order{
+				Number: orderNumber,
+				Status: order.StatusProcessing,
+			},
+			mockNotChanged: true,
+			wantedErr:      &order.AlreadyFinal{OrderNumber: orderNumber, Status: order.StatusProcessed},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				rep := mocks.OrderRepository{}
+				webhooks := mocks.WebhookRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				os := NewOrderService(&rep, &txHelper)
+				os := NewOrderService(&rep, &mocks.AccrualResultRepository{}, &webhooks, &txHelper, pubsub.NewHub())
 				tx := storagemocks.Transaction{}
 				txHelper.On("StartTransaction", tt.args.ctx).Return(&tx, nil)
 				tx.On("Rollback").Return(nil)
 				tx.On("Commit").Return(nil)
 				tx.On("GetTransaction").Return(&bun.Tx{})
-				rep.On("GetByNumber", tt.args.ctx, tt.args.number, &bun.Tx{}).Return(tt.mockRes, tt.mockGetOrderErr)
+				webhooks.On(
+					"Enqueue", tt.args.ctx, mock.MatchedBy(
+						func(e webhook.Event) bool {
+							return e.Type == webhook.EventOrderInvalid
+						},
+					), &bun.Tx{},
+				).Return(nil)
+				rep.On("GetByNumber", tt.args.ctx, tt.args.number, &bun.Tx{}).Return(tt.mockRes, tt.mockGetOrderErr).Once()
+				processed := tt.mockRes
+				processed.Status = order.StatusProcessed
+				rep.On("GetByNumber", tt.args.ctx, tt.args.number, &bun.Tx{}).Return(processed, nil)
+				rep.On("MarkInvalid", tt.args.ctx, tt.mockRes.ID, &bun.Tx{}).Return(!tt.mockNotChanged, tt.mockUpdateErr)
 				rep.On(
-					"UpdateOrder", tt.args.ctx, order.Order{Number: tt.args.number, Status: order.StatusInvalid}, &bun.Tx{},
-				).Return(tt.mockUpdateErr)
+					"AddStatusChanges", tt.args.ctx, mock.MatchedBy(
+						func(changes []order.StatusChange) bool {
+							return len(changes) == 1 && changes[0].Status == order.StatusInvalid
+						},
+					), &bun.Tx{},
+				).Return(nil)
 				err := os.InvalidateOrder(tt.args.ctx, tt.args.number)
 				if (err != nil) != tt.wantErr {
 					t.Errorf("InvalidateOrder() error = %v, wantErr %v", err, tt.wantErr)
 				}
 				if tt.wantErr {
 					require.Equal(t, tt.wantedErr, err)
+					rep.AssertNotCalled(t, "AddStatusChanges", mock.Anything, mock.Anything, mock.Anything)
+					webhooks.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything, mock.Anything)
+				} else {
+					rep.AssertNumberOfCalls(t, "AddStatusChanges", 1)
+					webhooks.AssertNumberOfCalls(t, "Enqueue", 1)
 				}
 			},
 		)
@@ -279,9 +426,8 @@ func TestOrderService_LoadOrderByNumber(t *testing.T) {
 				ctx:    ctx,
 				number: orderNumber,
 			},
-			mockRes:         order.Order{},
-			mockGetOrderErr: errors.New("no order"),
-			wantedErr:       nil,
+			mockRes:   order.Order{},
+			wantedErr: nil,
 		},
 		{
 			name: "Test_2. Невалидный номер",
@@ -303,7 +449,8 @@ func TestOrderService_LoadOrderByNumber(t *testing.T) {
 				UserID: uuid.UUID{},
 				Number: orderNumber,
 			},
-			wantErr: true,
+			mockCreateErr: repository.UniqueViolation{Constraint: repository.ConstraintOrderNumber},
+			wantErr:       true,
 			wantedErr: &order.AlreadyLoaded{
 				OrderNumber: orderNumber,
 				UserID:      uuid.UUID{},
@@ -315,26 +462,45 @@ func TestOrderService_LoadOrderByNumber(t *testing.T) {
 				ctx:    ctx,
 				number: orderNumber,
 			},
+			mockRes:       order.Order{},
+			mockCreateErr: errors.New("can not create order"),
+			wantErr:       true,
+			wantedErr:     errors.New("can not create order"),
+		},
+		{
+			name: "Test_5. Номер занят, но заказ не удалось получить",
+			args: args{
+				ctx:    ctx,
+				number: orderNumber,
+			},
 			mockRes:         order.Order{},
-			mockGetOrderErr: errors.New("no order"),
-			mockCreateErr:   errors.New("can not create order"),
+			mockCreateErr:   repository.UniqueViolation{Constraint: repository.ConstraintOrderNumber},
+			mockGetOrderErr: errors.New("can not get order"),
 			wantErr:         true,
-			wantedErr:       errors.New("can not create order"),
+			wantedErr:       errors.New("can not get order"),
+		},
+		{
+			name: "Test_6. Нарушено другое ограничение уникальности",
+			args: args{
+				ctx:    ctx,
+				number: orderNumber,
+			},
+			mockRes:       order.Order{},
+			mockCreateErr: repository.UniqueViolation{Constraint: "orders_pkey"},
+			wantErr:       true,
+			wantedErr:     repository.UniqueViolation{Constraint: "orders_pkey"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				rep := mocks.OrderRepository{}
-				txHelper := storagemocks.TransactionHelper{}
-				os := NewOrderService(&rep, &txHelper)
-				tx := storagemocks.Transaction{}
-				txHelper.On("StartTransaction", tt.args.ctx).Return(&tx, nil)
-				tx.On("Rollback").Return(nil)
-				tx.On("Commit").Return(nil)
-				tx.On("GetTransaction").Return(&bun.Tx{})
-				rep.On("GetByNumber", tt.args.ctx, tt.args.number, &bun.Tx{}).Return(tt.mockRes, tt.mockGetOrderErr)
-				rep.On("CreateOrder", tt.args.ctx, mock.AnythingOfType("order.Order"), &bun.Tx{}).Return(tt.mockCreateErr)
+				os := NewOrderService(
+					&rep, &mocks.AccrualResultRepository{}, &mocks.WebhookRepository{}, &storagemocks.TransactionHelper{},
+					pubsub.NewHub(),
+				)
+				rep.On("GetByNumber", tt.args.ctx, tt.args.number, nil).Return(tt.mockRes, tt.mockGetOrderErr)
+				rep.On("CreateOrder", tt.args.ctx, mock.AnythingOfType("order.Order"), nil).Return(tt.mockCreateErr)
 				err := os.LoadOrderByNumber(tt.args.ctx, tt.args.number, userID)
 				if (err != nil) != tt.wantErr {
 					t.Errorf("LoadOrderByNumber() error = %v, wantErr %v", err, tt.wantErr)
@@ -349,32 +515,35 @@ func TestOrderService_LoadOrderByNumber(t *testing.T) {
 
 func TestOrderService_UpdateOrdersAndBalance(t *testing.T) {
 	type args struct {
-		ctx  context.Context
-		info map[string]clients.OrderLoyaltyInfo
+		ctx   context.Context
+		limit int
 	}
 	orderNumber := goluhn.Generate(10)
 	ctx := context.Background()
 	tests := []struct {
 		name                  string
 		args                  args
-		wantErr               bool
+		wantedProcessed       int
 		wantedErr             []error
+		mockResults           []order.AccrualResult
+		mockResultsErr        error
 		mockGetButchOrders    []order.Order
 		mockGetButchOrdersErr error
+		mockUpdated           []string
 		mockUpdateErr         error
 		orders                []order.Order
-		transactions          []transaction.Transaction
+		wantStatusChanges     []string
+		wantWebhooks          []string
+		wantCommit            bool
 	}{
 		{
 			name: "Test_1. Нормальное создание",
-			args: args{
-				ctx: ctx,
-				info: map[string]clients.OrderLoyaltyInfo{
-					orderNumber: {
-						Order:   orderNumber,
-						Status:  clients.StatusProcessed,
-						Accrual: 100,
-					},
+			args: args{ctx: ctx, limit: 10},
+			mockResults: []order.AccrualResult{
+				{
+					OrderNumber: orderNumber,
+					Status:      clients.StatusProcessed,
+					Accrual:     100,
 				},
 			},
 			mockGetButchOrders: []order.Order{
@@ -389,17 +558,20 @@ func TestOrderService_UpdateOrdersAndBalance(t *testing.T) {
 					Status: order.StatusProcessed,
 				},
 			},
+			mockUpdated:       []string{orderNumber},
+			wantStatusChanges: []string{order.StatusProcessed},
+			wantWebhooks:      []string{webhook.EventOrderProcessed},
+			wantedProcessed:   1,
+			wantCommit:        true,
 		},
 		{
 			name: "Test_2. Невалидный статус",
-			args: args{
-				ctx: ctx,
-				info: map[string]clients.OrderLoyaltyInfo{
-					orderNumber: {
-						Order:   orderNumber,
-						Status:  "Невалидный статус",
-						Accrual: 100,
-					},
+			args: args{ctx: ctx, limit: 10},
+			mockResults: []order.AccrualResult{
+				{
+					OrderNumber: orderNumber,
+					Status:      "Невалидный статус",
+					Accrual:     100,
 				},
 			},
 			mockGetButchOrders: []order.Order{
@@ -414,42 +586,38 @@ func TestOrderService_UpdateOrdersAndBalance(t *testing.T) {
 					Status: order.StatusNew,
 				},
 			},
-			wantErr: true,
+			mockUpdated:     []string{orderNumber},
+			wantedProcessed: 1,
 			wantedErr: []error{
 				order.InvalidStatus{
 					OrderNumber: orderNumber,
 					Status:      "Невалидный статус",
 				},
 			},
+			wantCommit: true,
 		},
 		{
 			name: "Test_3. Ошибка получения заказов",
-			args: args{
-				ctx: ctx,
-				info: map[string]clients.OrderLoyaltyInfo{
-					orderNumber: {
-						Order:   orderNumber,
-						Status:  clients.StatusProcessed,
-						Accrual: 100,
-					},
+			args: args{ctx: ctx, limit: 10},
+			mockResults: []order.AccrualResult{
+				{
+					OrderNumber: orderNumber,
+					Status:      clients.StatusProcessed,
+					Accrual:     100,
 				},
 			},
 			mockGetButchOrders:    []order.Order{},
 			mockGetButchOrdersErr: errors.New("db gone away"),
-			orders:                []order.Order{},
-			wantErr:               true,
 			wantedErr:             []error{errors.New("db gone away")},
 		},
 		{
 			name: "Test_4. Ошибка обновления",
-			args: args{
-				ctx: ctx,
-				info: map[string]clients.OrderLoyaltyInfo{
-					orderNumber: {
-						Order:   orderNumber,
-						Status:  clients.StatusProcessed,
-						Accrual: 100,
-					},
+			args: args{ctx: ctx, limit: 10},
+			mockResults: []order.AccrualResult{
+				{
+					OrderNumber: orderNumber,
+					Status:      clients.StatusProcessed,
+					Accrual:     100,
 				},
 			},
 			mockGetButchOrders: []order.Order{
@@ -465,28 +633,269 @@ func TestOrderService_UpdateOrdersAndBalance(t *testing.T) {
 				},
 			},
 			mockUpdateErr: errors.New("can not update"),
-			wantErr:       true,
 			wantedErr:     []error{errors.New("can not update")},
 		},
+		{
+			name:           "Test_5. Ошибка получения ответов",
+			args:           args{ctx: ctx, limit: 10},
+			mockResultsErr: errors.New("db gone away"),
+			wantedErr:      []error{errors.New("db gone away")},
+		},
+		{
+			name: "Test_6. Нет новых ответов",
+			args: args{ctx: ctx, limit: 10},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				rep := mocks.OrderRepository{}
+				accrualRep := mocks.AccrualResultRepository{}
 				txHelper := storagemocks.TransactionHelper{}
-				os := NewOrderService(&rep, &txHelper)
-				orderNumbers := make([]string, len(tt.args.info))
-				n := 0
-				for _, i := range tt.args.info {
-					orderNumbers[n] = i.Order
-					n++
+				tx := storagemocks.Transaction{}
+				webhooks := mocks.WebhookRepository{}
+				hub := pubsub.NewHub()
+				events, unsubscribe := hub.Subscribe(uuid.Nil)
+				defer unsubscribe()
+				os := NewOrderService(&rep, &accrualRep, &webhooks, &txHelper, hub)
+				orderNumbers := make([]string, len(tt.mockResults))
+				for n, r := range tt.mockResults {
+					orderNumbers[n] = r.OrderNumber
 				}
-				rep.On("GetBatchByNumbers", tt.args.ctx, orderNumbers).Return(tt.mockGetButchOrders, tt.mockGetButchOrdersErr)
+				txHelper.On("StartTransaction", tt.args.ctx).Return(&tx, nil)
+				tx.On("GetTransaction").Return(&bun.Tx{})
+				tx.On("Rollback").Return(nil)
+				tx.On("Commit").Return(nil)
+				accrualRep.On("ClaimPending", tt.args.ctx, tt.args.limit, &bun.Tx{}).Return(tt.mockResults, tt.mockResultsErr)
+				accrualRep.On("MarkProcessed", tt.args.ctx, orderNumbers, &bun.Tx{}).Return(nil)
+				rep.On("GetBatchByNumbers", tt.args.ctx, orderNumbers, &bun.Tx{}).
+					Return(tt.mockGetButchOrders, tt.mockGetButchOrdersErr)
 				rep.On(
 					"BatchUpdateOrdersAndBalance", tt.args.ctx, tt.orders, mock.AnythingOfType("[]transaction.Transaction"),
-				).Return(tt.mockUpdateErr)
-				got := os.UpdateOrdersAndBalance(tt.args.ctx, tt.args.info)
-				require.Equal(t, got, tt.wantedErr)
+					&bun.Tx{},
+				).Return(tt.mockUpdated, tt.mockUpdateErr)
+				var gotWebhooks []string
+				webhooks.On("Enqueue", tt.args.ctx, mock.AnythingOfType("webhook.Event"), &bun.Tx{}).
+					Run(
+						func(args mock.Arguments) {
+							gotWebhooks = append(gotWebhooks, args.Get(1).(webhook.Event).Type)
+						},
+					).Return(nil)
+				var gotStatusChanges []string
+				rep.On("AddStatusChanges", tt.args.ctx, mock.AnythingOfType("[]order.StatusChange"), &bun.Tx{}).
+					Run(
+						func(args mock.Arguments) {
+							for _, c := range args.Get(1).([]order.StatusChange) {
+								gotStatusChanges = append(gotStatusChanges, c.Status)
+							}
+						},
+					).Return(nil)
+				processed, got := os.UpdateOrdersAndBalance(tt.args.ctx, tt.args.limit)
+				require.Equal(t, tt.wantedErr, got)
+				require.Equal(t, tt.wantedProcessed, processed)
+				require.Equal(t, tt.wantStatusChanges, gotStatusChanges)
+				require.Len(t, events, len(tt.wantStatusChanges), "subscribers must get every committed change")
+				require.Equal(t, tt.wantWebhooks, gotWebhooks)
+				if tt.wantCommit {
+					accrualRep.AssertCalled(t, "MarkProcessed", tt.args.ctx, orderNumbers, &bun.Tx{})
+					tx.AssertCalled(t, "Commit")
+				} else {
+					tx.AssertNotCalled(t, "Commit")
+				}
+			},
+		)
+	}
+}
+
+func TestOrderService_RequeueOrder(t *testing.T) {
+	ctx := context.Background()
+	orderNumber := goluhn.Generate(10)
+	orderID, _ := uuid.NewV7()
+	tests := []struct {
+		name        string
+		mockOrder   order.Order
+		mockErr     error
+		wantErr     error
+		wantRequeue bool
+	}{
+		{
+			name:        "Test_1. Заказ ставится в очередь",
+			mockOrder:   order.Order{ID: orderID, Number: orderNumber, Status: order.StatusProcessing},
+			wantRequeue: true,
+		},
+		{
+			name:      "Test_2. Заказ с конечным статусом",
+			mockOrder: order.Order{ID: orderID, Number: orderNumber, Status: order.StatusProcessed},
+			wantErr:   &order.AlreadyFinal{OrderNumber: orderNumber, Status: order.StatusProcessed},
+		},
+		{
+			name:    "Test_3. Заказ не найден",
+			mockErr: repository.NoResultError{},
+			wantErr: &order.NoSuchOrder{OrderNumber: orderNumber},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.OrderRepository{}
+				os := NewOrderService(
+					&rep, &mocks.AccrualResultRepository{}, &mocks.WebhookRepository{}, &storagemocks.TransactionHelper{},
+					pubsub.NewHub(),
+				)
+				rep.On("GetByNumber", ctx, orderNumber, nil).Return(tt.mockOrder, tt.mockErr)
+				rep.On("RequeueForPolling", ctx, orderID, mock.AnythingOfType("time.Time")).Return(nil)
+				err := os.RequeueOrder(ctx, orderNumber)
+				require.Equal(t, tt.wantErr, err)
+				if tt.wantRequeue {
+					rep.AssertCalled(t, "RequeueForPolling", ctx, orderID, mock.AnythingOfType("time.Time"))
+				} else {
+					rep.AssertNotCalled(t, "RequeueForPolling", ctx, orderID, mock.AnythingOfType("time.Time"))
+				}
+			},
+		)
+	}
+}
+
+func TestOrderService_GetUserOrder(t *testing.T) {
+	ctx := context.Background()
+	orderNumber := goluhn.Generate(10)
+	userID, _ := uuid.NewV7()
+	orderID, _ := uuid.NewV7()
+	uploadedAt := time.Now().Add(-time.Hour)
+	processedAt := time.Now()
+	info := service.OrderInfo{
+		ID: orderID, Number: orderNumber, Status: order.StatusProcessed, Accrual: 500, UploadedAt: uploadedAt,
+	}
+	changes := []order.StatusChange{
+		{OrderID: orderID, Status: order.StatusProcessing, ChangedAt: uploadedAt.Add(time.Minute)},
+		{OrderID: orderID, Status: order.StatusProcessed, ChangedAt: processedAt},
+	}
+	tests := []struct {
+		name        string
+		mockInfo    service.OrderInfo
+		mockErr     error
+		mockHistory []order.StatusChange
+		want        service.OrderDetails
+		wantErr     error
+	}{
+		{
+			name:        "Test_1. Заказ с историей статусов",
+			mockInfo:    info,
+			mockHistory: changes,
+			want: service.OrderDetails{
+				OrderInfo: info,
+				History: []order.StatusChange{
+					{OrderID: orderID, Status: order.StatusNew, ChangedAt: uploadedAt},
+					changes[0],
+					changes[1],
+				},
+			},
+		},
+		{
+			name:     "Test_2. Заказ ещё не опрошен",
+			mockInfo: service.OrderInfo{ID: orderID, Number: orderNumber, Status: order.StatusNew, UploadedAt: uploadedAt},
+			want: service.OrderDetails{
+				OrderInfo: service.OrderInfo{ID: orderID, Number: orderNumber, Status: order.StatusNew, UploadedAt: uploadedAt},
+				History:   []order.StatusChange{{OrderID: orderID, Status: order.StatusNew, ChangedAt: uploadedAt}},
+			},
+		},
+		{
+			name:    "Test_3. Чужой или неизвестный заказ",
+			mockErr: repository.NoResultError{},
+			wantErr: &order.NoSuchOrder{OrderNumber: orderNumber},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.OrderRepository{}
+				os := NewOrderService(
+					&rep, &mocks.AccrualResultRepository{}, &mocks.WebhookRepository{}, &storagemocks.TransactionHelper{},
+					pubsub.NewHub(),
+				)
+				rep.On("GetUserOrderInfo", ctx, userID, orderNumber).Return(tt.mockInfo, tt.mockErr)
+				rep.On("GetStatusHistory", ctx, orderID).Return(tt.mockHistory, nil)
+				got, err := os.GetUserOrder(ctx, userID, orderNumber)
+				require.Equal(t, tt.wantErr, err)
+				require.Equal(t, tt.want, got)
+			},
+		)
+	}
+}
+
+func TestOrderService_LoadOrders(t *testing.T) {
+	ctx := context.Background()
+	userID, _ := uuid.NewV7()
+	otherUserID, _ := uuid.NewV7()
+	newNumber := goluhn.Generate(10)
+	ownNumber := goluhn.Generate(10)
+	foreignNumber := goluhn.Generate(10)
+	tests := []struct {
+		name         string
+		numbers      []string
+		mockCreated  []string
+		mockExisting []order.Order
+		mockErr      error
+		want         []order.LoadResult
+		wantErr      error
+		wantCommit   bool
+	}{
+		{
+			name:        "Test_1. Все результаты загрузки",
+			numbers:     []string{newNumber, "1245", ownNumber, foreignNumber, newNumber},
+			mockCreated: []string{newNumber},
+			mockExisting: []order.Order{
+				{Number: ownNumber, UserID: userID},
+				{Number: foreignNumber, UserID: otherUserID},
+			},
+			want: []order.LoadResult{
+				{Number: newNumber, Result: order.LoadAccepted},
+				{Number: "1245", Result: order.LoadInvalidFormat},
+				{Number: ownNumber, Result: order.LoadAlreadyUploaded},
+				{Number: foreignNumber, Result: order.LoadUploadedByOthers},
+			},
+			wantCommit: true,
+		},
+		{
+			name:    "Test_2. Только невалидные номера",
+			numbers: []string{"1245"},
+			want:    []order.LoadResult{{Number: "1245", Result: order.LoadInvalidFormat}},
+		},
+		{
+			name:    "Test_3. Пустой пакет",
+			wantErr: &order.InvalidBatch{Message: "no order numbers"},
+		},
+		{
+			name:    "Test_4. Ошибка создания",
+			numbers: []string{newNumber},
+			mockErr: errors.New("can not create orders"),
+			wantErr: errors.New("can not create orders"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.OrderRepository{}
+				txHelper := storagemocks.TransactionHelper{}
+				tx := storagemocks.Transaction{}
+				os := NewOrderService(
+					&rep, &mocks.AccrualResultRepository{}, &mocks.WebhookRepository{}, &txHelper, pubsub.NewHub(),
+				)
+				txHelper.On("StartTransaction", ctx).Return(&tx, nil)
+				tx.On("GetTransaction").Return(&bun.Tx{})
+				tx.On("Rollback").Return(nil)
+				tx.On("Commit").Return(nil)
+				rep.On("CreateOrdersIfNotExist", ctx, mock.AnythingOfType("[]order.Order"), &bun.Tx{}).
+					Return(tt.mockCreated, tt.mockErr)
+				rep.On("GetBatchByNumbers", ctx, mock.AnythingOfType("[]string"), &bun.Tx{}).Return(tt.mockExisting, nil)
+				got, err := os.LoadOrders(ctx, tt.numbers, userID)
+				require.Equal(t, tt.wantErr, err)
+				require.Equal(t, tt.want, got)
+				if tt.wantCommit {
+					tx.AssertCalled(t, "Commit")
+				} else {
+					tx.AssertNotCalled(t, "Commit")
+				}
 			},
 		)
 	}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/session"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage"
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+const refreshTokenLength = 32
+
+type SessionService struct {
+	repo       repository.SessionRepository
+	users      repository.UserRepository
+	tokens     *auth.TokenManager
+	txHelper   storage.TransactionHelper
+	refreshTTL time.Duration
+}
+
+func NewSessionService(
+	repo repository.SessionRepository, users repository.UserRepository, tokens *auth.TokenManager,
+	txHelper storage.TransactionHelper, refreshTTL time.Duration,
+) *SessionService {
+	return &SessionService{repo: repo, users: users, tokens: tokens, txHelper: txHelper, refreshTTL: refreshTTL}
+}
+
+// Create открывает новую сессию пользователя и выдаёт для неё пару токенов
+func (ss SessionService) Create(ctx context.Context, userID uuid.UUID) (service.Tokens, error) {
+	s, refreshToken, err := ss.newSession(userID)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+	if err := ss.repo.CreateSession(ctx, s, nil); err != nil {
+		return service.Tokens{}, err
+	}
+
+	return ss.issue(ctx, s, refreshToken)
+}
+
+// ChangePassword в одной транзакции сохраняет новый хеш пароля, отзывает все сессии пользователя
+// и открывает новую, поэтому украденные сессии не переживут смену пароля. Текущий пароль проверяет
+// вызывающая сторона.
+func (ss SessionService) ChangePassword(
+	ctx context.Context, userID uuid.UUID, passwordHash string,
+) (service.Tokens, error) {
+	s, refreshToken, err := ss.newSession(userID)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+	tx, err := ss.txHelper.StartTransaction(ctx)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+	if err := ss.users.UpdatePassword(ctx, userID, passwordHash, tx.GetTransaction()); err != nil {
+		return service.Tokens{}, rollback(tx, err)
+	}
+	if err := ss.repo.RevokeUserSessions(ctx, userID, s.CreatedAt, tx.GetTransaction()); err != nil {
+		return service.Tokens{}, rollback(tx, err)
+	}
+	if err := ss.repo.CreateSession(ctx, s, tx.GetTransaction()); err != nil {
+		return service.Tokens{}, rollback(tx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return service.Tokens{}, err
+	}
+
+	return ss.issue(ctx, s, refreshToken)
+}
+
+// ChangeRole в одной транзакции меняет роль пользователя и отзывает все его сессии, поэтому
+// действующие токены с прежней ролью не переживут смену роли, а при ошибке роль не меняется
+func (ss SessionService) ChangeRole(ctx context.Context, userID uuid.UUID, role user.Role) error {
+	tx, err := ss.txHelper.StartTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	if err := ss.users.UpdateRole(ctx, userID, role, tx.GetTransaction()); err != nil {
+		return rollback(tx, err)
+	}
+	if err := ss.repo.RevokeUserSessions(ctx, userID, time.Now(), tx.GetTransaction()); err != nil {
+		return rollback(tx, err)
+	}
+
+	return tx.Commit()
+}
+
+// Refresh заменяет refresh токен новым и выдаёт новый токен доступа. Предъявление уже заменённого
+// refresh токена означает, что он украден, поэтому сессия отзывается.
+func (ss SessionService) Refresh(ctx context.Context, refreshToken string) (service.Tokens, error) {
+	hash := hashRefreshToken(refreshToken)
+	tx, err := ss.txHelper.StartTransaction(ctx)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+	s, err := ss.repo.GetSessionByRefreshHash(ctx, hash, tx.GetTransaction())
+	if err != nil {
+		if errors.Is(err, repository.NoResultError{}) {
+			return service.Tokens{}, rollback(tx, session.InvalidRefreshToken{})
+		}
+		return service.Tokens{}, rollback(tx, err)
+	}
+	now := time.Now()
+	if !s.IsActive(now) {
+		return service.Tokens{}, rollback(tx, session.InvalidRefreshToken{})
+	}
+	if s.RefreshTokenHash != hash {
+		s.RevokedAt = now
+		if err := ss.repo.UpdateSession(ctx, s, tx.GetTransaction()); err != nil {
+			return service.Tokens{}, rollback(tx, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return service.Tokens{}, err
+		}
+		return service.Tokens{}, session.RefreshTokenReused{SessionID: s.ID.String()}
+	}
+
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return service.Tokens{}, rollback(tx, err)
+	}
+	s.PreviousRefreshTokenHash = s.RefreshTokenHash
+	s.RefreshTokenHash = newHash
+	s.LastUsedAt = now
+	s.ExpiresAt = now.Add(ss.refreshTTL)
+	if err := ss.repo.UpdateSession(ctx, s, tx.GetTransaction()); err != nil {
+		return service.Tokens{}, rollback(tx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return service.Tokens{}, err
+	}
+
+	return ss.issue(ctx, s, newToken)
+}
+
+func (ss SessionService) Logout(ctx context.Context, sessionID uuid.UUID) error {
+	return ss.repo.RevokeSession(ctx, sessionID, time.Now())
+}
+
+func (ss SessionService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	return ss.repo.RevokeUserSessions(ctx, userID, time.Now(), nil)
+}
+
+func (ss SessionService) IsSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	s, err := ss.repo.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.NoResultError{}) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return s.IsActive(time.Now()), nil
+}
+
+// issue выпускает токен доступа с текущей ролью пользователя, поэтому смена роли
+// вступает в силу при следующем обновлении токена
+func (ss SessionService) issue(ctx context.Context, s session.Session, refreshToken string) (service.Tokens, error) {
+	u, err := ss.users.GetByID(ctx, s.UserID)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+	accessToken, err := ss.tokens.GenerateJWT(s.UserID, s.ID, u.Role)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+
+	return service.Tokens{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  time.Now().Add(ss.tokens.TTL()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: s.ExpiresAt,
+	}, nil
+}
+
+func (ss SessionService) newSession(userID uuid.UUID) (session.Session, string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return session.Session{}, "", err
+	}
+	refreshToken, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return session.Session{}, "", err
+	}
+	now := time.Now()
+
+	return session.Session{
+		ID:               id,
+		UserID:           userID,
+		RefreshTokenHash: refreshHash,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(ss.refreshTTL),
+	}, refreshToken, nil
+}
+
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, refreshTokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
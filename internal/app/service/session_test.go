@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/session"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository/mocks"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	storagemocks "github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/mocks"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"testing"
+	"time"
+)
+
+func newTestTokenManager(t *testing.T) *auth.TokenManager {
+	t.Helper()
+	keys, err := auth.NewRandomKeySet()
+	require.NoError(t, err)
+	tm, err := auth.NewTokenManager(keys, "gophermart", time.Minute)
+	require.NoError(t, err)
+	return tm
+}
+
+func TestSessionService_Refresh(t *testing.T) {
+	ctx := context.Background()
+	sessionID, _ := uuid.NewV7()
+	userID, _ := uuid.NewV7()
+	refreshToken := "refresh-token"
+	refreshHash := hashRefreshToken(refreshToken)
+	active := session.Session{
+		ID:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: refreshHash,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+	rotated := active
+	rotated.RefreshTokenHash = hashRefreshToken("new-refresh-token")
+	rotated.PreviousRefreshTokenHash = refreshHash
+	expired := active
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	revoked := active
+	revoked.RevokedAt = time.Now()
+	tests := []struct {
+		name        string
+		mockSession session.Session
+		mockErr     error
+		wantErr     error
+		wantUpdate  bool
+		wantRevoked bool
+	}{
+		{
+			name:        "Test_1. Токен заменяется новым",
+			mockSession: active,
+			wantUpdate:  true,
+		},
+		{
+			name:        "Test_2. Повторное использование заменённого токена отзывает сессию",
+			mockSession: rotated,
+			wantErr:     session.RefreshTokenReused{SessionID: sessionID.String()},
+			wantUpdate:  true,
+			wantRevoked: true,
+		},
+		{
+			name:    "Test_3. Неизвестный токен",
+			mockErr: repository.NoResultError{},
+			wantErr: session.InvalidRefreshToken{},
+		},
+		{
+			name:        "Test_4. Истёкшая сессия",
+			mockSession: expired,
+			wantErr:     session.InvalidRefreshToken{},
+		},
+		{
+			name:        "Test_5. Отозванная сессия",
+			mockSession: revoked,
+			wantErr:     session.InvalidRefreshToken{},
+		},
+		{
+			name:    "Test_6. Ошибка репозитория",
+			mockErr: errors.New("db gone away"),
+			wantErr: errors.New("db gone away"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.SessionRepository{}
+				users := mocks.UserRepository{}
+				txHelper := storagemocks.TransactionHelper{}
+				tx := storagemocks.Transaction{}
+				tm := newTestTokenManager(t)
+				ss := NewSessionService(&rep, &users, tm, &txHelper, time.Hour)
+				users.On("GetByID", ctx, userID).Return(user.User{ID: userID, Role: user.RoleSupport}, nil)
+				txHelper.On("StartTransaction", ctx).Return(&tx, nil)
+				tx.On("GetTransaction").Return(&bun.Tx{})
+				tx.On("Rollback").Return(nil)
+				tx.On("Commit").Return(nil)
+				rep.On("GetSessionByRefreshHash", ctx, refreshHash, &bun.Tx{}).Return(tt.mockSession, tt.mockErr)
+				rep.On("UpdateSession", ctx, mock.AnythingOfType("session.Session"), &bun.Tx{}).Return(nil)
+
+				tokens, err := ss.Refresh(ctx, refreshToken)
+				require.Equal(t, tt.wantErr, err)
+				if !tt.wantUpdate {
+					rep.AssertNotCalled(t, "UpdateSession", ctx, mock.AnythingOfType("session.Session"), &bun.Tx{})
+					return
+				}
+				updated := rep.Calls[len(rep.Calls)-1].Arguments.Get(1).(session.Session)
+				if tt.wantRevoked {
+					require.False(t, updated.RevokedAt.IsZero())
+					require.Empty(t, tokens.AccessToken)
+					return
+				}
+				require.NotEqual(t, refreshToken, tokens.RefreshToken)
+				require.Equal(t, hashRefreshToken(tokens.RefreshToken), updated.RefreshTokenHash)
+				require.Equal(t, refreshHash, updated.PreviousRefreshTokenHash)
+				claims, err := tm.ParseJWT(tokens.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.RoleSupport, claims.Role)
+			},
+		)
+	}
+}
+
+func TestSessionService_IsSessionActive(t *testing.T) {
+	ctx := context.Background()
+	sessionID, _ := uuid.NewV7()
+	tests := []struct {
+		name        string
+		mockSession session.Session
+		mockErr     error
+		want        bool
+		wantErr     bool
+	}{
+		{
+			name:        "Test_1. Действующая сессия",
+			mockSession: session.Session{ID: sessionID, ExpiresAt: time.Now().Add(time.Hour)},
+			want:        true,
+		},
+		{
+			name:        "Test_2. Отозванная сессия",
+			mockSession: session.Session{ID: sessionID, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: time.Now()},
+		},
+		{
+			name:    "Test_3. Сессия не найдена",
+			mockErr: repository.NoResultError{},
+		},
+		{
+			name:    "Test_4. Ошибка репозитория",
+			mockErr: errors.New("db gone away"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.SessionRepository{}
+				ss := NewSessionService(
+					&rep, &mocks.UserRepository{}, newTestTokenManager(t), &storagemocks.TransactionHelper{}, time.Hour,
+				)
+				rep.On("GetSession", ctx, sessionID).Return(tt.mockSession, tt.mockErr)
+				got, err := ss.IsSessionActive(ctx, sessionID)
+				require.Equal(t, tt.wantErr, err != nil)
+				require.Equal(t, tt.want, got)
+			},
+		)
+	}
+}
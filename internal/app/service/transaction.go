@@ -0,0 +1,11 @@
+package service
+
+import "github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage"
+
+// rollback откатывает транзакцию и возвращает исходную ошибку, если откат прошёл успешно
+func rollback(tx storage.Transaction, err error) error {
+	if rbErr := tx.Rollback(); rbErr != nil {
+		return rbErr
+	}
+	return err
+}
@@ -5,23 +5,53 @@ import (
 	"errors"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/service"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
 	"github.com/gofrs/uuid"
+	"time"
+)
+
+const (
+	// Первые неудачные попытки не ограничиваются, после них каждая следующая удваивает блокировку
+	loginFreeAttempts = 5
+	ipFreeAttempts    = 20
+	loginLockoutBase  = time.Second
+	loginLockoutMax   = 15 * time.Minute
+	// loginAttemptsTTL счётчик сбрасывается, если неудачных попыток не было столько времени
+	loginAttemptsTTL = time.Hour
+	// loginFailuresRetention столько хранится журнал неудачных попыток входа
+	loginFailuresRetention = 30 * 24 * time.Hour
+)
+
+var (
+	loginLockout = user.Lockout{FreeAttempts: loginFreeAttempts, Base: loginLockoutBase, Max: loginLockoutMax}
+	ipLockout    = user.Lockout{FreeAttempts: ipFreeAttempts, Base: loginLockoutBase, Max: loginLockoutMax}
 )
 
 type UserService struct {
-	repo repository.UserRepository
+	repo      repository.UserRepository
+	attempts  repository.LoginAttemptStore
+	audit     repository.LoginAuditRepository
+	sessions  service.SessionService
+	policy    user.Policy
+	passwords *auth.Passwords
 }
 
-func NewUserService(repo repository.UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(
+	repo repository.UserRepository, attempts repository.LoginAttemptStore, audit repository.LoginAuditRepository,
+	sessions service.SessionService, policy user.Policy, passwords *auth.Passwords,
+) *UserService {
+	return &UserService{
+		repo: repo, attempts: attempts, audit: audit, sessions: sessions, policy: policy, passwords: passwords,
+	}
 }
 
 func (us UserService) Register(ctx context.Context, login, password string) (user.User, error) {
-	if _, err := us.repo.GetByLogin(ctx, login); err == nil {
-		return user.User{}, &user.LoginAlreadyExists{Login: login}
+	violations := append(us.policy.ValidateLogin(login), us.policy.ValidatePassword(login, password)...)
+	if len(violations) > 0 {
+		return user.User{}, &user.InvalidCredentials{Violations: violations}
 	}
-	passwordHash, err := auth.MakePasswordHash(password)
+	passwordHash, err := us.passwords.Hash(password)
 	if err != nil {
 		return user.User{}, err
 	}
@@ -30,33 +60,206 @@ func (us UserService) Register(ctx context.Context, login, password string) (use
 		return user.User{}, err
 	}
 
-	return us.repo.CreateUser(
+	// Занятость логина проверяет уникальный индекс, поэтому параллельные регистрации не создадут двух пользователей
+	u, err := us.repo.CreateUser(
 		ctx, user.User{
 			ID:       id,
 			Login:    login,
 			Password: passwordHash,
+			Role:     user.RoleUser,
 		},
 	)
+	if repository.ViolatesUnique(err, repository.ConstraintUserLogin) {
+		return user.User{}, &user.LoginAlreadyExists{Login: login}
+	}
+
+	return u, err
 }
 
-func (us UserService) Login(ctx context.Context, login, password string) (user.User, error) {
+// Login проверяет пароль, если ни логин, ни IP адрес не заблокированы за перебор паролей.
+// Попытка засчитывается неудачной до проверки пароля, поэтому параллельные попытки не обходят блокировку,
+// а заблокированные отклоняются, не нагружая процессор и не попадая в журнал, чтобы заблокированный клиент
+// не мог бесконечно его наполнять.
+func (us UserService) Login(ctx context.Context, login, password, ip string) (user.User, error) {
+	now := time.Now()
+	retryAfter, err := us.acquireAttempt(ctx, now, login, ip)
+	if err != nil {
+		return user.User{}, err
+	}
+	if retryAfter > 0 {
+		return user.User{}, &user.LoginLocked{Login: login, RetryAfter: retryAfter}
+	}
+
 	u, err := us.repo.GetByLogin(ctx, login)
 	if err != nil {
 		if errors.Is(err, repository.NoResultError{}) {
+			if err := us.recordFailure(ctx, now, login, ip, user.LoginFailureUnknownLogin); err != nil {
+				return user.User{}, err
+			}
 			return user.User{}, &user.IncorrectLoginOrPassword{
 				Login:    login,
 				Password: password,
 			}
 		}
+		us.releaseAttempt(ctx, login, ip)
 		return user.User{}, err
 	}
 
-	if !auth.CheckPassword(password, u.Password) {
+	if !us.passwords.Verify(password, u.Password) {
+		if err := us.recordFailure(ctx, now, login, ip, user.LoginFailureWrongPassword); err != nil {
+			return user.User{}, err
+		}
 		return user.User{}, &user.IncorrectLoginOrPassword{
 			Login:    login,
 			Password: password,
 		}
 	}
 
+	// С IP снимается только эта попытка, иначе вход в свой аккаунт позволял бы продолжать перебор чужих
+	if err := us.attempts.ResetAttempts(ctx, loginAttemptsKey(login)); err != nil {
+		return user.User{}, err
+	}
+	if err := us.attempts.ReleaseAttempt(ctx, ipAttemptsKey(ip)); err != nil {
+		return user.User{}, err
+	}
+	// Пароль известен только в момент входа, поэтому хеш с устаревшим алгоритмом или параметрами
+	// обновляется здесь. Ошибка обновления не мешает входу: хеш обновится при следующем входе.
+	if us.passwords.NeedsRehash(u.Password) {
+		if passwordHash, err := us.passwords.Hash(password); err == nil {
+			if err := us.repo.UpdatePassword(ctx, u.ID, passwordHash, nil); err == nil {
+				u.Password = passwordHash
+			}
+		}
+	}
+
 	return u, nil
 }
+
+// ChangePassword меняет пароль после проверки текущего, отзывает все сессии пользователя
+// и открывает новую для текущего клиента
+func (us UserService) ChangePassword(
+	ctx context.Context, userID uuid.UUID, oldPassword, newPassword string,
+) (service.Tokens, error) {
+	u, err := us.repo.GetByID(ctx, userID)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+	if !us.passwords.Verify(oldPassword, u.Password) {
+		return service.Tokens{}, &user.WrongPassword{}
+	}
+	violations := us.policy.ValidatePassword(u.Login, newPassword)
+	if oldPassword == newPassword {
+		violations = append(
+			violations, user.Violation{
+				Field: "new_password", Code: user.ViolationSameAsPrevious, Message: "new password must differ from the current one",
+			},
+		)
+	}
+	if len(violations) > 0 {
+		for i := range violations {
+			if violations[i].Field == "password" {
+				violations[i].Field = "new_password"
+			}
+		}
+		return service.Tokens{}, &user.InvalidCredentials{Violations: violations}
+	}
+	passwordHash, err := us.passwords.Hash(newPassword)
+	if err != nil {
+		return service.Tokens{}, err
+	}
+
+	return us.sessions.ChangePassword(ctx, userID, passwordHash)
+}
+
+func (us UserService) GetUser(ctx context.Context, userID uuid.UUID) (user.User, error) {
+	u, err := us.repo.GetByID(ctx, userID)
+	if errors.Is(err, repository.NoResultError{}) {
+		return user.User{}, &user.NoSuchUser{ID: userID.String()}
+	}
+
+	return u, err
+}
+
+func (us UserService) FindByLogin(ctx context.Context, login string) (user.User, error) {
+	u, err := us.repo.GetByLogin(ctx, login)
+	if errors.Is(err, repository.NoResultError{}) {
+		return user.User{}, &user.NoSuchUser{Login: login}
+	}
+
+	return u, err
+}
+
+// SetRole меняет роль пользователя. Роль попадает в токены доступа при их выпуске,
+// поэтому вместе с ней отзываются все сессии пользователя.
+func (us UserService) SetRole(ctx context.Context, userID uuid.UUID, role user.Role) error {
+	if !role.IsValid() {
+		return &user.InvalidRole{Role: role}
+	}
+	err := us.sessions.ChangeRole(ctx, userID, role)
+	if errors.Is(err, repository.NoResultError{}) {
+		return &user.NoSuchUser{ID: userID.String()}
+	}
+
+	return err
+}
+
+// CleanupLoginHistory удаляет истёкшие счётчики попыток входа и записи журнала неудачных попыток
+// старше loginFailuresRetention
+func (us UserService) CleanupLoginHistory(ctx context.Context) error {
+	now := time.Now()
+	if err := us.attempts.DeleteExpired(ctx, now); err != nil {
+		return err
+	}
+
+	return us.audit.DeleteFailuresBefore(ctx, now.Add(-loginFailuresRetention))
+}
+
+// acquireAttempt резервирует попытку входа по логину и по IP адресу. Если один из них заблокирован,
+// резерв снимается и возвращается оставшееся время блокировки.
+func (us UserService) acquireAttempt(ctx context.Context, now time.Time, login, ip string) (time.Duration, error) {
+	retryAfter, err := us.attempts.TryAcquire(ctx, loginAttemptsKey(login), loginLockout, now, loginAttemptsTTL)
+	if err != nil || retryAfter > 0 {
+		return retryAfter, err
+	}
+	retryAfter, err = us.attempts.TryAcquire(ctx, ipAttemptsKey(ip), ipLockout, now, loginAttemptsTTL)
+	if err != nil || retryAfter > 0 {
+		if releaseErr := us.attempts.ReleaseAttempt(ctx, loginAttemptsKey(login)); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+		return retryAfter, err
+	}
+
+	return 0, nil
+}
+
+// releaseAttempt снимает резерв попытки, которая не дошла до проверки пароля из-за внутренней ошибки
+func (us UserService) releaseAttempt(ctx context.Context, login, ip string) {
+	_ = us.attempts.ReleaseAttempt(ctx, loginAttemptsKey(login))
+	_ = us.attempts.ReleaseAttempt(ctx, ipAttemptsKey(ip))
+}
+
+// recordFailure записывает неудачную попытку в журнал. Счётчики попыток увеличивает acquireAttempt.
+func (us UserService) recordFailure(ctx context.Context, now time.Time, login, ip, reason string) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+
+	return us.audit.RecordFailure(
+		ctx, user.LoginFailure{
+			ID:          id,
+			Login:       login,
+			IP:          ip,
+			Reason:      reason,
+			AttemptedAt: now,
+		},
+	)
+}
+
+func loginAttemptsKey(login string) string {
+	return "login:" + login
+}
+
+func ipAttemptsKey(ip string) string {
+	return "ip:" + ip
+}
@@ -3,19 +3,38 @@ package service
 import (
 	"context"
 	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/adapter/repository/memory"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/user"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository/mocks"
 	"github.com/ZhuzhomaAL/GopherMart/internal/app/infra/auth"
+	storagemocks "github.com/ZhuzhomaAL/GopherMart/internal/app/infra/storage/mocks"
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
 	"math/rand"
+	"regexp"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+var testPolicy = user.Policy{
+	LoginMinLength:    3,
+	LoginMaxLength:    64,
+	LoginPattern:      regexp.MustCompile(`^[a-zA-Z0-9._@-]+$`),
+	PasswordMinLength: 8,
+	PasswordMaxLength: 72,
+	Blocklist:         map[string]struct{}{"password123": {}},
+}
+
+// testPasswords минимальная стоимость bcrypt ускоряет тесты
+var testPasswords = auth.NewPasswords(auth.BcryptHasher{Cost: bcrypt.MinCost})
+
 func generateLogin() string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	var seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -31,19 +50,27 @@ func TestUserService_Login(t *testing.T) {
 	ID, _ := uuid.NewV7()
 	login := generateLogin()
 	password := strconv.Itoa(rand.New(rand.NewSource(10)).Int())
-	passwordHash, _ := auth.MakePasswordHash(password)
+	passwordHash, _ := testPasswords.Hash(password)
+	outdatedHash, _ := auth.BcryptHasher{Cost: bcrypt.MinCost + 1}.Hash(password)
+	ip := "192.0.2.1"
 	type args struct {
 		ctx      context.Context
 		login    string
 		password string
 	}
 	tests := []struct {
-		name    string
-		args    args
-		mockRes user.User
-		wantErr bool
-		mockErr error
-		expErr  error
+		name            string
+		args            args
+		mockRes         user.User
+		wantErr         bool
+		mockErr         error
+		expErr          error
+		mockLoginLocked time.Duration
+		mockIPLocked    time.Duration
+		wantLocked      bool
+		wantReleased    bool
+		wantFailure     string
+		wantRehash      bool
 	}{
 		{
 			name: "Test_1.Проверка успешной авторизации",
@@ -78,6 +105,7 @@ func TestUserService_Login(t *testing.T) {
 				Login:    login,
 				Password: password,
 			},
+			wantFailure: user.LoginFailureUnknownLogin,
 		},
 		{
 			name: "Test_3.Метод возвращает ошибку.Сравнение пароля и хеша",
@@ -97,48 +125,174 @@ func TestUserService_Login(t *testing.T) {
 				Login:    login,
 				Password: password,
 			},
+			wantFailure: user.LoginFailureWrongPassword,
+		},
+		{
+			name: "Test_4.Метод возвращает ошибку.Логин заблокирован после неудачных попыток",
+			args: args{
+				ctx:      ctx,
+				login:    login,
+				password: password,
+			},
+			wantErr:         true,
+			mockLoginLocked: time.Minute,
+			wantLocked:      true,
+		},
+		{
+			name: "Test_5.Метод возвращает ошибку.IP заблокирован после неудачных попыток",
+			args: args{
+				ctx:      ctx,
+				login:    login,
+				password: password,
+			},
+			wantErr:      true,
+			mockIPLocked: time.Minute,
+			wantLocked:   true,
+			wantReleased: true,
+		},
+		{
+			name: "Test_6.Метод возвращает ошибку.Ошибка базы данных не засчитывается неудачной попыткой",
+			args: args{
+				ctx:      ctx,
+				login:    login,
+				password: password,
+			},
+			wantErr:      true,
+			mockErr:      errors.New("connection refused"),
+			expErr:       errors.New("connection refused"),
+			wantReleased: true,
+		},
+		{
+			name: "Test_7.Проверка успешной авторизации с перехешированием устаревшего хеша",
+			args: args{
+				ctx:      ctx,
+				login:    login,
+				password: password,
+			},
+			mockRes: user.User{
+				ID:       ID,
+				Login:    login,
+				Password: outdatedHash,
+			},
+			wantRehash: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				rep := mocks.UserRepository{}
-				us := NewUserService(&rep)
+				attempts := mocks.LoginAttemptStore{}
+				audit := mocks.LoginAuditRepository{}
+				us := NewUserService(&rep, &attempts, &audit, nil, user.Policy{}, testPasswords)
 				rep.On("GetByLogin", tt.args.ctx, tt.args.login).Return(tt.mockRes, tt.mockErr)
-				loginData, err := us.Login(tt.args.ctx, tt.args.login, tt.args.password)
+				attempts.On("TryAcquire", tt.args.ctx, "login:"+tt.args.login, loginLockout, mock.Anything, loginAttemptsTTL).
+					Return(tt.mockLoginLocked, nil)
+				attempts.On("TryAcquire", tt.args.ctx, "ip:"+ip, ipLockout, mock.Anything, loginAttemptsTTL).
+					Return(tt.mockIPLocked, nil)
+				attempts.On("ReleaseAttempt", tt.args.ctx, mock.AnythingOfType("string")).Return(nil)
+				attempts.On("ResetAttempts", tt.args.ctx, "login:"+tt.args.login).Return(nil)
+				rep.On("UpdatePassword", tt.args.ctx, ID, mock.AnythingOfType("string"), nil).Return(nil)
+				audit.On("RecordFailure", tt.args.ctx, mock.AnythingOfType("user.LoginFailure")).Return(nil)
+				loginData, err := us.Login(tt.args.ctx, tt.args.login, tt.args.password, ip)
 				if (err != nil) != tt.wantErr {
 					t.Errorf("Login() error = %v, wantErr %v", err, tt.wantErr)
 					return
 				}
-				if !tt.wantErr {
+				if tt.wantRehash {
+					newHash := rep.Calls[len(rep.Calls)-1].Arguments.String(2)
+					require.False(t, testPasswords.NeedsRehash(newHash))
+					require.True(t, testPasswords.Verify(tt.args.password, newHash))
+					require.Equal(t, newHash, loginData.Password)
+				} else if !tt.wantErr {
 					require.Equal(t, tt.mockRes, loginData)
+					rep.AssertNotCalled(t, "UpdatePassword", tt.args.ctx, ID, mock.AnythingOfType("string"), nil)
 				}
-				if tt.wantErr {
+				if !tt.wantErr {
+					attempts.AssertCalled(t, "ResetAttempts", tt.args.ctx, "login:"+tt.args.login)
+					attempts.AssertCalled(t, "ReleaseAttempt", tt.args.ctx, "ip:"+ip)
+				} else if tt.wantReleased {
+					attempts.AssertCalled(t, "ReleaseAttempt", tt.args.ctx, "login:"+tt.args.login)
+				} else {
+					attempts.AssertNotCalled(t, "ReleaseAttempt", tt.args.ctx, mock.AnythingOfType("string"))
+				}
+				if tt.wantLocked {
+					locked, ok := err.(*user.LoginLocked)
+					require.True(t, ok)
+					require.Positive(t, locked.RetryAfter)
+					rep.AssertNotCalled(t, "GetByLogin", tt.args.ctx, tt.args.login)
+				} else if tt.wantErr {
 					require.Equal(t, tt.expErr, err)
+					attempts.AssertNumberOfCalls(t, "TryAcquire", 2)
+				}
+				if tt.wantFailure != "" {
+					failure := audit.Calls[0].Arguments.Get(1).(user.LoginFailure)
+					require.Equal(t, tt.wantFailure, failure.Reason)
+					require.Equal(t, ip, failure.IP)
+				} else {
+					audit.AssertNotCalled(t, "RecordFailure", tt.args.ctx, mock.AnythingOfType("user.LoginFailure"))
 				}
 			},
 		)
 	}
 }
 
+func TestUserService_LoginConcurrent(t *testing.T) {
+	const guesses = 50
+	ctx := context.Background()
+	ID, _ := uuid.NewV7()
+	login := generateLogin()
+	passwordHash, _ := testPasswords.Hash("correct-password")
+	rep := mocks.UserRepository{}
+	audit := mocks.LoginAuditRepository{}
+	us := NewUserService(&rep, memory.NewLoginAttemptStore(), &audit, nil, user.Policy{}, testPasswords)
+	rep.On("GetByLogin", ctx, login).Return(user.User{ID: ID, Login: login, Password: passwordHash}, nil)
+	audit.On("RecordFailure", ctx, mock.AnythingOfType("user.LoginFailure")).Return(nil)
+
+	var incorrect, locked atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := us.Login(ctx, login, "guess-"+strconv.Itoa(i), "192.0.2.1")
+			var errIncorrect *user.IncorrectLoginOrPassword
+			var errLocked *user.LoginLocked
+			switch {
+			case errors.As(err, &errIncorrect):
+				incorrect.Add(1)
+			case errors.As(err, &errLocked):
+				locked.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Пароль проверяется только в разрешённых попытках, остальные отклоняются блокировкой
+	require.EqualValues(t, loginFreeAttempts, incorrect.Load())
+	require.EqualValues(t, guesses-loginFreeAttempts, locked.Load())
+}
+
 func TestUserService_Register(t *testing.T) {
 	ctx := context.Background()
 	ID, _ := uuid.NewV7()
 	login := generateLogin()
 	password := strconv.Itoa(rand.New(rand.NewSource(10)).Int())
-	passwordHash, _ := auth.MakePasswordHash(password)
+	passwordHash, _ := testPasswords.Hash(password)
 	type args struct {
 		ctx      context.Context
 		login    string
 		password string
 	}
 	tests := []struct {
-		name          string
-		args          args
-		mockRes       user.User
-		wantErr       bool
-		mockErr       error
-		mockCreateErr error
+		name           string
+		args           args
+		mockRes        user.User
+		wantErr        bool
+		wantedErr      error
+		mockCreateErr  error
+		wantViolations []user.Violation
 	}{
 		{
 			name: "Test_1.Проверка успешной регистрации",
@@ -153,7 +307,6 @@ func TestUserService_Register(t *testing.T) {
 				Password: passwordHash,
 			},
 			wantErr: false,
-			mockErr: errors.New("no data"),
 		},
 		{
 			name: "Test_2.Метод возвращает ошибку.Логин уже существует",
@@ -167,8 +320,9 @@ func TestUserService_Register(t *testing.T) {
 				Login:    login,
 				Password: passwordHash,
 			},
-			wantErr: true,
-			mockErr: nil,
+			wantErr:       true,
+			mockCreateErr: repository.UniqueViolation{Constraint: repository.ConstraintUserLogin},
+			wantedErr:     &user.LoginAlreadyExists{Login: login},
 		},
 		{
 			name: "Test_3.Метод возвращает ошибку.Не удалось создать пользователя",
@@ -183,16 +337,49 @@ func TestUserService_Register(t *testing.T) {
 				Password: passwordHash,
 			},
 			wantErr:       true,
-			mockErr:       errors.New("no data"),
 			mockCreateErr: errors.New("can't create"),
+			wantedErr:     errors.New("can't create"),
+		},
+		{
+			name: "Test_4.Метод возвращает ошибку.Пустой логин и пароль",
+			args: args{
+				ctx: ctx,
+			},
+			wantErr: true,
+			wantViolations: []user.Violation{
+				{Field: "login", Code: user.ViolationRequired, Message: "login is required"},
+				{Field: "password", Code: user.ViolationRequired, Message: "password is required"},
+			},
+		},
+		{
+			name: "Test_5.Метод возвращает ошибку.Пароль из списка утёкших",
+			args: args{
+				ctx:      ctx,
+				login:    login,
+				password: "Password123",
+			},
+			wantErr: true,
+			wantViolations: []user.Violation{
+				{Field: "password", Code: user.ViolationBlocklisted, Message: "password is known from data breaches"},
+			},
+		},
+		{
+			name: "Test_6.Метод возвращает ошибку.Нарушено другое ограничение уникальности",
+			args: args{
+				ctx:      ctx,
+				login:    login,
+				password: password,
+			},
+			wantErr:       true,
+			mockCreateErr: repository.UniqueViolation{Constraint: "users_pkey"},
+			wantedErr:     repository.UniqueViolation{Constraint: "users_pkey"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
 				rep := mocks.UserRepository{}
-				us := NewUserService(&rep)
-				rep.On("GetByLogin", tt.args.ctx, tt.args.login).Return(tt.mockRes, tt.mockErr)
+				us := NewUserService(&rep, &mocks.LoginAttemptStore{}, &mocks.LoginAuditRepository{}, nil, testPolicy, testPasswords)
 				rep.On("CreateUser", tt.args.ctx, mock.AnythingOfType("user.User")).Return(tt.mockRes, tt.mockCreateErr)
 				userData, err := us.Register(tt.args.ctx, tt.args.login, tt.args.password)
 				if (err != nil) != tt.wantErr {
@@ -202,7 +389,221 @@ func TestUserService_Register(t *testing.T) {
 				if !tt.wantErr {
 					require.Equal(t, tt.mockRes, userData)
 				}
+				if tt.wantedErr != nil {
+					require.Equal(t, tt.wantedErr, err)
+				}
+				if tt.wantViolations != nil {
+					require.Equal(t, &user.InvalidCredentials{Violations: tt.wantViolations}, err)
+					rep.AssertNotCalled(t, "CreateUser", tt.args.ctx, mock.AnythingOfType("user.User"))
+				}
+			},
+		)
+	}
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+	ID, _ := uuid.NewV7()
+	login := generateLogin()
+	password := "old-password"
+	passwordHash, _ := testPasswords.Hash(password)
+	stored := user.User{ID: ID, Login: login, Password: passwordHash}
+	tests := []struct {
+		name          string
+		oldPassword   string
+		newPassword   string
+		mockErr       error
+		mockUpdateErr error
+		wantErr       error
+		wantUpdate    bool
+	}{
+		{
+			name:        "Test_1.Проверка успешной смены пароля",
+			oldPassword: password,
+			newPassword: "new-password",
+			wantUpdate:  true,
+		},
+		{
+			name:        "Test_2.Метод возвращает ошибку.Неверный текущий пароль",
+			oldPassword: "wrong-password",
+			newPassword: "new-password",
+			wantErr:     &user.WrongPassword{},
+		},
+		{
+			name:        "Test_3.Метод возвращает ошибку.Новый пароль совпадает с текущим",
+			oldPassword: password,
+			newPassword: password,
+			wantErr: &user.InvalidCredentials{
+				Violations: []user.Violation{
+					{
+						Field: "new_password", Code: user.ViolationSameAsPrevious,
+						Message: "new password must differ from the current one",
+					},
+				},
+			},
+		},
+		{
+			name:        "Test_4.Метод возвращает ошибку.Короткий новый пароль",
+			oldPassword: password,
+			newPassword: "short",
+			wantErr: &user.InvalidCredentials{
+				Violations: []user.Violation{
+					{
+						Field: "new_password", Code: user.ViolationTooShort,
+						Message: "password must be at least 8 characters long",
+					},
+				},
+			},
+		},
+		{
+			name:        "Test_5.Метод возвращает ошибку.Пользователь не найден",
+			oldPassword: password,
+			newPassword: "new-password",
+			mockErr:     repository.NoResultError{},
+			wantErr:     repository.NoResultError{},
+		},
+		{
+			name:          "Test_6.Метод возвращает ошибку.Ошибка сохранения пароля откатывает отзыв сессий",
+			oldPassword:   password,
+			newPassword:   "new-password",
+			mockUpdateErr: errors.New("connection refused"),
+			wantErr:       errors.New("connection refused"),
+			wantUpdate:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.UserRepository{}
+				sessions := mocks.SessionRepository{}
+				txHelper := storagemocks.TransactionHelper{}
+				tx := storagemocks.Transaction{}
+				ss := NewSessionService(&sessions, &rep, newTestTokenManager(t), &txHelper, time.Hour)
+				us := NewUserService(&rep, &mocks.LoginAttemptStore{}, &mocks.LoginAuditRepository{}, ss, testPolicy, testPasswords)
+				rep.On("GetByID", ctx, ID).Return(stored, tt.mockErr)
+				rep.On("UpdatePassword", ctx, ID, mock.AnythingOfType("string"), &bun.Tx{}).Return(tt.mockUpdateErr)
+				txHelper.On("StartTransaction", ctx).Return(&tx, nil)
+				tx.On("GetTransaction").Return(&bun.Tx{})
+				tx.On("Commit").Return(nil)
+				tx.On("Rollback").Return(nil)
+				sessions.On("RevokeUserSessions", ctx, ID, mock.AnythingOfType("time.Time"), &bun.Tx{}).Return(nil)
+				sessions.On("CreateSession", ctx, mock.AnythingOfType("session.Session"), &bun.Tx{}).Return(nil)
+				tokens, err := us.ChangePassword(ctx, ID, tt.oldPassword, tt.newPassword)
+				require.Equal(t, tt.wantErr, err)
+				if !tt.wantUpdate {
+					rep.AssertNotCalled(t, "UpdatePassword", ctx, ID, mock.AnythingOfType("string"), &bun.Tx{})
+					return
+				}
+				var newHash string
+				for _, call := range rep.Calls {
+					if call.Method == "UpdatePassword" {
+						newHash = call.Arguments.String(2)
+					}
+				}
+				require.True(t, testPasswords.Verify(tt.newPassword, newHash))
+				if tt.mockUpdateErr != nil {
+					// Старые сессии остаются только вместе со старым паролем
+					tx.AssertCalled(t, "Rollback")
+					tx.AssertNotCalled(t, "Commit")
+					sessions.AssertNotCalled(t, "CreateSession", ctx, mock.AnythingOfType("session.Session"), &bun.Tx{})
+					require.Empty(t, tokens.AccessToken)
+					return
+				}
+				sessions.AssertCalled(t, "RevokeUserSessions", ctx, ID, mock.AnythingOfType("time.Time"), &bun.Tx{})
+				sessions.AssertCalled(t, "CreateSession", ctx, mock.AnythingOfType("session.Session"), &bun.Tx{})
+				tx.AssertCalled(t, "Commit")
+				require.NotEmpty(t, tokens.AccessToken)
+				require.NotEmpty(t, tokens.RefreshToken)
+			},
+		)
+	}
+}
+
+func TestUserService_SetRole(t *testing.T) {
+	ctx := context.Background()
+	userID, _ := uuid.NewV7()
+	tests := []struct {
+		name          string
+		role          user.Role
+		mockErr       error
+		mockRevokeErr error
+		wantErr       error
+		wantUpdate    bool
+		wantCommit    bool
+	}{
+		{
+			name:       "Test_1. Роль назначена, сессии отозваны",
+			role:       user.RoleSupport,
+			wantUpdate: true,
+			wantCommit: true,
+		},
+		{
+			name:    "Test_2. Неизвестная роль",
+			role:    "root",
+			wantErr: &user.InvalidRole{Role: "root"},
+		},
+		{
+			name:       "Test_3. Пользователь не найден",
+			role:       user.RoleAdmin,
+			mockErr:    repository.NoResultError{},
+			wantErr:    &user.NoSuchUser{ID: userID.String()},
+			wantUpdate: true,
+		},
+		{
+			name:          "Test_4. Ошибка отзыва сессий откатывает смену роли",
+			role:          user.RoleAdmin,
+			mockRevokeErr: errors.New("connection refused"),
+			wantErr:       errors.New("connection refused"),
+			wantUpdate:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				rep := mocks.UserRepository{}
+				sessions := mocks.SessionRepository{}
+				txHelper := storagemocks.TransactionHelper{}
+				tx := storagemocks.Transaction{}
+				ss := NewSessionService(&sessions, &rep, newTestTokenManager(t), &txHelper, time.Hour)
+				us := NewUserService(&rep, &mocks.LoginAttemptStore{}, &mocks.LoginAuditRepository{}, ss, testPolicy, testPasswords)
+				rep.On("UpdateRole", ctx, userID, tt.role, &bun.Tx{}).Return(tt.mockErr)
+				txHelper.On("StartTransaction", ctx).Return(&tx, nil)
+				tx.On("GetTransaction").Return(&bun.Tx{})
+				tx.On("Commit").Return(nil)
+				tx.On("Rollback").Return(nil)
+				sessions.On("RevokeUserSessions", ctx, userID, mock.AnythingOfType("time.Time"), &bun.Tx{}).
+					Return(tt.mockRevokeErr)
+				err := us.SetRole(ctx, userID, tt.role)
+				require.Equal(t, tt.wantErr, err)
+				if !tt.wantUpdate {
+					rep.AssertNotCalled(t, "UpdateRole", ctx, userID, tt.role, &bun.Tx{})
+					return
+				}
+				if !tt.wantCommit {
+					// Роль меняется только вместе с отзывом сессий
+					tx.AssertCalled(t, "Rollback")
+					tx.AssertNotCalled(t, "Commit")
+					return
+				}
+				sessions.AssertCalled(t, "RevokeUserSessions", ctx, userID, mock.AnythingOfType("time.Time"), &bun.Tx{})
+				tx.AssertCalled(t, "Commit")
 			},
 		)
 	}
 }
+
+func TestUserService_CleanupLoginHistory(t *testing.T) {
+	ctx := context.Background()
+	attempts := mocks.LoginAttemptStore{}
+	audit := mocks.LoginAuditRepository{}
+	us := NewUserService(&mocks.UserRepository{}, &attempts, &audit, nil, user.Policy{}, testPasswords)
+	attempts.On("DeleteExpired", ctx, mock.AnythingOfType("time.Time")).Return(nil)
+	audit.On("DeleteFailuresBefore", ctx, mock.AnythingOfType("time.Time")).Return(nil)
+
+	started := time.Now()
+	require.NoError(t, us.CleanupLoginHistory(ctx))
+	expiredBefore := attempts.Calls[0].Arguments.Get(1).(time.Time)
+	require.WithinDuration(t, started, expiredBefore, time.Second)
+	failuresBefore := audit.Calls[0].Arguments.Get(1).(time.Time)
+	require.WithinDuration(t, started.Add(-loginFailuresRetention), failuresBefore, time.Second)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/gofrs/uuid"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookLease запас блокировки доставок сверх времени их отправки
+	webhookLease          = time.Minute
+	webhookBackoffBase    = 30 * time.Second
+	webhookBackoffMax     = 6 * time.Hour
+	webhookMaxAttempts    = 12
+	webhookDeliveriesPage = 100
+	webhookMaxErrorLength = 500
+)
+
+type WebhookService struct {
+	repo    repository.WebhookRepository
+	client  clients.WebhookClient
+	timeout time.Duration
+	workers int
+}
+
+// NewWebhookService timeout — наибольшая длительность одного запроса клиента, workers — сколько доставок
+// отправляется одновременно
+func NewWebhookService(
+	repo repository.WebhookRepository, client clients.WebhookClient, timeout time.Duration, workers int,
+) *WebhookService {
+	if workers < 1 {
+		workers = 1
+	}
+	return &WebhookService{repo: repo, client: client, timeout: timeout, workers: workers}
+}
+
+// CreateEndpoint регистрирует адрес для событий пользователя. Если секрет не передан, он генерируется;
+// секрет возвращается только здесь.
+func (ws WebhookService) CreateEndpoint(
+	ctx context.Context, userID uuid.UUID, url, secret string, events []string,
+) (webhook.Endpoint, error) {
+	endpoint := webhook.Endpoint{UserID: userID, URL: url, Secret: secret, Events: events, CreatedAt: time.Now()}
+	if err := endpoint.Validate(); err != nil {
+		return webhook.Endpoint{}, err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return webhook.Endpoint{}, err
+	}
+	endpoint.ID = id
+	if err := ws.repo.CreateEndpoint(ctx, endpoint); err != nil {
+		return webhook.Endpoint{}, err
+	}
+
+	return endpoint, nil
+}
+
+func (ws WebhookService) GetEndpoints(ctx context.Context, userID uuid.UUID) ([]webhook.Endpoint, error) {
+	return ws.repo.GetEndpointsByUser(ctx, userID)
+}
+
+func (ws WebhookService) DeleteEndpoint(ctx context.Context, userID uuid.UUID, endpointID uuid.UUID) error {
+	err := ws.repo.DeleteEndpoint(ctx, userID, endpointID)
+	if errors.Is(err, repository.NoResultError{}) {
+		return &webhook.NoSuchEndpoint{ID: endpointID.String()}
+	}
+
+	return err
+}
+
+// GetDeliveries журнал последних доставок на адрес пользователя
+func (ws WebhookService) GetDeliveries(
+	ctx context.Context, userID uuid.UUID, endpointID uuid.UUID,
+) ([]webhook.Delivery, error) {
+	return ws.repo.GetDeliveries(ctx, userID, endpointID, webhookDeliveriesPage)
+}
+
+// DeliverDue отправляет до limit доставок, время которых наступило. Доставка считается успешной при ответе 2xx,
+// иначе откладывается с экспоненциально растущим интервалом, а после webhookMaxAttempts попыток
+// помечается неудачной. Доставки отправляются параллельно, не больше workers одновременно.
+// Возвращает количество отправленных доставок.
+func (ws WebhookService) DeliverDue(ctx context.Context, limit int) (int, []error) {
+	deliveries, err := ws.repo.ClaimDue(ctx, limit, ws.lease(limit))
+	if err != nil {
+		return 0, []error{err}
+	}
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	workers := make(chan struct{}, ws.workers)
+	for _, d := range deliveries {
+		claimed := d
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ws.deliver(ctx, claimed); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+			<-workers
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), errs
+}
+
+// lease блокирует пачку на время отправки в худшем случае, когда каждый запрос длится весь таймаут,
+// чтобы блокировка не истекла до конца отправки и другой экземпляр не отправил те же доставки повторно
+func (ws WebhookService) lease(limit int) time.Duration {
+	rounds := (limit + ws.workers - 1) / ws.workers
+	return time.Duration(rounds)*ws.timeout + webhookLease
+}
+
+func (ws WebhookService) deliver(ctx context.Context, d webhook.Delivery) error {
+	now := time.Now()
+	headers := map[string]string{
+		webhook.HeaderEvent:     d.Event,
+		webhook.HeaderDelivery:  d.ID.String(),
+		webhook.HeaderTimestamp: strconv.FormatInt(now.Unix(), 10),
+		webhook.HeaderSignature: webhook.Sign(d.Secret, now, d.Payload),
+	}
+	status, err := ws.client.Send(ctx, d.URL, headers, d.Payload)
+	if ctx.Err() != nil {
+		// Остановка сервиса: блокировка истечёт, и доставка будет повторена без учёта этой попытки
+		return ctx.Err()
+	}
+
+	d.Attempts++
+	d.LastStatusCode = status
+	d.LastError = ""
+	switch {
+	case err != nil:
+		d.LastError = truncate(err.Error(), webhookMaxErrorLength)
+	case status < http.StatusOK || status >= http.StatusMultipleChoices:
+		d.LastError = fmt.Sprintf("unexpected status %d", status)
+	}
+	switch {
+	case d.LastError == "":
+		d.Status = webhook.DeliveryDelivered
+		d.DeliveredAt = now
+		d.NextAttemptAt = time.Time{}
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = webhook.DeliveryFailed
+		d.NextAttemptAt = time.Time{}
+	default:
+		d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+	}
+
+	return ws.repo.SaveAttempt(ctx, d)
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBackoffBase
+	for i := 1; i < attempts && backoff < webhookBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookBackoffMax {
+		return webhookBackoffMax
+	}
+	return backoff
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/domain/webhook"
+	clientmocks "github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/clients/mocks"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository"
+	"github.com/ZhuzhomaAL/GopherMart/internal/app/core/ports/adapters/repository/mocks"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookService_DeliverDue(t *testing.T) {
+	ctx := context.Background()
+	deliveryID, _ := uuid.NewV7()
+	payload := []byte(`{"id":"1","type":"order.processed"}`)
+	tests := []struct {
+		name         string
+		attempts     int
+		mockStatus   int
+		mockErr      error
+		wantStatus   string
+		wantAttempts int
+		wantError    bool
+		wantNext     time.Duration
+	}{
+		{
+			name:         "Test_1. Ответ 2xx, доставка успешна",
+			mockStatus:   204,
+			wantStatus:   webhook.DeliveryDelivered,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Test_2. Ответ 500, доставка откладывается",
+			attempts:     2,
+			mockStatus:   500,
+			wantStatus:   webhook.DeliveryPending,
+			wantAttempts: 3,
+			wantError:    true,
+			wantNext:     2 * time.Minute,
+		},
+		{
+			name:         "Test_3. Ошибка сети, доставка откладывается",
+			mockErr:      errors.New("connection refused"),
+			wantStatus:   webhook.DeliveryPending,
+			wantAttempts: 1,
+			wantError:    true,
+			wantNext:     30 * time.Second,
+		},
+		{
+			name:         "Test_4. Последняя попытка, доставка неудачна",
+			attempts:     webhookMaxAttempts - 1,
+			mockStatus:   500,
+			wantStatus:   webhook.DeliveryFailed,
+			wantAttempts: webhookMaxAttempts,
+			wantError:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				repo := mocks.WebhookRepository{}
+				client := clientmocks.WebhookClient{}
+				ws := NewWebhookService(&repo, &client, time.Second, 5)
+				d := webhook.Delivery{
+					ID:       deliveryID,
+					Event:    webhook.EventOrderProcessed,
+					Payload:  payload,
+					Status:   webhook.DeliveryPending,
+					Attempts: tt.attempts,
+					URL:      "https://example.com/hook",
+					Secret:   "0123456789abcdef",
+				}
+				repo.On("ClaimDue", ctx, 10, 2*time.Second+webhookLease).Return([]webhook.Delivery{d}, nil)
+				var headers map[string]string
+				client.On(
+					"Send", ctx, d.URL, mock.AnythingOfType("map[string]string"), []byte(payload),
+				).Run(
+					func(args mock.Arguments) {
+						headers = args.Get(2).(map[string]string)
+					},
+				).Return(tt.mockStatus, tt.mockErr)
+				var saved webhook.Delivery
+				repo.On("SaveAttempt", ctx, mock.AnythingOfType("webhook.Delivery")).Run(
+					func(args mock.Arguments) {
+						saved = args.Get(1).(webhook.Delivery)
+					},
+				).Return(nil)
+
+				started := time.Now()
+				n, errs := ws.DeliverDue(ctx, 10)
+				require.Equal(t, 1, n)
+				require.Empty(t, errs)
+
+				require.Equal(t, webhook.EventOrderProcessed, headers[webhook.HeaderEvent])
+				require.Equal(t, deliveryID.String(), headers[webhook.HeaderDelivery])
+				unix, err := strconv.ParseInt(headers[webhook.HeaderTimestamp], 10, 64)
+				require.NoError(t, err)
+				require.True(t, webhook.Verify(d.Secret, time.Unix(unix, 0), payload, headers[webhook.HeaderSignature]))
+
+				require.Equal(t, tt.wantStatus, saved.Status)
+				require.Equal(t, tt.wantAttempts, saved.Attempts)
+				require.Equal(t, tt.wantError, saved.LastError != "")
+				if tt.wantNext > 0 {
+					require.WithinDuration(t, started.Add(tt.wantNext), saved.NextAttemptAt, time.Second)
+				} else {
+					require.True(t, saved.NextAttemptAt.IsZero())
+				}
+				if tt.wantStatus == webhook.DeliveryDelivered {
+					require.False(t, saved.DeliveredAt.IsZero())
+				}
+			},
+		)
+	}
+}
+
+func TestWebhookService_DeliverDueParallel(t *testing.T) {
+	const (
+		deliveriesCount = 6
+		workers         = 2
+	)
+	ctx := context.Background()
+	repo := mocks.WebhookRepository{}
+	client := clientmocks.WebhookClient{}
+	ws := NewWebhookService(&repo, &client, time.Second, workers)
+
+	deliveries := make([]webhook.Delivery, deliveriesCount)
+	for i := range deliveries {
+		id, _ := uuid.NewV7()
+		deliveries[i] = webhook.Delivery{
+			ID: id, Event: webhook.EventOrderProcessed, Payload: []byte(`{}`), Status: webhook.DeliveryPending,
+			URL: "https://example.com/hook", Secret: "0123456789abcdef",
+		}
+	}
+	// Блокировка рассчитана на три последовательных запроса каждого из двух потоков
+	repo.On("ClaimDue", ctx, deliveriesCount, 3*time.Second+webhookLease).Return(deliveries, nil)
+	var active, maxActive atomic.Int32
+	client.On("Send", ctx, mock.Anything, mock.Anything, mock.Anything).Run(
+		func(args mock.Arguments) {
+			n := active.Add(1)
+			for {
+				current := maxActive.Load()
+				if n <= current || maxActive.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			active.Add(-1)
+		},
+	).Return(200, nil)
+	repo.On("SaveAttempt", ctx, mock.AnythingOfType("webhook.Delivery")).Return(nil)
+
+	n, errs := ws.DeliverDue(ctx, deliveriesCount)
+	require.Equal(t, deliveriesCount, n)
+	require.Empty(t, errs)
+	require.EqualValues(t, workers, maxActive.Load())
+	repo.AssertNumberOfCalls(t, "SaveAttempt", deliveriesCount)
+}
+
+func TestWebhookService_CreateEndpoint(t *testing.T) {
+	ctx := context.Background()
+	userID, _ := uuid.NewV7()
+	tests := []struct {
+		name    string
+		url     string
+		secret  string
+		events  []string
+		wantErr bool
+	}{
+		{
+			name:   "Test_1. Адрес создан, секрет сгенерирован",
+			url:    "https://example.com/hook",
+			events: []string{webhook.EventOrderProcessed, webhook.EventBalanceWithdrawn},
+		},
+		{
+			name:    "Test_2. Неизвестное событие",
+			url:     "https://example.com/hook",
+			events:  []string{"order.lost"},
+			wantErr: true,
+		},
+		{
+			name:    "Test_3. Адрес не http",
+			url:     "ftp://example.com/hook",
+			events:  []string{webhook.EventOrderInvalid},
+			wantErr: true,
+		},
+		{
+			name:    "Test_4. Короткий секрет",
+			url:     "https://example.com/hook",
+			secret:  "short",
+			events:  []string{webhook.EventOrderInvalid},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				repo := mocks.WebhookRepository{}
+				ws := NewWebhookService(&repo, &clientmocks.WebhookClient{}, time.Second, 5)
+				repo.On("CreateEndpoint", ctx, mock.AnythingOfType("webhook.Endpoint")).Return(nil)
+
+				endpoint, err := ws.CreateEndpoint(ctx, userID, tt.url, tt.secret, tt.events)
+				if tt.wantErr {
+					var errInvalid *webhook.InvalidEndpoint
+					require.ErrorAs(t, err, &errInvalid)
+					repo.AssertNotCalled(t, "CreateEndpoint", ctx, mock.AnythingOfType("webhook.Endpoint"))
+					return
+				}
+				require.NoError(t, err)
+				require.NotEqual(t, uuid.Nil, endpoint.ID)
+				require.NotEmpty(t, endpoint.Secret)
+			},
+		)
+	}
+}
+
+func TestWebhookService_DeleteEndpoint(t *testing.T) {
+	ctx := context.Background()
+	userID, _ := uuid.NewV7()
+	endpointID, _ := uuid.NewV7()
+	repo := mocks.WebhookRepository{}
+	ws := NewWebhookService(&repo, &clientmocks.WebhookClient{}, time.Second, 5)
+	repo.On("DeleteEndpoint", ctx, userID, endpointID).Return(repository.NoResultError{})
+
+	err := ws.DeleteEndpoint(ctx, userID, endpointID)
+	var errNoSuch *webhook.NoSuchEndpoint
+	require.ErrorAs(t, err, &errNoSuch)
+}